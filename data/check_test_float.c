@@ -0,0 +1,411 @@
+// This file cross-checks the test data generated by `generate_test_float.py` against independent
+// implementations, namely the C library (glibc and libgcc's soft-fp, which follow the rounding mode set by
+// `fesetround` and raise the IEEE exceptions) and MPFR.
+// Build and run it in this directory with
+//     gcc -O2 -frounding-math -o check_test_float check_test_float.c -lmpfr -lm
+//     ./check_test_float [FILE...]
+// where the default files are all the vectors covered below.
+// For each line, the result is recomputed from the operands in the rounding mode given by the suffix of the
+// file name, and it is compared with the expected result (NaNs are only compared by their NaN-ness) and with
+// the expected exception flags.
+// The C library detects tininess after rounding as x86 does, while the generator detects it before rounding
+// as `float.Context` does, so an underflow flag that is only expected by the latter is accepted if the result
+// is the smallest normal number.
+// Rounding to nearest with ties away from zero is not available in C, so it is derived from the results
+// toward zero and to nearest (ties to even), where MPFR decides whether the exact result is a tie.
+#define _GNU_SOURCE
+#include <fenv.h>
+#include <math.h>
+#include <mpfr.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#define FLAG_INEXACT 0x01
+#define FLAG_UNDERFLOW 0x02
+#define FLAG_OVERFLOW 0x04
+#define FLAG_INFINITE 0x08
+#define FLAG_INVALID 0x10
+
+// Not a C rounding mode, see above
+#define ROUND_TIES_AWAY -1
+
+typedef unsigned __int128 u128;
+
+struct format {
+    const char *name;
+    int width;
+    // Number of the fraction bits
+    int m;
+};
+
+static const struct format FORMATS[] = {
+    {"f16", 16, 10},
+    {"f32", 32, 23},
+    {"f64", 64, 52},
+    {"f128", 128, 112},
+};
+
+static const char *DEFAULT_FILES[] = {
+    "f32/fma", "f32/fma_rne", "f32/fma_rtz", "f32/fma_rup", "f32/fma_rdn", "f32/fma_rna",
+    "f64/fma", "f64/fma_rne", "f64/fma_rtz", "f64/fma_rup", "f64/fma_rdn", "f64/fma_rna",
+    "f16/add", "f16/sub", "f16/mul", "f16/div", "f16/sqrt", "f16/fma", "f16/rem", "f16/fmod",
+    "f16/min", "f16/max", "f16/minnum", "f16/maxnum", "f16/minmagnitude", "f16/maxmagnitude", "f16/to_f32",
+    "f128/add", "f128/sub", "f128/mul", "f128/div", "f128/sqrt", "f128/fma", "f128/rem", "f128/fmod",
+    "f128/min", "f128/max", "f128/minnum", "f128/maxnum", "f128/minmagnitude", "f128/maxmagnitude", "f128/to_f64",
+    "f32/to_f16", "f64/to_f16", "f64/to_f128",
+    "f32/round", "f32/roundeven", "f64/round", "f64/roundeven",
+};
+
+static const struct format *find_format(const char *name)
+{
+    for (size_t i = 0; i < sizeof(FORMATS) / sizeof(FORMATS[0]); i++) {
+        if (strcmp(name, FORMATS[i].name) == 0) {
+            return &FORMATS[i];
+        }
+    }
+    return NULL;
+}
+
+static float to_f32(u128 x)
+{
+    uint32_t bits = x;
+    float f;
+    memcpy(&f, &bits, sizeof(f));
+    return f;
+}
+
+static u128 from_f32(float f)
+{
+    uint32_t bits;
+    memcpy(&bits, &f, sizeof(bits));
+    return bits;
+}
+
+static double to_f64(u128 x)
+{
+    uint64_t bits = x;
+    double f;
+    memcpy(&f, &bits, sizeof(f));
+    return f;
+}
+
+static u128 from_f64(double f)
+{
+    uint64_t bits;
+    memcpy(&bits, &f, sizeof(bits));
+    return bits;
+}
+
+// Convert `x` in the format `fmt` to binary128, which is exact, but raises the invalid flag for a signaling
+// NaN in a narrower format.
+static _Float128 to_f128(const struct format *fmt, u128 x)
+{
+    _Float128 f;
+    if (fmt->width == 16) {
+        uint16_t bits = x;
+        _Float16 h;
+        memcpy(&h, &bits, sizeof(h));
+        return h;
+    } else if (fmt->width == 32) {
+        return to_f32(x);
+    } else if (fmt->width == 64) {
+        return to_f64(x);
+    }
+    memcpy(&f, &x, sizeof(f));
+    return f;
+}
+
+// Round `f` to the format `fmt` in the current rounding mode.
+static u128 from_f128(const struct format *fmt, _Float128 f)
+{
+    u128 bits = 0;
+    if (fmt->width == 16) {
+        _Float16 h = (_Float16)f;
+        memcpy(&bits, &h, sizeof(h));
+    } else if (fmt->width == 32) {
+        bits = from_f32((float)f);
+    } else if (fmt->width == 64) {
+        bits = from_f64((double)f);
+    } else {
+        memcpy(&bits, &f, sizeof(f));
+    }
+    return bits;
+}
+
+static u128 magnitude(const struct format *fmt, u128 x)
+{
+    return x & (((u128)1 << (fmt->width - 1)) - 1);
+}
+
+static int is_nan(const struct format *fmt, u128 x)
+{
+    return magnitude(fmt, x) > ((((u128)1 << (fmt->width - fmt->m - 1)) - 1) << fmt->m);
+}
+
+static int is_finite(const struct format *fmt, u128 x)
+{
+    return magnitude(fmt, x) < ((((u128)1 << (fmt->width - fmt->m - 1)) - 1) << fmt->m);
+}
+
+static int get_flags(void)
+{
+    int raised = fetestexcept(FE_ALL_EXCEPT);
+    return (raised & FE_INVALID ? FLAG_INVALID : 0) | (raised & FE_DIVBYZERO ? FLAG_INFINITE : 0) |
+           (raised & FE_OVERFLOW ? FLAG_OVERFLOW : 0) | (raised & FE_UNDERFLOW ? FLAG_UNDERFLOW : 0) |
+           (raised & FE_INEXACT ? FLAG_INEXACT : 0);
+}
+
+// Computes the binary128 operation `op` on `x`, `y` and `z`, where the unused operands are ignored.
+// Returns 0 if `op` is not supported.
+static int compute_f128(const char *op, _Float128 x, _Float128 y, _Float128 z, _Float128 *r)
+{
+    if (strcmp(op, "add") == 0) {
+        *r = x + y;
+    } else if (strcmp(op, "sub") == 0) {
+        *r = x - y;
+    } else if (strcmp(op, "mul") == 0) {
+        *r = x * y;
+    } else if (strcmp(op, "div") == 0) {
+        *r = x / y;
+    } else if (strcmp(op, "sqrt") == 0) {
+        *r = sqrtf128(x);
+    } else if (strcmp(op, "fma") == 0) {
+        *r = fmaf128(x, y, z);
+    } else if (strcmp(op, "rem") == 0) {
+        *r = remainderf128(x, y);
+    } else if (strcmp(op, "fmod") == 0) {
+        *r = fmodf128(x, y);
+    } else if (strcmp(op, "min") == 0) {
+        *r = fminimumf128(x, y);
+    } else if (strcmp(op, "max") == 0) {
+        *r = fmaximumf128(x, y);
+    } else if (strcmp(op, "minnum") == 0) {
+        *r = fminimum_numf128(x, y);
+    } else if (strcmp(op, "maxnum") == 0) {
+        *r = fmaximum_numf128(x, y);
+    } else if (strcmp(op, "minmagnitude") == 0) {
+        *r = fminimum_magf128(x, y);
+    } else if (strcmp(op, "maxmagnitude") == 0) {
+        *r = fmaximum_magf128(x, y);
+    } else if (strcmp(op, "round") == 0) {
+        *r = roundf128(x);
+    } else if (strcmp(op, "roundeven") == 0) {
+        *r = roundevenf128(x);
+    } else if (strncmp(op, "to_", 3) == 0) {
+        *r = x;
+    } else {
+        return 0;
+    }
+    return 1;
+}
+
+// Computes `op` with `arity` operands `in` of the format `fmt` in the current rounding mode, and stores the result
+// in the format `dst` and the raised exception flags in `flags`.
+// The FMA of f32 and f64 is computed natively. Otherwise, the operands are converted to binary128 exactly, and
+// the result is computed in binary128 and then rounded to `dst`. This is a single rounding for binary128 itself,
+// for the conversions, and for the roundings to integral values, whose results are exact. It is also correct for
+// f16, since binary128 computes the exact results of the additions, multiplications, FMAs, remainders and min/max
+// of f16 numbers, and has more than `2 * 11 + 2` bits for the divisions and square roots, in which case the double
+// rounding is innocuous.
+// Returns 0 if `op` is not supported.
+static int compute(const struct format *fmt, const struct format *dst, const char *op, int arity, const u128 *in,
+                   u128 *out, int *flags)
+{
+    feclearexcept(FE_ALL_EXCEPT);
+    if (strcmp(op, "fma") == 0 && fmt->width == 32) {
+        *out = from_f32(fmaf(to_f32(in[0]), to_f32(in[1]), to_f32(in[2])));
+    } else if (strcmp(op, "fma") == 0 && fmt->width == 64) {
+        *out = from_f64(fma(to_f64(in[0]), to_f64(in[1]), to_f64(in[2])));
+    } else if (fmt->width == 16 || fmt->width == 128 || strncmp(op, "to_", 3) == 0 || strncmp(op, "round", 5) == 0) {
+        _Float128 x[3] = {0, 0, 0}, r;
+        for (int i = 0; i < arity; i++) {
+            x[i] = to_f128(fmt, in[i]);
+        }
+        if (!compute_f128(op, x[0], x[1], x[2], &r)) {
+            return 0;
+        }
+        *out = from_f128(dst, r);
+    } else {
+        return 0;
+    }
+    *flags = get_flags();
+    return 1;
+}
+
+static double to_double(const struct format *fmt, u128 x)
+{
+    return fmt->width == 32 ? to_f32(x) : to_f64(x);
+}
+
+// Returns the neighbor of the finite `x` away from zero.
+static u128 next_away(u128 x)
+{
+    return x + 1;
+}
+
+// Computes `op` with ties away from zero, which is only supported for the FMA of f32 and f64.
+static int compute_ties_away(const struct format *fmt, const char *op, const u128 *in, u128 *out, int *flags)
+{
+    u128 toward_zero;
+    int toward_zero_flags;
+    if (strcmp(op, "fma") != 0 || fmt->width > 64) {
+        return 0;
+    }
+    fesetround(FE_TOWARDZERO);
+    compute(fmt, fmt, op, 3, in, &toward_zero, &toward_zero_flags);
+    fesetround(FE_TONEAREST);
+    compute(fmt, fmt, op, 3, in, out, flags);
+    // The results only differ if the exact result is a tie that is rounded to the even `toward_zero`, and the
+    // flags are the same in both modes, since the largest finite number is odd.
+    if (*out != toward_zero || !(*flags & FLAG_INEXACT) || !is_finite(fmt, *out) ||
+        !is_finite(fmt, in[0]) || !is_finite(fmt, in[1]) || !is_finite(fmt, in[2])) {
+        return 1;
+    }
+    // The exact result of an f64 FMA lies between 2^-2148 and 2^2049, and is hence exact with 4300 bits.
+    // The neighbor is finite, since a tie above the largest finite number is rounded to infinity.
+    mpfr_t exact, addend, midpoint, away;
+    mpfr_init2(exact, 4300);
+    mpfr_init2(addend, 4300);
+    mpfr_init2(midpoint, 4300);
+    mpfr_init2(away, 4300);
+    mpfr_set_d(exact, to_double(fmt, in[0]), MPFR_RNDN);
+    mpfr_set_d(addend, to_double(fmt, in[1]), MPFR_RNDN);
+    mpfr_mul(exact, exact, addend, MPFR_RNDN);
+    mpfr_set_d(addend, to_double(fmt, in[2]), MPFR_RNDN);
+    mpfr_add(exact, exact, addend, MPFR_RNDN);
+    u128 neighbor = next_away(toward_zero);
+    mpfr_set_d(away, to_double(fmt, neighbor), MPFR_RNDN);
+    mpfr_set_d(midpoint, to_double(fmt, toward_zero), MPFR_RNDN);
+    mpfr_add(midpoint, midpoint, away, MPFR_RNDN);
+    mpfr_div_2ui(midpoint, midpoint, 1, MPFR_RNDN);
+    if (mpfr_equal_p(exact, midpoint)) {
+        *out = neighbor;
+    }
+    mpfr_clear(exact);
+    mpfr_clear(addend);
+    mpfr_clear(midpoint);
+    mpfr_clear(away);
+    return 1;
+}
+
+static u128 parse_hex(const char *s)
+{
+    u128 x = 0;
+    for (; *s; s++) {
+        x = x << 4 | (*s <= '9' ? *s - '0' : (*s | 0x20) - 'a' + 10);
+    }
+    return x;
+}
+
+static void print_hex(const struct format *fmt, u128 x)
+{
+    for (int i = fmt->width - 4; i >= 0; i -= 4) {
+        putchar("0123456789ABCDEF"[(int)(x >> i) & 0xf]);
+    }
+}
+
+// Checks the vectors in `path`, e.g. `f32/fma_rup`, and returns the number of mismatches, or -1 if the file
+// is not supported.
+static int check(const char *path)
+{
+    char name[64], *op;
+    const struct format *fmt, *dst;
+    int mode = FE_TONEAREST, arity = 2;
+    snprintf(name, sizeof(name), "%s", path);
+    if ((op = strchr(name, '/')) == NULL) {
+        return -1;
+    }
+    *op++ = '\0';
+    char *suffix = strrchr(op, '_');
+    if (suffix != NULL) {
+        static const struct {
+            const char *suffix;
+            int mode;
+        } MODES[] = {
+            {"_rne", FE_TONEAREST}, {"_rtz", FE_TOWARDZERO}, {"_rup", FE_UPWARD}, {"_rdn", FE_DOWNWARD},
+            {"_rna", ROUND_TIES_AWAY},
+        };
+        for (size_t i = 0; i < sizeof(MODES) / sizeof(MODES[0]); i++) {
+            if (strcmp(suffix, MODES[i].suffix) == 0) {
+                mode = MODES[i].mode;
+                *suffix = '\0';
+            }
+        }
+    }
+    fmt = find_format(name);
+    dst = strncmp(op, "to_", 3) == 0 ? find_format(op + 3) : fmt;
+    if (strcmp(op, "fma") == 0) {
+        arity = 3;
+    } else if (strcmp(op, "sqrt") == 0 || strncmp(op, "to_", 3) == 0 || strncmp(op, "round", 5) == 0) {
+        arity = 1;
+    }
+    FILE *file;
+    if (fmt == NULL || dst == NULL || (file = fopen(path, "r")) == NULL) {
+        return -1;
+    }
+
+    char line[256];
+    int count = 0, mismatches = 0, tininess = 0;
+    while (fgets(line, sizeof(line), file) != NULL) {
+        char *fields[8];
+        int n = 0;
+        for (char *token = strtok(line, " \n"); token != NULL && n < 8; token = strtok(NULL, " \n")) {
+            fields[n++] = token;
+        }
+        if (n != arity + 2) {
+            continue;
+        }
+        u128 in[3], expected = parse_hex(fields[arity]), actual;
+        int expected_flags = parse_hex(fields[arity + 1]), actual_flags, supported;
+        for (int i = 0; i < arity; i++) {
+            in[i] = parse_hex(fields[i]);
+        }
+        if (mode == ROUND_TIES_AWAY) {
+            supported = compute_ties_away(fmt, op, in, &actual, &actual_flags);
+        } else {
+            fesetround(mode);
+            supported = compute(fmt, dst, op, arity, in, &actual, &actual_flags);
+            fesetround(FE_TONEAREST);
+        }
+        if (!supported) {
+            fclose(file);
+            return -1;
+        }
+        count++;
+        int same_result = is_nan(dst, expected) ? is_nan(dst, actual) : expected == actual;
+        if (same_result && expected_flags == (actual_flags | FLAG_UNDERFLOW) && (actual_flags & FLAG_INEXACT) &&
+            magnitude(dst, actual) == (u128)1 << dst->m) {
+            tininess++;
+        } else if (!same_result || expected_flags != actual_flags) {
+            mismatches++;
+            printf("%s: mismatch for", path);
+            for (int i = 0; i < arity; i++) {
+                printf(" %s", fields[i]);
+            }
+            printf(": expected %s %02X, got ", fields[arity], expected_flags);
+            print_hex(dst, actual);
+            printf(" %02X\n", actual_flags);
+        }
+    }
+    fclose(file);
+    printf("%s: %d vectors, %d mismatches, %d underflows only detected before rounding\n", path, count,
+           mismatches, tininess);
+    return mismatches;
+}
+
+int main(int argc, char **argv)
+{
+    const char **files = argc > 1 ? (const char **)argv + 1 : DEFAULT_FILES;
+    int n = argc > 1 ? argc - 1 : (int)(sizeof(DEFAULT_FILES) / sizeof(DEFAULT_FILES[0]));
+    int failed = 0;
+    for (int i = 0; i < n; i++) {
+        int mismatches = check(files[i]);
+        if (mismatches < 0) {
+            printf("%s: not supported\n", files[i]);
+        }
+        failed |= mismatches != 0;
+    }
+    return failed;
+}
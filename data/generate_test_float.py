@@ -0,0 +1,695 @@
+# This file generates test data for floating-point operations whose vectors are not shipped with the
+# existing Berkeley TestFloat files (e.g. f32_mulAdd).
+# For f32 and f64, the operands are taken from the existing TestFloat vectors in `./f32` and `./f64`, so
+# that the special patterns chosen by TestFloat (boundaries, subnormals, infinities, NaNs, ...) are covered.
+# For the other formats (binary16, bfloat16 and binary128), the operands are generated with the same kinds of
+# patterns as TestFloat's random generator.
+# In both cases, the results are computed with exact rational arithmetic.
+# The output follows the TestFloat conventions:
+# * Each line contains the operands, the result and the exception flags, all in hex
+# * The flags are `invalid (10) | infinite (08) | overflow (04) | underflow (02) | inexact (01)`
+# * The default NaN is the x86 one
+# * Tininess is detected before rounding as in `float.Context`, while the TestFloat vectors detect it after
+# rounding, which only makes a difference in the underflow flag of results rounded to the smallest normal number
+import random
+from fractions import Fraction
+from math import isqrt
+
+FLAG_INEXACT = 0x01
+FLAG_UNDERFLOW = 0x02
+FLAG_OVERFLOW = 0x04
+FLAG_INFINITE = 0x08
+FLAG_INVALID = 0x10
+
+# Rounding modes, named after the suffixes of the generated files
+RNE = "rne"  # To nearest, ties to even
+RTZ = "rtz"  # Toward zero
+RUP = "rup"  # Toward positive infinity
+RDN = "rdn"  # Toward negative infinity
+RNA = "rna"  # To nearest, ties away from zero
+
+
+class Format:
+    def __init__(self, name, E, M, tininess_before=True):
+        self.name = name
+        self.tininess_before = tininess_before
+        self.E = E
+        self.M = M
+        self.bias = (1 << (E - 1)) - 1
+        self.emin = 1 - self.bias
+        self.emax = self.bias
+        self.width = 1 + E + M
+        self.digits = (self.width + 3) // 4
+
+    def hex(self, bits):
+        return f"{bits:0{self.digits}X}"
+
+    def default_nan(self):
+        return (1 << (self.width - 1)) | (((1 << self.E) - 1) << self.M) | (1 << (self.M - 1))
+
+    def inf(self, sign):
+        return (sign << (self.width - 1)) | (((1 << self.E) - 1) << self.M)
+
+    def max_finite(self, sign):
+        return (sign << (self.width - 1)) | ((((1 << self.E) - 2) << self.M) | ((1 << self.M) - 1))
+
+    def zero(self, sign):
+        return sign << (self.width - 1)
+
+    def is_signaling(self, bits):
+        sign, kind, _ = self.decode(bits)
+        return kind == "nan" and (bits >> (self.M - 1)) & 1 == 0
+
+    # Decode `bits` into `(sign, kind, value)`, where `kind` is one of "nan", "inf" and "finite", and
+    # `value` is the exact magnitude of a finite number.
+    def decode(self, bits):
+        sign = bits >> (self.width - 1)
+        e = (bits >> self.M) & ((1 << self.E) - 1)
+        m = bits & ((1 << self.M) - 1)
+        if e == (1 << self.E) - 1:
+            return sign, ("nan" if m != 0 else "inf"), None
+        if e == 0:
+            return sign, "finite", Fraction(m) * Fraction(2) ** (self.emin - self.M)
+        return sign, "finite", Fraction(m + (1 << self.M)) * Fraction(2) ** (e - self.bias - self.M)
+
+    # Round the exact value `(-1)^sign * magnitude` to the format in the rounding `mode`, and return the
+    # encoded result together with the raised flags.
+    # If `sticky` is set, the exact value is slightly larger than `magnitude` (by less than any bit that
+    # matters for rounding), which is used for irrational results such as square roots.
+    def round(self, sign, magnitude, mode=RNE, sticky=False):
+        if magnitude == 0 and not sticky:
+            return self.zero(sign), 0
+        e = floor_log2(magnitude)
+        q = max(e, self.emin) - self.M
+        n, inexact = round_to_integer(magnitude / Fraction(2) ** q, sign, mode, sticky)
+        flags = FLAG_INEXACT if inexact else 0
+        if n == 1 << (self.M + 1):
+            n >>= 1
+            q += 1
+        if n >= 1 << self.M and q + self.M > self.emax:
+            if mode in (RNE, RNA) or (mode == RUP and sign == 0) or (mode == RDN and sign == 1):
+                return self.inf(sign), FLAG_OVERFLOW | FLAG_INEXACT
+            return self.max_finite(sign), FLAG_OVERFLOW | FLAG_INEXACT
+        if flags and self.is_tiny(sign, magnitude, mode, sticky):
+            flags |= FLAG_UNDERFLOW
+        if n < 1 << self.M:
+            return (sign << (self.width - 1)) | n, flags
+        return (sign << (self.width - 1)) | ((q + self.M + self.bias) << self.M) | (n - (1 << self.M)), flags
+
+    # Tininess before rounding: the exact result is below the minimum normal number.
+    # Tininess after rounding: the result rounded to `M + 1` significant bits with unbounded exponent
+    # is below the minimum normal number.
+    def is_tiny(self, sign, magnitude, mode, sticky):
+        if self.tininess_before:
+            return magnitude < Fraction(2) ** self.emin
+        q = floor_log2(magnitude) - self.M
+        n, _ = round_to_integer(magnitude / Fraction(2) ** q, sign, mode, sticky)
+        return n * Fraction(2) ** q < Fraction(2) ** self.emin
+
+
+# Round the non-negative `value` (the magnitude of a number with the given `sign`) to an integer in the
+# rounding `mode`, and return the integer together with whether the rounding is inexact.
+def round_to_integer(value, sign, mode, sticky=False):
+    n, rem = divmod(value, 1)
+    n = int(n)
+    inexact = rem != 0 or sticky
+    half = Fraction(1, 2)
+    if mode == RNE:
+        up = rem > half or (rem == half and (sticky or n % 2 == 1))
+    elif mode == RNA:
+        up = rem >= half
+    elif mode == RUP:
+        up = inexact and sign == 0
+    elif mode == RDN:
+        up = inexact and sign == 1
+    else:
+        up = False
+    return n + 1 if up else n, inexact
+
+
+def floor_log2(x):
+    e = x.numerator.bit_length() - x.denominator.bit_length()
+    if Fraction(2) ** e > x:
+        e -= 1
+    elif Fraction(2) ** (e + 1) <= x:
+        e += 1
+    return e
+
+
+def signed(sign, value):
+    return -value if sign else value
+
+
+def propagate_nan(fmt, operands, invalid=False):
+    invalid = invalid or any(fmt.is_signaling(x) for x in operands)
+    return fmt.default_nan(), FLAG_INVALID if invalid else 0
+
+
+# Round the exact sum of two terms `(sx, vx)` and `(sy, vy)`.
+# An exact zero sum is negative only if both terms are negative zeros, or if the rounding mode is toward
+# negative infinity and the terms have different signs.
+def round_sum(fmt, sx, vx, sy, vy, mode):
+    v = signed(sx, vx) + signed(sy, vy)
+    if v == 0:
+        return fmt.zero(sx if sx == sy else int(mode == RDN)), 0
+    return fmt.round(int(v < 0), abs(v), mode)
+
+
+def add(fmt, a, b, mode=RNE):
+    (sa, ka, va), (sb, kb, vb) = fmt.decode(a), fmt.decode(b)
+    if ka == "nan" or kb == "nan":
+        return propagate_nan(fmt, (a, b))
+    if ka == "inf" and kb == "inf" and sa != sb:
+        return fmt.default_nan(), FLAG_INVALID
+    if ka == "inf" or kb == "inf":
+        return fmt.inf(sa if ka == "inf" else sb), 0
+    return round_sum(fmt, sa, va, sb, vb, mode)
+
+
+def sub(fmt, a, b, mode=RNE):
+    if fmt.decode(b)[1] == "nan":
+        return add(fmt, a, b, mode)
+    return add(fmt, a, b ^ (1 << (fmt.width - 1)), mode)
+
+
+def mul(fmt, a, b, mode=RNE):
+    (sa, ka, va), (sb, kb, vb) = fmt.decode(a), fmt.decode(b)
+    if ka == "nan" or kb == "nan":
+        return propagate_nan(fmt, (a, b))
+    if (ka == "inf" and kb == "finite" and vb == 0) or (kb == "inf" and ka == "finite" and va == 0):
+        return fmt.default_nan(), FLAG_INVALID
+    if ka == "inf" or kb == "inf":
+        return fmt.inf(sa ^ sb), 0
+    return fmt.round(sa ^ sb, va * vb, mode)
+
+
+def div(fmt, a, b, mode=RNE):
+    (sa, ka, va), (sb, kb, vb) = fmt.decode(a), fmt.decode(b)
+    if ka == "nan" or kb == "nan":
+        return propagate_nan(fmt, (a, b))
+    if ka == "inf" and kb == "inf" or ka == "finite" and kb == "finite" and va == 0 and vb == 0:
+        return fmt.default_nan(), FLAG_INVALID
+    if ka == "inf":
+        return fmt.inf(sa ^ sb), 0
+    if kb == "inf":
+        return fmt.zero(sa ^ sb), 0
+    if vb == 0:
+        return fmt.inf(sa ^ sb), FLAG_INFINITE
+    return fmt.round(sa ^ sb, va / vb, mode)
+
+
+def sqrt(fmt, a, mode=RNE):
+    sa, ka, va = fmt.decode(a)
+    if ka == "nan":
+        return propagate_nan(fmt, (a,))
+    if ka == "finite" and va == 0:
+        return a, 0
+    if sa == 1:
+        return fmt.default_nan(), FLAG_INVALID
+    if ka == "inf":
+        return a, 0
+    # Compute the square root with far more bits than needed, and record whether it is exact
+    k = 2 * (fmt.bias + fmt.M) + 16
+    scaled = va * 4**k
+    n = isqrt(scaled.numerator // scaled.denominator)
+    exact = scaled.denominator == 1 and n * n == scaled.numerator
+    return fmt.round(0, Fraction(n, 2**k), mode, not exact)
+
+
+def fma(fmt, a, b, c, mode=RNE):
+    (sa, ka, va), (sb, kb, vb), (sc, kc, vc) = fmt.decode(a), fmt.decode(b), fmt.decode(c)
+    sp = sa ^ sb
+    product_invalid = (ka == "inf" and kb == "finite" and vb == 0) or (kb == "inf" and ka == "finite" and va == 0)
+    if ka == "nan" or kb == "nan" or kc == "nan":
+        return propagate_nan(fmt, (a, b, c), product_invalid)
+    if product_invalid:
+        return fmt.default_nan(), FLAG_INVALID
+    if ka == "inf" or kb == "inf":
+        if kc == "inf" and sc != sp:
+            return fmt.default_nan(), FLAG_INVALID
+        return fmt.inf(sp), 0
+    if kc == "inf":
+        return fmt.inf(sc), 0
+    return round_sum(fmt, sp, va * vb, sc, vc, mode)
+
+
+# Convert `a` from the format `src` to the format `dst`.
+def convert(src, dst, a, mode=RNE):
+    sa, ka, va = src.decode(a)
+    if ka == "nan":
+        return dst.default_nan(), FLAG_INVALID if src.is_signaling(a) else 0
+    if ka == "inf":
+        return dst.inf(sa), 0
+    return dst.round(sa, va, mode)
+
+
+# A two's complement (if `signed`) or unsigned integer format with `width` bits.
+class Integer:
+    def __init__(self, name, width, signed):
+        self.name = name
+        self.width = width
+        self.signed = signed
+        self.digits = (width + 3) // 4
+
+    def hex(self, bits):
+        return f"{bits:0{self.digits}X}"
+
+    def decode(self, bits):
+        if self.signed and bits >> (self.width - 1):
+            return bits - (1 << self.width)
+        return bits
+
+    def encode(self, value):
+        return value & ((1 << self.width) - 1)
+
+    def contains(self, value):
+        if self.signed:
+            return -(1 << (self.width - 1)) <= value < 1 << (self.width - 1)
+        return 0 <= value < 1 << self.width
+
+    # The x86 "integer indefinite" value returned by invalid conversions
+    def indefinite(self):
+        return 1 << (self.width - 1) if self.signed else (1 << self.width) - 1
+
+
+# Convert the integer `a` in the format `src` to the float format `dst`.
+def convert_from_int(src, dst, a, mode=RNE):
+    v = src.decode(a)
+    return dst.round(int(v < 0), Fraction(abs(v)), mode)
+
+
+# Round `a` to an integral value in the rounding `mode`, as specified by the `roundToIntegral` operations, which
+# don't raise the inexact flag. As in TestFloat, NaNs are quieted with their payloads kept.
+def round_to_integral(fmt, a, mode=RNE):
+    sa, ka, va = fmt.decode(a)
+    if ka == "nan":
+        return a | (1 << (fmt.M - 1)), FLAG_INVALID if fmt.is_signaling(a) else 0
+    if ka == "inf":
+        return a, 0
+    n, _ = round_to_integer(va, sa, mode)
+    result, _ = fmt.round(sa, Fraction(n))
+    return result, 0
+
+
+# Convert `a` in the float format `src` to the integer format `dst`, as specified by the `convertToInteger`
+# operations, which don't raise the inexact flag.
+def convert_to_int(src, dst, a, mode):
+    sa, ka, va = src.decode(a)
+    if ka != "finite":
+        return dst.indefinite(), FLAG_INVALID
+    n, _ = round_to_integer(va, sa, mode)
+    v = signed(sa, n)
+    if not dst.contains(v):
+        return dst.indefinite(), FLAG_INVALID
+    return dst.encode(v), 0
+
+
+# Compute the remainder `a - n * b`, where `n` is `a / b` rounded to an integer in the rounding `mode`, i.e., to
+# nearest even for the IEEE `remainder` and toward zero for `fmod`. The result is always exact.
+def remainder(fmt, a, b, mode):
+    (sa, ka, va), (sb, kb, vb) = fmt.decode(a), fmt.decode(b)
+    if ka == "nan" or kb == "nan":
+        return propagate_nan(fmt, (a, b))
+    if ka == "inf" or kb == "finite" and vb == 0:
+        return fmt.default_nan(), FLAG_INVALID
+    if kb == "inf" or va == 0:
+        return a, 0
+    n, _ = round_to_integer(va / vb, 0, mode)
+    v = va - n * vb
+    if v == 0:
+        return fmt.zero(sa), 0
+    return fmt.round(sa ^ int(v < 0), abs(v))
+
+
+def rem(fmt, a, b, mode=RNE):
+    return remainder(fmt, a, b, RNE)
+
+
+def fmod(fmt, a, b, mode=RNE):
+    return remainder(fmt, a, b, RTZ)
+
+
+# The IEEE 754-2019 `minimum`/`maximum` family, where -0 is less than +0.
+# `minimum` and `maximum` propagate NaNs, `minimumNumber` and `maximumNumber` treat a NaN as missing data, and
+# `minimumMagnitude` and `maximumMagnitude` compare the magnitudes first and propagate NaNs.
+def min_max(fmt, a, b, is_max, is_number=False, is_magnitude=False):
+    (sa, ka, va), (sb, kb, vb) = fmt.decode(a), fmt.decode(b)
+    if ka == "nan" or kb == "nan":
+        if is_number and (ka != "nan" or kb != "nan"):
+            return (b if ka == "nan" else a), FLAG_INVALID if fmt.is_signaling(a) or fmt.is_signaling(b) else 0
+        return propagate_nan(fmt, (a, b))
+    # Map the numbers to keys that are ordered as required
+    ma = float("inf") if ka == "inf" else va
+    mb = float("inf") if kb == "inf" else vb
+    if is_magnitude and ma != mb:
+        return (a if (ma < mb) != is_max else b), 0
+    ka, kb = (-ma if sa else ma, sa), (-mb if sb else mb, sb)
+    # -0 is less than +0, i.e., a larger sign bit means smaller among equal values
+    less = (ka[0], -ka[1]) < (kb[0], -kb[1])
+    return (a if less != is_max else b), 0
+
+
+def minimum(fmt, a, b, mode=RNE):
+    return min_max(fmt, a, b, False)
+
+
+def maximum(fmt, a, b, mode=RNE):
+    return min_max(fmt, a, b, True)
+
+
+def minimum_number(fmt, a, b, mode=RNE):
+    return min_max(fmt, a, b, False, is_number=True)
+
+
+def maximum_number(fmt, a, b, mode=RNE):
+    return min_max(fmt, a, b, True, is_number=True)
+
+
+def minimum_magnitude(fmt, a, b, mode=RNE):
+    return min_max(fmt, a, b, False, is_magnitude=True)
+
+
+def maximum_magnitude(fmt, a, b, mode=RNE):
+    return min_max(fmt, a, b, True, is_magnitude=True)
+
+
+# The quiet equality `compareQuietEqual`, where NaN is unequal to everything and +0 is equal to -0.
+def eq(fmt, a, b, mode=RNE):
+    (sa, ka, va), (sb, kb, vb) = fmt.decode(a), fmt.decode(b)
+    flags = FLAG_INVALID if fmt.is_signaling(a) or fmt.is_signaling(b) else 0
+    if ka == "nan" or kb == "nan":
+        return 0, flags
+    if ka == "finite" and kb == "finite" and va == 0 and vb == 0:
+        return 1, 0
+    return int(a == b), 0
+
+
+# The `totalOrder` predicate, i.e., whether `a <= b` in the order -NaN < -inf < ... < -0 < +0 < ... < +inf < +NaN.
+# NaNs of the same sign are considered equal, as their payloads are not distinguished in `float.Context`.
+def total_order(fmt, a, b, mode=RNE):
+    def key(x):
+        sign, kind, value = fmt.decode(x)
+        k = {"nan": (2, 0), "inf": (1, 0), "finite": (0, value)}[kind]
+        return (-k[0], -k[1], 0) if sign else (k[0], k[1], 1)
+    return int(key(a) <= key(b)), 0
+
+
+OPS = {
+    "add": (add, 2), "sub": (sub, 2), "mul": (mul, 2), "div": (div, 2), "sqrt": (sqrt, 1), "fma": (fma, 3),
+    "rem": (rem, 2), "fmod": (fmod, 2),
+    "min": (minimum, 2), "max": (maximum, 2), "minnum": (minimum_number, 2), "maxnum": (maximum_number, 2),
+    "minmagnitude": (minimum_magnitude, 2), "maxmagnitude": (maximum_magnitude, 2),
+    "eq": (eq, 2), "totalorder": (total_order, 2),
+}
+
+
+# The format of boolean results, which are written as `0` or `1` as in TestFloat.
+class Boolean:
+    def hex(self, bit):
+        return str(bit)
+
+
+def read_operands(path, n):
+    with open(path) as file:
+        return [[int(x, 16) for x in line.split()[:n]] for line in file]
+
+
+# Write the vectors to `path`, where the operands are in `fmt` and the results are in `result_fmt` (`fmt` by
+# default).
+def write_vectors(path, fmt, vectors, result_fmt=None):
+    result_fmt = result_fmt or fmt
+    with open(path, "w") as file:
+        for operands, (result, flags) in vectors:
+            file.write(" ".join(fmt.hex(x) for x in operands) + f" {result_fmt.hex(result)} {flags:02X}\n")
+
+
+def generate_fma(fmt, step):
+    pairs = read_operands(f"./{fmt.name}/mul", 2)
+    addends = read_operands(f"./{fmt.name}/add", 2)
+    vectors = []
+    for i in range(0, len(pairs), step):
+        a, b = pairs[i]
+        # Use an unrelated TestFloat operand as the addend
+        c = addends[(i * 7919) % len(addends)][1]
+        vectors.append(((a, b, c), fma(fmt, a, b, c)))
+        # Force (massive) cancellation by using the negated rounded product as the addend
+        p = pairs[(i + step // 2) % len(pairs)]
+        (sa, ka, va), (sb, kb, vb) = fmt.decode(p[0]), fmt.decode(p[1])
+        if ka == "finite" and kb == "finite":
+            product, _ = fmt.round(1 - (sa ^ sb), va * vb)
+            vectors.append(((p[0], p[1], product), fma(fmt, p[0], p[1], product)))
+    return vectors
+
+
+# Generate the vectors of `op` in the rounding `mode`, reusing every `step`-th operand tuple of the
+# round-to-nearest-even vectors.
+def generate_rounding(fmt, op, mode, step):
+    f, n = OPS[op]
+    operands = read_operands(f"./{fmt.name}/{op}", n)[::step]
+    if op == "sqrt":
+        # The largest mantissas with both exponent parities, whose square roots overflow the mantissa when
+        # rounded up
+        mask = (1 << fmt.M) - 1
+        operands += [[(fmt.bias << fmt.M) | mask], [((fmt.bias + 1) << fmt.M) | mask], [fmt.max_finite(0)]]
+    if op == "fma":
+        # Exact results that are ties, where the even candidate is the one toward zero, i.e., `1 + ulp / 2`,
+        # its negation, half of the smallest subnormal number, and the largest finite number plus half an ulp
+        one, half_ulp, sign = fmt.bias << fmt.M, (fmt.bias - fmt.M - 1) << fmt.M, 1 << (fmt.width - 1)
+        operands += [
+            [half_ulp, one, one],
+            [sign | half_ulp, one, sign | one],
+            [1, (fmt.bias - 1) << fmt.M, fmt.zero(0)],
+            [fmt.max_finite(0), one, (2 * fmt.bias - fmt.M - 1) << fmt.M],
+        ]
+    return [(x, f(fmt, *x, mode)) for x in operands]
+
+
+# Generate a random operand in the style of TestFloat, i.e., the exponent is biased towards its boundaries
+# (zero/subnormal, around 1, largest finite, infinity/NaN), and the mantissa is either random or one of the
+# patterns that are likely to trigger corner cases in rounding (all zeros, all ones, a single bit, or a run
+# of leading/trailing ones).
+def random_operand(fmt, rng, near=None):
+    sign = rng.getrandbits(1)
+    e_max = (1 << fmt.E) - 1
+    if near is not None and rng.random() < 0.5:
+        # Pick an exponent close to `near`'s, so that additions may cancel and roundings may carry
+        e = min(max(((near >> fmt.M) & e_max) + rng.randint(-2, 2), 0), e_max)
+    else:
+        e = rng.choice([0, 0, 1, 2, fmt.bias - 1, fmt.bias, fmt.bias + 1, e_max - 2, e_max - 1, e_max,
+                        rng.randrange(1, e_max), rng.randrange(1, e_max), rng.randrange(1, e_max)])
+    k = rng.randrange(fmt.M)
+    m = rng.choice([
+        0,
+        (1 << fmt.M) - 1,
+        1 << k,
+        (1 << fmt.M) - (1 << k),
+        (1 << k) - 1,
+        rng.getrandbits(fmt.M),
+        rng.getrandbits(fmt.M),
+        rng.getrandbits(fmt.M),
+    ])
+    return (sign << (fmt.width - 1)) | (e << fmt.M) | m
+
+
+# Generate `count` vectors of `op` in the rounding `mode` with random operands.
+def generate_random(fmt, op, count, seed, mode=RNE):
+    f, n = OPS[op]
+    rng = random.Random(seed)
+    vectors = []
+    for _ in range(count):
+        x = [random_operand(fmt, rng)]
+        for _ in range(n - 1):
+            x.append(random_operand(fmt, rng, x[0]))
+        if op == "sqrt" and rng.random() < 0.75:
+            # Most square roots should be taken of non-negative numbers to be meaningful
+            x[0] &= (1 << (fmt.width - 1)) - 1
+        vectors.append((x, f(fmt, *x, mode)))
+    return vectors
+
+
+# Generate random FMA vectors, followed by the vectors with the same products whose addends are the negated rounded
+# products, which forces (massive) cancellation as in `generate_fma`.
+def generate_random_fma(fmt, count, seed):
+    vectors = generate_random(fmt, "fma", count, seed)
+    # Replay the random operands of the products
+    rng = random.Random(seed)
+    for _ in range(count):
+        a = random_operand(fmt, rng)
+        b = random_operand(fmt, rng, a)
+        (sa, ka, va), (sb, kb, vb) = fmt.decode(a), fmt.decode(b)
+        if ka == "finite" and kb == "finite":
+            product, _ = fmt.round(1 - (sa ^ sb), va * vb)
+            vectors.append(((a, b, product), fma(fmt, a, b, product)))
+    return vectors
+
+
+# Generate a random operand in `src` for the conversion to `dst`. Half of the operands are around the boundaries
+# of `dst`'s range (its subnormal numbers, 1, and its largest finite numbers), where rounding is most subtle.
+def random_conversion_operand(src, dst, rng):
+    if rng.random() < 0.5:
+        return random_operand(src, rng)
+    e = rng.choice([
+        rng.randint(dst.emin - dst.M - 2, dst.emin + 1),
+        rng.randint(-1, 1),
+        rng.randint(dst.emax - 1, dst.emax + 1),
+    ])
+    e = min(max(e + src.bias, 1), (1 << src.E) - 2)
+    x = random_operand(src, rng)
+    return (x & ~(((1 << src.E) - 1) << src.M)) | (e << src.M)
+
+
+# Generate `count` vectors of the conversion from `src` to `dst` in the rounding `mode`.
+def generate_conversion(src, dst, count, seed, mode=RNE):
+    rng = random.Random(seed)
+    vectors = []
+    for _ in range(count):
+        a = random_conversion_operand(src, dst, rng)
+        vectors.append(((a,), convert(src, dst, a, mode)))
+    return vectors
+
+
+# Generate a random integer in `src` for the conversion to `dst`. Most integers are around the precision of `dst`,
+# where rounding is most subtle, and the others are chosen from the boundaries of `src` or the patterns in
+# `random_operand`.
+def random_int_operand(src, dst, rng):
+    lo = -(1 << (src.width - 1)) if src.signed else 0
+    hi = (1 << (src.width - 1 if src.signed else src.width)) - 1
+    # The number of bits in the magnitude
+    bits = src.width - 1 if src.signed else src.width
+    k = rng.randint(1, bits)
+    if rng.random() < 0.5:
+        k = min(dst.M + rng.randint(-1, 3), bits)
+    j = rng.randrange(k)
+    v = rng.choice([
+        0, 1, lo, hi,
+        1 << (k - 1),
+        (1 << k) - 1,
+        (1 << k) - (1 << j),
+        (1 << (k - 1)) | (1 << j),
+        (1 << (k - 1)) | rng.getrandbits(k - 1),
+        (1 << (k - 1)) | rng.getrandbits(k - 1),
+    ])
+    v = min(v, hi)
+    if src.signed and rng.getrandbits(1):
+        v = max(-v, lo)
+    return src.encode(v)
+
+
+# Generate `count` vectors of the conversion from the integer format `src` to `dst` in the rounding `mode`.
+def generate_from_int(src, dst, count, seed, mode=RNE):
+    rng = random.Random(seed)
+    vectors = []
+    for _ in range(count):
+        a = random_int_operand(src, dst, rng)
+        vectors.append(((a,), convert_from_int(src, dst, a, mode)))
+    return vectors
+
+
+# Generate the vectors of rounding to an integral value in the rounding `mode`, reusing the operands of the
+# TestFloat vectors in `path`, together with the ties `±(n + 1/2)` and their neighbors.
+def generate_round_to_integral(fmt, path, mode):
+    operands = [x[0] for x in read_operands(path, 1)]
+    for n in [0, 1, 2, 3, (1 << fmt.M) - 2, (1 << fmt.M) - 1]:
+        for sign in [0, 1]:
+            x, _ = fmt.round(sign, Fraction(2 * n + 1, 2))
+            operands += [x - 1, x, x + 1]
+    return [((x,), round_to_integral(fmt, x, mode)) for x in operands]
+
+
+INT_MODES = {"trunc": RTZ, "floor": RDN, "ceil": RUP, "round": RNA, "roundeven": RNE}
+
+
+# Generate the vectors of the conversion from `src` to the integer format `dst` in the rounding `mode`, reusing
+# the operands of the TestFloat vectors in `path`, together with the ties `±(n + 1/2)` that are representable.
+def generate_to_int(src, dst, path, mode):
+    operands = [x[0] for x in read_operands(path, 1)]
+    for n in [0, 1, 2, 3, (1 << (dst.width - 1)) - 1, (1 << (dst.width - 1)), (1 << dst.width) - 1]:
+        for sign in [0, 1]:
+            x, flags = src.round(sign, Fraction(2 * n + 1, 2))
+            if flags == 0:
+                operands.append(x)
+    return [((x,), convert_to_int(src, dst, x, mode)) for x in operands]
+
+
+# Generate `count` vectors of the min/max operation `op`, where a quarter of the operands are equal in magnitude
+# to stress the handling of signed zeros and ties.
+def generate_min_max(fmt, op, count, seed):
+    f, _ = OPS[op]
+    rng = random.Random(seed)
+    vectors = []
+    for i in range(count):
+        a = random_operand(fmt, rng)
+        b = random_operand(fmt, rng, a)
+        if i % 4 == 0:
+            b = a ^ (rng.getrandbits(1) << (fmt.width - 1))
+        vectors.append(((a, b), f(fmt, a, b)))
+    return vectors
+
+
+# Generate the vectors of the predicate `op`, reusing every `step`-th operand pair of the TestFloat vectors in
+# `path`, together with operands that are equal in magnitude.
+def generate_predicate(fmt, op, path, step, seed):
+    f, _ = OPS[op]
+    rng = random.Random(seed)
+    operands = read_operands(path, 2)[::step]
+    for a, _ in operands[::2]:
+        operands.append((a, a ^ (rng.getrandbits(1) << (fmt.width - 1))))
+    return [((a, b), f(fmt, a, b)) for a, b in operands]
+
+
+if __name__ == "__main__":
+    for fmt in [Format("f32", 8, 23), Format("f64", 11, 52)]:
+        write_vectors(f"./{fmt.name}/fma", fmt, generate_fma(fmt, 32))
+        for mode in [RNE, RTZ, RUP, RDN, RNA]:
+            for op, step in [("add", 181), ("sub", 181), ("mul", 181), ("div", 181), ("sqrt", 3), ("fma", 11)]:
+                write_vectors(f"./{fmt.name}/{op}_{mode}", fmt, generate_rounding(fmt, op, mode, step))
+    for fmt, ops in [
+        (Format("f16", 5, 10), ["add", "sub", "mul", "div", "sqrt", "fma"]),
+        (Format("bf16", 8, 7), ["add", "sub", "mul", "div", "sqrt", "fma"]),
+        (Format("f128", 15, 112), ["add", "sub", "mul", "div", "sqrt", "fma"]),
+    ]:
+        for i, op in enumerate(ops):
+            if fmt.name == "f128" and op == "fma":
+                # binary128's operands are aligned in a narrower window than the other formats' in `FMA`, which
+                # should also be tested with cancellation
+                write_vectors(f"./{fmt.name}/{op}", fmt, generate_random_fma(fmt, 128, i))
+            else:
+                write_vectors(f"./{fmt.name}/{op}", fmt, generate_random(fmt, op, 128, i))
+    # Conversions between formats, where the narrowing conversion from f64 to f32 is tested in all rounding modes.
+    f16, bf16, f32, f64, f128 = Format("f16", 5, 10), Format("bf16", 8, 7), Format("f32", 8, 23), Format("f64", 11, 52), Format("f128", 15, 112)
+    for i, (src, dst) in enumerate([
+        (f16, f32), (f32, f16), (bf16, f32), (f32, bf16), (f16, bf16), (bf16, f16),
+        (f32, f64), (f64, f32), (f64, f16), (f64, f128), (f128, f64),
+    ]):
+        write_vectors(f"./{src.name}/to_{dst.name}", src, generate_conversion(src, dst, 256, i), dst)
+    for mode in [RTZ, RUP, RDN, RNA]:
+        write_vectors(f"./f64/to_f32_{mode}", f64, generate_conversion(f64, f32, 256, 0, mode), f32)
+    # Conversions from integers, where the inexact conversion from i64 to f32 is tested in all rounding modes.
+    i32, u32, i64, u64 = Integer("i32", 32, True), Integer("u32", 32, False), Integer("i64", 64, True), Integer("u64", 64, False)
+    for i, (src, dst) in enumerate([
+        (i32, f16), (i32, f32), (i32, f64), (u32, f32), (i64, f32), (i64, f64), (u64, f64), (u64, bf16), (i64, f128),
+    ]):
+        write_vectors(f"./{src.name}/to_{dst.name}", src, generate_from_int(src, dst, 256, i), dst)
+    for mode in [RTZ, RUP, RDN, RNA]:
+        write_vectors(f"./i64/to_f32_{mode}", i64, generate_from_int(i64, f32, 256, 0, mode), f32)
+    # Conversions to integers, where the TestFloat vectors only cover the signed conversions in the directed modes.
+    for src, width in [(f32, 32), (f64, 64)]:
+        for dst in [Integer(f"i{width}", width, True), Integer(f"u{width}", width, False)]:
+            for name, mode in INT_MODES.items():
+                if dst.signed and name in ["trunc", "floor", "ceil"]:
+                    continue
+                write_vectors(f"./{src.name}/to_{dst.name}_{name}", src, generate_to_int(src, dst, f"./{src.name}/to_i{width}_trunc", mode), dst)
+    # Rounding to the nearest integral value, whose TestFloat vectors are not shipped.
+    for fmt in [f32, f64]:
+        for name, mode in [("round", RNA), ("roundeven", RNE)]:
+            write_vectors(f"./{fmt.name}/{name}", fmt, generate_round_to_integral(fmt, f"./{fmt.name}/trunc", mode))
+    # The remainders are exact and hence don't depend on the rounding mode.
+    for i, fmt in enumerate([f16, bf16, f32, f64, f128]):
+        for j, op in enumerate(["rem", "fmod"]):
+            write_vectors(f"./{fmt.name}/{op}", fmt, generate_random(fmt, op, 256 if fmt in [f32, f64] else 128, 2 * i + j))
+    for i, fmt in enumerate([f16, bf16, f32, f64, f128]):
+        for j, op in enumerate(["min", "max", "minnum", "maxnum", "minmagnitude", "maxmagnitude"]):
+            write_vectors(f"./{fmt.name}/{op}", fmt, generate_min_max(fmt, op, 128, 6 * i + j))
+    for i, fmt in enumerate([f32, f64]):
+        for j, op in enumerate(["eq", "totalorder"]):
+            write_vectors(f"./{fmt.name}/{op}", fmt, generate_predicate(fmt, op, f"./{fmt.name}/lt", 16, 2 * i + j), Boolean())
@@ -0,0 +1,105 @@
+package float
+
+import (
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/tumberger/zk-Location/gadget"
+)
+
+// `FloatAPI` is the set of floating-point operations provided by `Context`.
+// Numeric code that accepts a `FloatAPI` instead of a concrete format, and allocates its constants by
+// `Self::const` instead of the format-specific constructors such as `Self::new_f64_constant`, is written once
+// and instantiated for any format by passing a context with the desired `E` and `M`, including custom formats.
+type FloatAPI interface {
+	// Return the frontend API of the context, e.g., for selecting the components of numbers directly.
+	API() frontend.API
+	// Return the integer gadget of the context.
+	IntGadget() *gadget.IntGadget
+	// Return the number of bits in the encoded exponent and mantissa.
+	Format() (E, M uint)
+
+	NewFloat(v frontend.Variable) FloatVar
+	Const(v float64) FloatVar
+	Encode(x FloatVar) frontend.Variable
+
+	Add(x, y FloatVar) FloatVar
+	Sub(x, y FloatVar) FloatVar
+	Mul(x, y FloatVar) FloatVar
+	Div(x, y FloatVar) FloatVar
+	Sqrt(x FloatVar) FloatVar
+	Rsqrt(x FloatVar) FloatVar
+	Cbrt(x FloatVar) FloatVar
+	Hypot(x, y FloatVar) FloatVar
+	FMA(x, y, z FloatVar) FloatVar
+	Dot(xs, ys []FloatVar) FloatVar
+	Sum(xs []FloatVar) FloatVar
+	Widen(x FloatVar) WideFloat
+	WideAdd(x, y WideFloat) WideFloat
+	WideSub(x, y WideFloat) WideFloat
+	WideMul(x, y WideFloat) WideFloat
+	Normalize(x WideFloat) FloatVar
+	TwoSum(x, y FloatVar) (FloatVar, FloatVar)
+	TwoProduct(x, y FloatVar) (FloatVar, FloatVar)
+	Double(x FloatVar) DoubleFloat
+	DoubleAdd(x, y DoubleFloat) DoubleFloat
+	DoubleSub(x, y DoubleFloat) DoubleFloat
+	DoubleMul(x, y DoubleFloat) DoubleFloat
+	DoubleDiv(x, y DoubleFloat) DoubleFloat
+	Rem(x, y FloatVar) FloatVar
+	Fmod(x, y FloatVar) FloatVar
+	Abs(x FloatVar) FloatVar
+	Neg(x FloatVar) FloatVar
+	Copysign(x, y FloatVar) FloatVar
+	ScaleB(x FloatVar, n frontend.Variable, bitWidth uint) FloatVar
+	Ldexp(x FloatVar, n int) FloatVar
+	Frexp(x FloatVar) (FloatVar, frontend.Variable)
+	Logb(x FloatVar) FloatVar
+
+	IsEq(x, y FloatVar) frontend.Variable
+	IsLt(x, y FloatVar) frontend.Variable
+	IsLe(x, y FloatVar) frontend.Variable
+	IsGt(x, y FloatVar) frontend.Variable
+	IsGe(x, y FloatVar) frontend.Variable
+	TotalOrder(x, y FloatVar) frontend.Variable
+	IsNaN(x FloatVar) frontend.Variable
+	IsInf(x FloatVar) frontend.Variable
+	IsZero(x FloatVar) frontend.Variable
+	IsSubnormal(x FloatVar) frontend.Variable
+	IsNormal(x FloatVar) frontend.Variable
+	Classify(x FloatVar) frontend.Variable
+
+	Min(x, y FloatVar) FloatVar
+	Max(x, y FloatVar) FloatVar
+	MinNum(x, y FloatVar) FloatVar
+	MaxNum(x, y FloatVar) FloatVar
+	MinMagnitude(x, y FloatVar) FloatVar
+	MaxMagnitude(x, y FloatVar) FloatVar
+
+	Trunc(x FloatVar) FloatVar
+	Floor(x FloatVar) FloatVar
+	Ceil(x FloatVar) FloatVar
+	Round(x FloatVar) FloatVar
+	RoundEven(x FloatVar) FloatVar
+	ToInt(x FloatVar) frontend.Variable
+	ToIntChecked(x FloatVar, bits uint, signed bool, mode RoundingMode) (frontend.Variable, frontend.Variable)
+	FromInt(v frontend.Variable, bitWidth uint, signed bool) FloatVar
+
+	Select(c frontend.Variable, x, y FloatVar) FloatVar
+	AssertIsEqual(x, y FloatVar)
+	AssertIsEqualOrULP(x, y FloatVar)
+	UlpDistanceLe(x, y FloatVar, n uint) frontend.Variable
+}
+
+var _ FloatAPI = (*Context)(nil)
+
+func (f *Context) API() frontend.API {
+	return f.Api
+}
+
+func (f *Context) IntGadget() *gadget.IntGadget {
+	return f.Gadget
+}
+
+func (f *Context) Format() (uint, uint) {
+	return f.E, f.M
+}
@@ -0,0 +1,103 @@
+package float
+
+// `DoubleFloat` is a double-word number, i.e., the unevaluated sum `Hi + Lo` of two `FloatVar`s with
+// `Hi = RN(Hi + Lo)`, which has about twice the precision of a single `FloatVar`, e.g., 106 bits for binary64.
+// Double-words are computed by `Self::double_add`, `Self::double_sub`, `Self::double_mul` and
+// `Self::double_div`, which are the algorithms of [JMP17] built on the error-free transformations
+// `Self::two_sum` and `Self::two_product`. Every step is an ordinary operation on `FloatVar`s, so the result is
+// proven in-circuit as a whole, and `Hi` is the double-word rounded to a single `FloatVar`.
+//
+// Error semantics: with the unit roundoff `u = 2^(-M - 1)`, the relative error of the result is less than
+// `3u^2 + 13u^3` for `Self::double_add` and `Self::double_sub`, `5u^2` for `Self::double_mul`, and
+// `15u^2 + 56u^3` for `Self::double_div`, as long as no intermediate result overflows or underflows.
+// Otherwise, the errors are those of the underlying operations, e.g., the low word of an infinite high word is
+// NaN, and the exception flags reflect the intermediate operations rather than the double-word result.
+// The rounding mode of the context should be `RoundNearestEven`, on which the error-free transformations rely.
+//
+// [JMP17] M. Joldes, J.-M. Muller, V. Popescu. Tight and rigorous error bounds for basic building blocks of
+// double-word arithmetic. ACM TOMS 44(2), 2017.
+type DoubleFloat struct {
+	Hi FloatVar
+	Lo FloatVar
+}
+
+// Convert `x` to a double-word whose low word is `+0`.
+func (f *Context) Double(x FloatVar) DoubleFloat {
+	return DoubleFloat{x, f.newFolded(f.constZero(false))}
+}
+
+// Compute `s = RN(x + y)` and the error `e = x + y - s` with Knuth's TwoSum, which is exact for any finite `x`
+// and `y` unless `x + y` overflows.
+// This panics if the rounding mode is not `RoundNearestEven`.
+func (f *Context) TwoSum(x, y FloatVar) (FloatVar, FloatVar) {
+	f.assertRoundNearestEven()
+	s := f.Add(x, y)
+	x_ := f.Sub(s, y)
+	y_ := f.Sub(s, x_)
+	return s, f.Add(f.Sub(x, x_), f.Sub(y, y_))
+}
+
+// Compute `s = RN(x + y)` and the error `e = x + y - s` with Dekker's Fast2Sum, which is cheaper than
+// `Self::two_sum`, but is only exact if the exponent of `x` is at least that of `y`, e.g., if `|x| >= |y|`.
+func (f *Context) fastTwoSum(x, y FloatVar) (FloatVar, FloatVar) {
+	f.assertRoundNearestEven()
+	s := f.Add(x, y)
+	return s, f.Sub(y, f.Sub(s, x))
+}
+
+// Compute `p = RN(x * y)` and the error `e = x * y - p` with `Self::fma`, which is exact for any finite `x`
+// and `y` unless `x * y` overflows or `e` is not representable due to underflow, i.e., unless the sum of the
+// exponents of `x` and `y` is at least `E_NORMAL_MIN + M`.
+// This panics if `Self::fma` is not supported for the format.
+func (f *Context) TwoProduct(x, y FloatVar) (FloatVar, FloatVar) {
+	p := f.Mul(x, y)
+	return p, f.FMA(x, y, f.Neg(p))
+}
+
+func (f *Context) assertRoundNearestEven() {
+	if f.RoundingMode != RoundNearestEven {
+		panic("the error-free transformations require rounding to nearest even")
+	}
+}
+
+// Compute `x + y` on double-words with Algorithm 6 (AccurateDWPlusDW) of [JMP17].
+func (f *Context) DoubleAdd(x, y DoubleFloat) DoubleFloat {
+	sh, sl := f.TwoSum(x.Hi, y.Hi)
+	th, tl := f.TwoSum(x.Lo, y.Lo)
+	vh, vl := f.fastTwoSum(sh, f.Add(sl, th))
+	zh, zl := f.fastTwoSum(vh, f.Add(tl, vl))
+	return DoubleFloat{zh, zl}
+}
+
+// Compute `x - y` on double-words, see `Self::double_add`.
+func (f *Context) DoubleSub(x, y DoubleFloat) DoubleFloat {
+	return f.DoubleAdd(x, DoubleFloat{f.Neg(y.Hi), f.Neg(y.Lo)})
+}
+
+// Compute `x * y` on double-words with Algorithm 12 (DWTimesDW3) of [JMP17].
+// This panics if `Self::fma` is not supported for the format.
+func (f *Context) DoubleMul(x, y DoubleFloat) DoubleFloat {
+	ch, cl1 := f.TwoProduct(x.Hi, y.Hi)
+	tl := f.FMA(x.Hi, y.Lo, f.Mul(x.Lo, y.Lo))
+	cl2 := f.FMA(x.Lo, y.Hi, tl)
+	zh, zl := f.fastTwoSum(ch, f.Add(cl1, cl2))
+	return DoubleFloat{zh, zl}
+}
+
+// Compute `x * y` for a double-word `x` and a `FloatVar` `y` with Algorithm 9 (DWTimesFP3) of [JMP17], whose
+// relative error is less than `2u^2`.
+func (f *Context) doubleMulFloat(x DoubleFloat, y FloatVar) DoubleFloat {
+	ch, cl1 := f.TwoProduct(x.Hi, y)
+	zh, zl := f.fastTwoSum(ch, f.FMA(x.Lo, y, cl1))
+	return DoubleFloat{zh, zl}
+}
+
+// Compute `x / y` on double-words with Algorithm 17 (DWDivDW2) of [JMP17].
+// This panics if `Self::fma` is not supported for the format.
+func (f *Context) DoubleDiv(x, y DoubleFloat) DoubleFloat {
+	th := f.Div(x.Hi, y.Hi)
+	r := f.doubleMulFloat(y, th)
+	d := f.Add(f.Sub(x.Hi, r.Hi), f.Sub(x.Lo, r.Lo))
+	zh, zl := f.fastTwoSum(th, f.Div(d, y.Hi))
+	return DoubleFloat{zh, zl}
+}
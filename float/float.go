@@ -3,6 +3,8 @@ package float
 import (
 	"math"
 	"math/big"
+	"math/bits"
+	"slices"
 
 	"github.com/consensys/gnark/frontend"
 
@@ -11,6 +13,23 @@ import (
 	"github.com/tumberger/zk-Location/util"
 )
 
+// `RoundingMode` specifies how the exact result of an operation is rounded to a representable number.
+type RoundingMode uint
+
+const (
+	// Round to the nearest representable number, and to the one with an even mantissa in case of a tie.
+	// This is the default rounding mode of IEEE 754.
+	RoundNearestEven RoundingMode = iota
+	// Round toward zero, i.e., truncate the exact result.
+	RoundTowardZero
+	// Round toward positive infinity.
+	RoundTowardPositive
+	// Round toward negative infinity.
+	RoundTowardNegative
+	// Round to the nearest representable number, and to the one with larger magnitude in case of a tie.
+	RoundNearestAway
+)
+
 type Context struct {
 	Api          frontend.API
 	Gadget       *gadget.IntGadget
@@ -19,6 +38,53 @@ type Context struct {
 	E_MAX        *big.Int
 	E_NORMAL_MIN *big.Int
 	E_MIN        *big.Int
+	// The rounding mode applied by all operations that round their results.
+	// Since the mode is known at compile time, only the constraints for the chosen mode are generated.
+	RoundingMode RoundingMode
+	// The accumulated exception flags, or nil if they are not tracked (see `EnableFlags`).
+	Flags *Flags
+	// Whether all numbers are statically assumed to be finite, which should be set before allocating any
+	// number.
+	// In this mode, `Self::new_float` enforces that the allocated number is finite, and `Self::add`,
+	// `Self::sub`, `Self::mul`, `Self::div` and `Self::sqrt` skip the constraints for propagating NaN and
+	// infinity. Instead, these operations are unsatisfiable if the result would be abnormal, i.e., if the
+	// result overflows (unless it is rounded to the largest finite number by a directed rounding mode),
+	// the divisor is zero, or the square root operand is negative.
+	// Other operations still handle abnormal numbers as usual, but their results must be finite in order
+	// to be used by the operations above.
+	FiniteOnly bool
+	// Whether subnormal numbers are flushed to zero with the same sign, which should be set before allocating
+	// any number.
+	// In this mode, subnormal inputs (including constants) are treated as zero, which saves the normalization
+	// in `Self::new_float`, and results that are tiny after rounding, i.e., whose magnitude is less than the
+	// smallest normal number after rounding to `M + 1` bits with an unbounded exponent, are replaced with
+	// zero, which saves the variable shift in `Self::round`.
+	// A flushed nonzero result is inexact and raises the underflow exception.
+	// Most operations become cheaper in this mode, except that `Self::add` and `Self::sub` need to check
+	// whether their results are tiny, since the difference of two close normal numbers may be subnormal.
+	// Note that this is not compliant with IEEE 754, and is only suitable for applications where subnormal
+	// numbers never occur or can be safely ignored.
+	FlushSubnormals bool
+}
+
+// `Flags` records the IEEE-754 exception flags raised by the operations of a context.
+// Each flag is a boolean variable, which is set once an operation raises the corresponding exception
+// and remains set afterwards.
+// Note that signaling NaNs are not distinguished from quiet NaNs in the circuit, so they do not raise
+// the invalid flag by themselves.
+type Flags struct {
+	// The operation has no meaningful result, e.g., `0 * inf`, `inf - inf`, `0 / 0`, `sqrt(-1)`, or
+	// a comparison involving NaN.
+	Invalid frontend.Variable
+	// A finite nonzero number is divided by zero.
+	DivisionByZero frontend.Variable
+	// The rounded result of finite inputs exceeds the largest finite number.
+	Overflow frontend.Variable
+	// The result is tiny and inexact, where tininess is detected before rounding, i.e., the exact result
+	// is nonzero and less than the smallest normal number in magnitude.
+	Underflow frontend.Variable
+	// The rounded result differs from the exact result.
+	Inexact frontend.Variable
 }
 
 // `FloatVar` represents an IEEE-754 floating point number in the constraint system,
@@ -48,12 +114,32 @@ type FloatVar struct {
 }
 
 func NewContext(api frontend.API, range_size uint, E, M uint) Context {
+	return NewContextWithGadget(api, NewGadget(api, range_size, E, M), E, M)
+}
+
+// Create a gadget that can be shared by the contexts of all formats with at most `E` exponent bits and `M`
+// mantissa bits via `NewContextWithGadget`, i.e., its powers-of-two table is sized to the largest format.
+func NewGadget(api frontend.API, range_size uint, E, M uint) *gadget.IntGadget {
+	return gadget.New(api, range_size, M+E+1)
+}
+
+// Create a context that uses an existing gadget `g`, which may be shared with other contexts created from
+// the same API, e.g., contexts of different formats in the same circuit.
+// Since each gadget commits its own range table and powers-of-two table, sharing a gadget pays for the table
+// entries and the finalize constraints only once, instead of once per context.
+// `g` should be created by `NewGadget` with the largest `E` and `M` in use. A powers-of-two table larger than
+// required by this format is fine, as the exponents queried by the operations are bounded by other checks,
+// except that `Self::to_int` accepts larger exponents accordingly.
+func NewContextWithGadget(api frontend.API, g *gadget.IntGadget, E, M uint) Context {
+	if g.PowersOfTwoSize() < M+E+1 {
+		panic("the powers-of-two table of the gadget is too small for this format")
+	}
 	E_MAX := new(big.Int).Lsh(big.NewInt(1), E-1)
 	E_NORMAL_MIN := new(big.Int).Sub(big.NewInt(2), E_MAX)
 	E_MIN := new(big.Int).Sub(E_NORMAL_MIN, big.NewInt(int64(M+1)))
 	return Context{
 		Api:          api,
-		Gadget:       gadget.New(api, range_size, M+E+1),
+		Gadget:       g,
 		E:            E,
 		M:            M,
 		E_MAX:        E_MAX,
@@ -62,10 +148,95 @@ func NewContext(api frontend.API, range_size uint, E, M uint) Context {
 	}
 }
 
+// Return a copy of the context that rounds in the given mode, which is useful for changing the rounding
+// mode of a single operation, e.g., `ctx.WithRoundingMode(RoundTowardPositive).Add(x, y)`.
+// The copy shares the same API and gadget with the original context.
+func (f *Context) WithRoundingMode(mode RoundingMode) *Context {
+	g := *f
+	g.RoundingMode = mode
+	return &g
+}
+
+// Start tracking the exception flags of all subsequent operations, with all flags initially cleared.
+// Copies of the context made afterwards, e.g., by `WithRoundingMode`, share the same flags.
+// Since tracking the flags costs additional constraints, it is disabled by default.
+func (f *Context) EnableFlags() {
+	f.Flags = &Flags{
+		Invalid:        0,
+		DivisionByZero: 0,
+		Overflow:       0,
+		Underflow:      0,
+		Inexact:        0,
+	}
+}
+
+// Accumulate the exception flags raised by an operation with operands `inputs` and result `result`,
+// if the flags are tracked.
+// The operation is invalid if the result is NaN but none of the inputs is NaN.
+// `is_div_by_zero`, `is_inexact`, `is_tiny` and `is_overflow` are nil if the operation never raises the
+// corresponding exception. `is_inexact` and `is_tiny` only need to be correct when all inputs are finite
+// and the operation is neither invalid nor a division by zero.
+func (f *Context) raiseFlags(
+	inputs []FloatVar,
+	result FloatVar,
+	is_div_by_zero frontend.Variable,
+	is_inexact frontend.Variable,
+	is_tiny frontend.Variable,
+	is_overflow frontend.Variable,
+) {
+	if f.Flags == nil {
+		return
+	}
+	// An operation without float inputs (e.g., `FromInt`) has neither NaN nor abnormal inputs.
+	var input_is_nan, input_is_abnormal frontend.Variable = 0, 0
+	for i, x := range inputs {
+		if i == 0 {
+			input_is_nan = f.IsNaN(x)
+			input_is_abnormal = x.IsAbnormal
+		} else {
+			input_is_nan = f.Api.Or(input_is_nan, f.IsNaN(x))
+			input_is_abnormal = f.Api.Or(input_is_abnormal, x.IsAbnormal)
+		}
+	}
+	input_is_not_nan := f.Api.Sub(big.NewInt(1), input_is_nan)
+	f.Api.Compiler().MarkBoolean(input_is_not_nan)
+	is_invalid := f.Api.And(f.IsNaN(result), input_is_not_nan)
+	f.Flags.Invalid = f.Api.Or(f.Flags.Invalid, is_invalid)
+
+	// The rounding of the result is only meaningful if no special case is involved.
+	is_special := f.Api.Or(input_is_abnormal, is_invalid)
+	if is_div_by_zero != nil {
+		f.Flags.DivisionByZero = f.Api.Or(f.Flags.DivisionByZero, is_div_by_zero)
+		is_special = f.Api.Or(is_special, is_div_by_zero)
+	}
+	is_regular := f.Api.Sub(big.NewInt(1), is_special)
+	f.Api.Compiler().MarkBoolean(is_regular)
+	if is_inexact != nil {
+		is_inexact = f.Api.And(is_inexact, is_regular)
+		if is_tiny != nil {
+			f.Flags.Underflow = f.Api.Or(f.Flags.Underflow, f.Api.And(is_tiny, is_inexact))
+		}
+		f.Flags.Inexact = f.Api.Or(f.Flags.Inexact, is_inexact)
+	}
+	if is_overflow != nil {
+		// Overflow is always inexact.
+		f.Flags.Overflow = f.Api.Or(f.Flags.Overflow, is_overflow)
+		f.Flags.Inexact = f.Api.Or(f.Flags.Inexact, is_overflow)
+	}
+}
+
 // Allocate a variable in the constraint system from a value.
 // This function decomposes the value into sign, exponent, and mantissa,
 // and enforces they are well-formed.
 func (f *Context) NewFloat(v frontend.Variable) FloatVar {
+	// A constant is decoded natively, unless it is abnormal in the finite-only mode, where the circuit below
+	// is unsatisfiable.
+	if c, ok := f.Api.Compiler().ConstantValue(v); ok && c.BitLen() <= int(f.E+f.M+1) {
+		if components := util.ComponentsOfBig(c, uint64(f.E), uint64(f.M)); !f.FiniteOnly || components[3].Sign() == 0 {
+			return f.NewBigConstant(c)
+		}
+	}
+
 	// Extract sign, exponent, and mantissa from the value
 	outputs, err := f.Api.Compiler().NewHint(hint.DecodeFloatHint, 2, v, f.E, f.M)
 	if err != nil {
@@ -90,7 +261,34 @@ func (f *Context) NewFloat(v frontend.Variable) FloatVar {
 	mantissa_is_not_zero := f.Api.Sub(big.NewInt(1), mantissa_is_zero)
 	f.Api.Compiler().MarkBoolean(mantissa_is_not_zero)
 	exponent_is_min := f.Gadget.IsEq(exponent, exponent_min)
-	exponent_is_max := f.Gadget.IsEq(exponent, exponent_max)
+	var exponent_is_max frontend.Variable
+	if f.FiniteOnly {
+		// Enforce that the number is finite, and hence we don't need to handle NaN and infinity below.
+		f.Api.AssertIsDifferent(exponent, exponent_max)
+		exponent_is_max = big.NewInt(0)
+	} else {
+		exponent_is_max = f.Gadget.IsEq(exponent, exponent_max)
+	}
+
+	// Add `2^M` to the mantissa to make its `M`-th bit 1
+	normal_mantissa := f.Api.Add(m, new(big.Int).Lsh(big.NewInt(1), f.M))
+	if !f.FiniteOnly {
+		normal_mantissa = f.Api.Select(
+			f.Api.And(exponent_is_max, mantissa_is_not_zero),
+			// If NaN, set the mantissa to 0
+			big.NewInt(0),
+			normal_mantissa,
+		)
+	}
+	if f.FlushSubnormals {
+		return FloatVar{
+			Sign: s,
+			// If zero or subnormal, set the exponent to 0's exponent
+			Exponent:   f.Api.Select(exponent_is_min, f.E_MIN, exponent),
+			Mantissa:   f.Api.Select(exponent_is_min, big.NewInt(0), normal_mantissa),
+			IsAbnormal: exponent_is_max,
+		}
+	}
 
 	// Find how many bits to shift the mantissa to the left to have the `(M - 1)`-th bit equal to 1
 	// and prodive it as a hint to the circuit
@@ -148,13 +346,7 @@ func (f *Context) NewFloat(v frontend.Variable) FloatVar {
 		exponent_is_min,
 		// If subnormal, shift the mantissa to the left by 1 to make its `M`-th bit 1
 		f.Api.Add(shifted_mantissa, shifted_mantissa),
-		f.Api.Select(
-			f.Api.And(exponent_is_max, mantissa_is_not_zero),
-			// If NaN, set the mantissa to 0
-			big.NewInt(0),
-			// Otherwise, add `2^M` to the mantissa to make its `M`-th bit 1
-			f.Api.Add(m, new(big.Int).Lsh(big.NewInt(1), f.M)),
-		),
+		normal_mantissa,
 	)
 
 	return FloatVar{
@@ -165,9 +357,75 @@ func (f *Context) NewFloat(v frontend.Variable) FloatVar {
 	}
 }
 
+// Encode `x` into its packed IEEE-754 bit pattern, i.e., the inverse of `Self::new_float`, which allows computed
+// numbers to be exposed as public outputs, or hashed or committed to in a compact form.
+// The encoding is canonical: NaN is always encoded as the positive quiet NaN whose mantissa has only the most
+// significant bit set (e.g., `0x7FC00000` in binary32), and a subnormal number is shifted back to the right
+// by `E_NORMAL_MIN - exponent` bits, with a biased exponent of 0.
+// The result is fully constrained for any `x` produced by the operations of this context, and the circuit is
+// unsatisfiable if the mantissa of a subnormal `x` has nonzero bits that would be shifted out.
+func (f *Context) Encode(x FloatVar) frontend.Variable {
+	if result, ok := f.foldEncode(x); ok {
+		return result
+	}
+
+	mantissa_is_zero := f.Api.IsZero(x.Mantissa)
+	is_nan := f.Api.And(x.IsAbnormal, mantissa_is_zero)
+
+	// The encoded magnitude of a normal number is `(exponent + E_MAX - 1) * 2^M + (mantissa - 2^M)`.
+	normal := f.Api.Add(
+		f.Api.Mul(f.Api.Add(x.Exponent, new(big.Int).Sub(f.E_MAX, big.NewInt(2))), new(big.Int).Lsh(big.NewInt(1), f.M)),
+		x.Mantissa,
+	)
+	var finite frontend.Variable
+	if f.FlushSubnormals {
+		// Zero is the only number whose exponent is less than `E_NORMAL_MIN`.
+		finite = f.Api.Select(mantissa_is_zero, big.NewInt(0), normal)
+	} else {
+		// Both subnormal numbers and zero have exponents less than `E_NORMAL_MIN`, and their encoded magnitudes
+		// are `mantissa / 2^shift`, where `shift = E_NORMAL_MIN - exponent` is in the range `[1, M + 1]`, since
+		// the mantissa of zero is 0 anyway.
+		is_small := f.Gadget.IsPositive(f.Api.Sub(new(big.Int).Sub(f.E_NORMAL_MIN, big.NewInt(1)), x.Exponent), f.E+1)
+		shift := f.Api.Mul(is_small, f.Api.Sub(f.E_NORMAL_MIN, x.Exponent))
+		two_to_shift := f.Gadget.QueryPowerOf2(shift)
+		// `2^shift` is nonzero, so `quotient` is uniquely determined by `quotient * 2^shift = mantissa` in the
+		// field. Enforcing that `quotient` is small makes this equation hold over the integers, which
+		// guarantees that no nonzero bits are shifted out.
+		quotient := f.Api.Div(x.Mantissa, two_to_shift)
+		f.Gadget.AssertBitLength(quotient, f.M+1, gadget.Loose)
+		finite = f.Api.Select(is_small, quotient, normal)
+	}
+	// The encoded magnitude of an abnormal number has all exponent bits set, and that of NaN additionally
+	// has the most significant mantissa bit set.
+	abnormal := f.Api.Add(
+		new(big.Int).Lsh(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), f.E), big.NewInt(1)), f.M),
+		f.Api.Mul(is_nan, new(big.Int).Lsh(big.NewInt(1), f.M-1)),
+	)
+	// The sign of NaN is dropped.
+	sign := f.Api.Select(is_nan, big.NewInt(0), x.Sign)
+
+	return f.Api.Add(
+		f.Api.Mul(sign, new(big.Int).Lsh(big.NewInt(1), f.E+f.M)),
+		f.Api.Select(x.IsAbnormal, abnormal, finite),
+	)
+}
+
 // Allocate a constant in the constraint system.
 func (f *Context) NewConstant(v uint64) FloatVar {
-	components := util.ComponentsOf(v, uint64(f.E), uint64(f.M))
+	return f.NewBigConstant(new(big.Int).SetUint64(v))
+}
+
+// Allocate a constant in the constraint system from its encoded value, which may have more than 64 bits.
+func (f *Context) NewBigConstant(v *big.Int) FloatVar {
+	components := util.ComponentsOfBig(v, uint64(f.E), uint64(f.M))
+	if f.FiniteOnly && components[3].Sign() != 0 {
+		panic("abnormal constant in a finite-only context")
+	}
+	if f.FlushSubnormals && components[1].Cmp(f.E_NORMAL_MIN) < 0 {
+		// Flush the subnormal constant to zero with the same sign.
+		components[1] = f.E_MIN
+		components[2] = big.NewInt(0)
+	}
 
 	return FloatVar{
 		Sign:       components[0],
@@ -177,6 +435,16 @@ func (f *Context) NewConstant(v uint64) FloatVar {
 	}
 }
 
+// Allocate a binary16 constant, which is the nearest binary16 number to `v`.
+func (f *Context) NewF16Constant(v float32) FloatVar {
+	return f.NewBigConstant(util.F64ToBits(float64(v), 5, 10))
+}
+
+// Allocate a bfloat16 constant, which is the nearest bfloat16 number to `v`.
+func (f *Context) NewBF16Constant(v float32) FloatVar {
+	return f.NewBigConstant(util.F64ToBits(float64(v), 8, 7))
+}
+
 func (f *Context) NewF32Constant(v float32) FloatVar {
 	return f.NewConstant(uint64(math.Float32bits(v)))
 }
@@ -185,6 +453,45 @@ func (f *Context) NewF64Constant(v float64) FloatVar {
 	return f.NewConstant(math.Float64bits(v))
 }
 
+// Allocate a constant that is the nearest number to `v` in the format of the context (ties to even),
+// regardless of `Self::rounding_mode`, which allows the same literal to be used in numeric code for any format.
+// The result is the same as `Self::new_f32_constant(float32(v))` in binary32 and `Self::new_f64_constant(v)` in
+// binary64, but note that `v` itself is a float64, so a binary128 constant with more precision should be
+// allocated by `Self::new_f128_constant` instead.
+func (f *Context) Const(v float64) FloatVar {
+	return f.NewBigConstant(util.F64ToBits(v, uint64(f.E), uint64(f.M)))
+}
+
+// Allocate a binary128 constant, which is the nearest binary128 number to `v`.
+// `v` may carry more precision than a float64, e.g., `new(big.Float).SetPrec(113).SetString("0.1")`.
+// Note that binary128 numbers are not supported by `Self::rsqrt`, `Self::cbrt`, `Self::hypot` and `Self::dot`,
+// which panic since their intermediate values overflow the usual 254-bit native fields.
+func (f *Context) NewF128Constant(v *big.Float) FloatVar {
+	return f.NewBigConstant(util.BitsOf(v, 15, 112))
+}
+
+// Return the components of `x` if all of them are known at compile time, e.g., if `x` is allocated by
+// `Self::new_constant`, which allows operations to generate cheaper constraints for constant operands.
+// If all operands of an operation are constants, the result is computed natively and returned as a constant
+// without any constraints, as implemented in `fold.go`.
+// The exponent is returned as a signed integer, while the other components are non-negative.
+func (f *Context) constantComponents(x FloatVar) ([4]*big.Int, bool) {
+	var components [4]*big.Int
+	modulus := f.Api.Compiler().Field()
+	for i, v := range []frontend.Variable{x.Sign, x.Exponent, x.Mantissa, x.IsAbnormal} {
+		c, ok := f.Api.Compiler().ConstantValue(v)
+		if !ok {
+			return components, false
+		}
+		// Negative constants are reduced modulo the field order, so we lift them back.
+		if c.Cmp(new(big.Int).Rsh(modulus, 1)) > 0 {
+			c = new(big.Int).Sub(c, modulus)
+		}
+		components[i] = c
+	}
+	return components, true
+}
+
 // Round the mantissa.
 // Note that the precision for subnormal numbers should be smaller than normal numbers, but in
 // our representation, the mantissa of subnormal numbers also has `M + 1` bits, and we have to set
@@ -197,13 +504,19 @@ func (f *Context) NewF64Constant(v float64) FloatVar {
 // reduce the number of constraints.
 // `half_flag` is a flag that indicates whether we should determine the rounding direction according
 // to the equality between the remainder and 1/2.
+// In other words, `half_flag` is 0 if the exact value is known to be slightly larger than `mantissa`,
+// in which case the result is also inexact, as required by the directed rounding modes.
+// `sign` is the sign of the result, which is only used by the directed rounding modes.
+// Return the rounded mantissa, together with whether the rounding is inexact if it is required by the
+// rounding mode or the exception flags (otherwise nil).
 func (f *Context) round(
 	mantissa frontend.Variable,
 	mantissa_bit_length uint,
 	shift frontend.Variable,
 	shift_max uint,
 	half_flag frontend.Variable,
-) frontend.Variable {
+	sign frontend.Variable,
+) (frontend.Variable, frontend.Variable) {
 	// Enforce that `two_to_shift` is equal to `2^shift`, where `shift` is known to be small.
 	two_to_shift := f.Gadget.QueryPowerOf2(shift)
 
@@ -232,47 +545,204 @@ func (f *Context) round(
 	p := outputs[0]
 	q := outputs[1]
 	r := outputs[2]
-	s := outputs[3]
 
-	// Enforce the bit length of `p`, `q`, `r` and `s`
+	// Enforce the bit length of `p`, `q` and `r`
 	f.Api.AssertIsBoolean(q)
 	f.Api.AssertIsBoolean(r)
 	f.Gadget.AssertBitLength(p, p_len, gadget.Loose)
-	f.Gadget.AssertBitLength(s, s_len, gadget.TightForSmallAbs)
 
-	// Concatenate `p || q`, `r || s`, and `p || q || r || s`.
-	// `p || q` is what we want, i.e., the final mantissa, and `r || s` will be thrown away.
+	// Concatenate `p || q`, which is what we want, i.e., the final mantissa.
 	pq := f.Api.Add(p, p, q)
-	rs := f.Api.Add(f.Api.Mul(r, new(big.Int).Lsh(big.NewInt(1), r_idx)), s)
-	pqrs := f.Api.Add(f.Api.Mul(pq, new(big.Int).Lsh(big.NewInt(1), q_idx)), rs)
 
-	// Enforce that `(p || q || r || s) << shift` is equal to `mantissa << shift_max`
-	// Multiplication here is safe because `p || q || r || s` has `shift_max + mantissa_bit_length` bits,
-	// and `2^shift` has at most `shift_max` bits, hence the product has `2 * shift_max + mantissa_bit_length`
-	// bits, which (at least for f32 and f64) is less than `F::MODULUS_BIT_SIZE` and will not overflow.
-	// This constraint guarantees that `p || q || r || s` is indeed `mantissa << (shift_max - shift)`.
-	f.Api.AssertIsEqual(
-		f.Api.Mul(pqrs, two_to_shift),
-		f.Api.Mul(mantissa, new(big.Int).Lsh(big.NewInt(1), shift_max)),
-	)
+	// `r || s` will be thrown away, and only its relation to `half`, the value of `r || s` when the
+	// remainder is exactly 1/2, matters.
+	var rs, half frontend.Variable
+	if 2*shift_max+mantissa_bit_length < uint(f.Api.Compiler().Field().BitLen()) {
+		// Enforce the bit length of `s`, which is empty if the mantissa has exactly `M + 2` bits and is not
+		// shifted, e.g., when rounding with `shift_max = 0` in the flush-to-zero mode.
+		var s frontend.Variable = big.NewInt(0)
+		if s_len > 0 {
+			s = outputs[3]
+			f.Gadget.AssertBitLength(s, s_len, gadget.TightForSmallAbs)
+		}
+
+		// Concatenate `r || s` and `p || q || r || s`.
+		rs = f.Api.Add(f.Api.Mul(r, new(big.Int).Lsh(big.NewInt(1), r_idx)), s)
+		pqrs := f.Api.Add(f.Api.Mul(pq, new(big.Int).Lsh(big.NewInt(1), q_idx)), rs)
+		half = new(big.Int).Lsh(big.NewInt(1), r_idx)
+
+		// Enforce that `(p || q || r || s) << shift` is equal to `mantissa << shift_max`
+		// Multiplication here is safe because `p || q || r || s` has `shift_max + mantissa_bit_length` bits,
+		// and `2^shift` has at most `shift_max` bits, hence the product has `2 * shift_max + mantissa_bit_length`
+		// bits, which is less than `F::MODULUS_BIT_SIZE` and will not overflow.
+		// This constraint guarantees that `p || q || r || s` is indeed `mantissa << (shift_max - shift)`.
+		f.Api.AssertIsEqual(
+			f.Api.Mul(pqrs, two_to_shift),
+			f.Api.Mul(mantissa, new(big.Int).Lsh(big.NewInt(1), shift_max)),
+		)
+	} else {
+		// For wide formats such as binary128, `mantissa << shift_max` may overflow the native field.
+		// In this case, we fall back to the first case and decompose the mantissa itself, i.e., `s` is the
+		// lowest `k = shift + mantissa_bit_length - M - 2` bits of the mantissa, where `k` depends on `shift`.
+		// Instead of using the hinted `s`, we compute `s` from `p`, `q` and `r`, and enforce `0 <= s < 2^k`
+		// by checking both `s` and `2^k - s - 1` have `shift_max + mantissa_bit_length - M - 2` bits.
+		// Together with the bit lengths of `p`, `q` and `r`, this guarantees that the decomposition is correct.
+		two_to_k := f.Gadget.QueryLargePowerOf2(f.Api.Add(shift, mantissa_bit_length-f.M-2))
+		rs = f.Api.Sub(mantissa, f.Api.Mul(pq, two_to_k, 2))
+		s := f.Api.Sub(rs, f.Api.Mul(r, two_to_k))
+		f.Gadget.AssertBitLength(s, s_len, gadget.Loose)
+		f.Gadget.AssertBitLength(f.Api.Sub(two_to_k, f.Api.Add(s, big.NewInt(1))), s_len, gadget.Loose)
+		half = two_to_k
+	}
+
+	// The result is inexact if and only if `r || s` is not 0 or the caller tells us that the mantissa
+	// is not exact.
+	var is_inexact frontend.Variable
+	if f.Flags != nil || f.RoundingMode == RoundTowardPositive || f.RoundingMode == RoundTowardNegative {
+		is_inexact = f.Api.Sub(big.NewInt(1), f.Api.And(f.Api.IsZero(rs), half_flag))
+		f.Api.Compiler().MarkBoolean(is_inexact)
+	}
 
-	// Determine whether `r == 1` and `s == 0`. If so, we need to round the mantissa according to `q`,
-	// and otherwise, we need to round the mantissa according to `r`.
-	// Also, we use `half_flag` to allow the caller to specify the rounding direction.
-	is_half := f.Api.And(f.Gadget.IsEq(rs, new(big.Int).Lsh(big.NewInt(1), r_idx)), half_flag)
-	carry := f.Api.Select(is_half, q, r)
+	var carry frontend.Variable
+	switch f.RoundingMode {
+	case RoundNearestEven:
+		// Determine whether `r == 1` and `s == 0`. If so, we need to round the mantissa according to `q`,
+		// and otherwise, we need to round the mantissa according to `r`.
+		// Also, we use `half_flag` to allow the caller to specify the rounding direction.
+		is_half := f.Api.And(f.Gadget.IsEq(rs, half), half_flag)
+		carry = f.Api.Select(is_half, q, r)
+	case RoundNearestAway:
+		// Ties are rounded away from zero, so we round up if and only if the remainder is at least 1/2.
+		carry = r
+	case RoundTowardZero:
+		carry = big.NewInt(0)
+	case RoundTowardPositive, RoundTowardNegative:
+		// The magnitude is rounded up if and only if the result is inexact and the sign matches the
+		// rounding direction.
+		if f.RoundingMode == RoundTowardPositive {
+			not_sign := f.Api.Sub(big.NewInt(1), sign)
+			f.Api.Compiler().MarkBoolean(not_sign)
+			carry = f.Api.And(is_inexact, not_sign)
+		} else {
+			carry = f.Api.And(is_inexact, sign)
+		}
+	default:
+		panic("unknown rounding mode")
+	}
 
 	// Round the mantissa according to `carry` and shift it back to the original position.
-	return f.Api.Mul(f.Api.Add(pq, carry), two_to_shift)
+	return f.Api.Mul(f.Api.Add(pq, carry), two_to_shift), is_inexact
+}
+
+// Round the mantissa of a result whose exponent may be less than `E_NORMAL_MIN`, i.e., the result may be
+// subnormal, and return the rounded mantissa together with the (possibly adjusted) exponent.
+// If the result is normal, we don't need to clear the lower bits of the rounded mantissa.
+// Otherwise, we need to clear `min(E_NORMAL_MIN - exponent, shift_max)` bits of the rounded mantissa,
+// where `shift_max` should be chosen such that the result is less than half of the smallest subnormal
+// number whenever `E_NORMAL_MIN - exponent >= shift_max`.
+// In addition to `Self::round`'s outputs, return whether the exact result is tiny if the exception flags
+// are tracked (otherwise nil).
+func (f *Context) roundSubnormal(
+	mantissa frontend.Variable,
+	mantissa_bit_length uint,
+	exponent frontend.Variable,
+	shift_max uint,
+	half_flag frontend.Variable,
+	sign frontend.Variable,
+) (frontend.Variable, frontend.Variable, frontend.Variable, frontend.Variable) {
+	if f.FlushSubnormals {
+		// Round the mantissa as if the result is normal, and flush it to zero if the result is tiny.
+		mantissa, is_inexact := f.round(mantissa, mantissa_bit_length, big.NewInt(0), 0, half_flag, sign)
+		mantissa, is_inexact, is_tiny := f.flushSubnormal(mantissa, exponent, is_inexact, true)
+		// The exponent of a tiny result may be much smaller than `E_MIN`, e.g., in `0 * 0`, so we set it to
+		// 0's exponent, which also keeps the range check in `Self::fix_overflow` tight.
+		return mantissa, f.Api.Select(is_tiny, f.E_MIN, exponent), is_inexact, is_tiny
+	}
+	shift := f.Gadget.Max(
+		f.Gadget.Min(
+			f.Api.Sub(f.E_NORMAL_MIN, exponent),
+			big.NewInt(int64(shift_max)),
+			f.E+1,
+		),
+		big.NewInt(0),
+		f.E+1,
+	)
+	// The exact result is tiny if and only if it is subnormal, i.e., we need to clear some bits.
+	var is_tiny frontend.Variable
+	if f.Flags != nil {
+		is_tiny = f.Api.Sub(big.NewInt(1), f.Api.IsZero(shift))
+		f.Api.Compiler().MarkBoolean(is_tiny)
+	}
+	mantissa, is_inexact := f.round(
+		mantissa,
+		mantissa_bit_length,
+		shift,
+		shift_max,
+		half_flag,
+		sign,
+	)
+	if f.RoundingMode == RoundTowardPositive || f.RoundingMode == RoundTowardNegative {
+		// A nonzero result less than half of the smallest subnormal number is rounded to 0 in the other modes,
+		// but may be rounded away from zero to the smallest subnormal number in the directed modes.
+		// In this case, `Self::round` returns `2^shift_max`, which does not fit in `M + 1` bits, so we right
+		// shift it to `2^M` and set the exponent to the smallest subnormal number's exponent.
+		is_tiny := f.Gadget.IsPositive(
+			f.Api.Sub(f.Api.Sub(f.E_NORMAL_MIN, exponent), big.NewInt(int64(shift_max))),
+			f.E+1,
+		)
+		mantissa = f.Api.Select(
+			is_tiny,
+			f.Api.Mul(mantissa, f.Api.Inverse(new(big.Int).Lsh(big.NewInt(1), shift_max-f.M))),
+			mantissa,
+		)
+		exponent = f.Api.Select(
+			is_tiny,
+			new(big.Int).Sub(f.E_NORMAL_MIN, big.NewInt(int64(f.M))),
+			exponent,
+		)
+	}
+	return mantissa, exponent, is_inexact, is_tiny
+}
+
+// Flush the rounded `mantissa` to zero if the result is tiny, i.e., if it is less than the smallest normal
+// number, which is used when `FlushSubnormals` is set.
+// If `may_carry` is true, `mantissa` may be `2^(M + 1)` after rounding, which is normal even if `exponent`
+// is `E_NORMAL_MIN - 1`, so the carry is taken into account.
+// Return the flushed mantissa, whether the result is inexact (nil if `is_inexact` is nil), and whether the
+// result is tiny.
+func (f *Context) flushSubnormal(
+	mantissa frontend.Variable,
+	exponent frontend.Variable,
+	is_inexact frontend.Variable,
+	may_carry bool,
+) (frontend.Variable, frontend.Variable, frontend.Variable) {
+	if may_carry {
+		exponent = f.Api.Add(exponent, f.Gadget.IsEq(mantissa, new(big.Int).Lsh(big.NewInt(1), f.M+1)))
+	}
+	is_tiny := f.Gadget.IsPositive(
+		f.Api.Sub(new(big.Int).Sub(f.E_NORMAL_MIN, big.NewInt(1)), exponent),
+		f.E+2,
+	)
+	if is_inexact != nil {
+		// A nonzero result becomes inexact after being flushed to zero.
+		mantissa_is_not_zero := f.Api.Sub(big.NewInt(1), f.Api.IsZero(mantissa))
+		f.Api.Compiler().MarkBoolean(mantissa_is_not_zero)
+		is_inexact = f.Api.Or(is_inexact, f.Api.And(is_tiny, mantissa_is_not_zero))
+	}
+	return f.Api.Select(is_tiny, big.NewInt(0), mantissa), is_inexact, is_tiny
 }
 
 // Fix mantissa and exponent overflow.
+// `sign` is the sign of the result, which is only used by the directed rounding modes.
+// In addition to the fixed mantissa, exponent and abnormal flag, return whether a finite result overflows
+// if it is required by the rounding mode or the exception flags (otherwise nil).
 func (f *Context) fixOverflow(
 	mantissa frontend.Variable,
 	mantissa_is_zero frontend.Variable,
 	exponent frontend.Variable,
 	input_is_abnormal frontend.Variable,
-) (frontend.Variable, frontend.Variable, frontend.Variable) {
+	sign frontend.Variable,
+) (frontend.Variable, frontend.Variable, frontend.Variable, frontend.Variable) {
 	// Check if mantissa overflows
 	// Since the mantissa without carry is always smaller than `2^(M + 1)`, overflow only happens
 	// when the original mantissa is `2^(M + 1) - 1` and the carry is 1. Therefore, the only possible
@@ -280,37 +750,137 @@ func (f *Context) fixOverflow(
 	mantissa_overflow := f.Gadget.IsEq(mantissa, new(big.Int).Lsh(big.NewInt(1), f.M+1))
 	// If mantissa overflows, we need to increment the exponent
 	exponent = f.Api.Add(exponent, mantissa_overflow)
+	if f.FiniteOnly {
+		return f.fixOverflowFinite(mantissa, mantissa_is_zero, mantissa_overflow, exponent, sign)
+	}
 	// Check if exponent overflows. If so, the result is abnormal.
+	// Note that an exact zero never overflows, even if its exponent is large, e.g., in `x - x` where
+	// `x`'s exponent is `E_MAX - 1`.
 	// Also, if the input is already abnormal, the result is of course abnormal.
-	is_abnormal := f.Api.Or(f.Gadget.IsPositive(f.Api.Sub(exponent, f.E_MAX), f.E+1), input_is_abnormal)
+	mantissa_is_not_zero := f.Api.Sub(big.NewInt(1), mantissa_is_zero)
+	f.Api.Compiler().MarkBoolean(mantissa_is_not_zero)
+	exponent_overflow := f.Api.And(f.Gadget.IsPositive(f.Api.Sub(exponent, f.E_MAX), f.E+1), mantissa_is_not_zero)
+	is_abnormal := f.Api.Or(exponent_overflow, input_is_abnormal)
+
+	mantissa = f.Api.Select(
+		f.Api.Or(mantissa_overflow, is_abnormal),
+		// If mantissa overflows, we right shift the mantissa by 1 and obtain `2^M`.
+		// If the result is abnormal, we set the mantissa to infinity's mantissa.
+		// We can combine both cases as inifinity's mantissa is `2^M`.
+		// We will adjust the mantissa latter if the result is NaN.
+		new(big.Int).Lsh(big.NewInt(1), f.M),
+		mantissa,
+	)
+	exponent = f.Api.Select(
+		is_abnormal,
+		// If the result is abnormal, we set the exponent to infinity/NaN's exponent.
+		f.E_MAX,
+		f.Api.Select(
+			mantissa_is_zero,
+			// If the result is 0, we set the exponent to 0's exponent.
+			f.E_MIN,
+			// Otherwise, return the original exponent.
+			exponent,
+		),
+	)
+
+	// Unlike `exponent_overflow`, `is_overflow` excludes the case where the input is already abnormal.
+	var is_overflow frontend.Variable
+	if f.Flags != nil || f.RoundingMode != RoundNearestEven && f.RoundingMode != RoundNearestAway {
+		input_is_not_abnormal := f.Api.Sub(big.NewInt(1), input_is_abnormal)
+		f.Api.Compiler().MarkBoolean(input_is_not_abnormal)
+		is_overflow = f.Api.And(exponent_overflow, input_is_not_abnormal)
+	}
+
+	// In the directed rounding modes, a finite result that overflows toward zero becomes the largest
+	// finite number with the same sign instead of infinity.
+	var toward_zero frontend.Variable
+	switch f.RoundingMode {
+	case RoundTowardZero:
+		toward_zero = big.NewInt(1)
+	case RoundTowardPositive:
+		toward_zero = sign
+	case RoundTowardNegative:
+		toward_zero = f.Api.Sub(big.NewInt(1), sign)
+		f.Api.Compiler().MarkBoolean(toward_zero)
+	default:
+		return mantissa, exponent, is_abnormal, is_overflow
+	}
+	is_max_finite := f.Api.And(is_overflow, toward_zero)
+	is_not_max_finite := f.Api.Sub(big.NewInt(1), is_max_finite)
+	f.Api.Compiler().MarkBoolean(is_not_max_finite)
 
 	return f.Api.Select(
-			f.Api.Or(mantissa_overflow, is_abnormal),
-			// If mantissa overflows, we right shift the mantissa by 1 and obtain `2^M`.
-			// If the result is abnormal, we set the mantissa to infinity's mantissa.
-			// We can combine both cases as inifinity's mantissa is `2^M`.
-			// We will adjust the mantissa latter if the result is NaN.
-			new(big.Int).Lsh(big.NewInt(1), f.M),
+			is_max_finite,
+			new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), f.M+1), big.NewInt(1)),
 			mantissa,
 		), f.Api.Select(
-			is_abnormal,
-			// If the result is abnormal, we set the exponent to infinity/NaN's exponent.
-			f.E_MAX,
-			f.Api.Select(
-				mantissa_is_zero,
-				// If the result is 0, we set the exponent to 0's exponent.
-				f.E_MIN,
-				// Otherwise, return the original exponent.
-				exponent,
-			),
-		), is_abnormal
+			is_max_finite,
+			new(big.Int).Sub(f.E_MAX, big.NewInt(1)),
+			exponent,
+		), f.Api.And(is_abnormal, is_not_max_finite), is_overflow
+}
+
+// The counterpart of `Self::fix_overflow` in the finite-only mode, where `input_is_abnormal` is known to be
+// false, and `exponent` has been incremented if `mantissa_overflow` is true.
+// Instead of returning infinity, the constraints are unsatisfiable if the result overflows, unless it is
+// rounded to the largest finite number by a directed rounding mode.
+func (f *Context) fixOverflowFinite(
+	mantissa frontend.Variable,
+	mantissa_is_zero frontend.Variable,
+	mantissa_overflow frontend.Variable,
+	exponent frontend.Variable,
+	sign frontend.Variable,
+) (frontend.Variable, frontend.Variable, frontend.Variable, frontend.Variable) {
+	mantissa = f.Api.Select(
+		mantissa_overflow,
+		// If mantissa overflows, we right shift the mantissa by 1 and obtain `2^M`.
+		new(big.Int).Lsh(big.NewInt(1), f.M),
+		mantissa,
+	)
+	exponent = f.Api.Select(
+		mantissa_is_zero,
+		// If the result is 0, we set the exponent to 0's exponent.
+		f.E_MIN,
+		// Otherwise, return the original exponent.
+		exponent,
+	)
+
+	var toward_zero frontend.Variable
+	switch f.RoundingMode {
+	case RoundTowardZero:
+		toward_zero = big.NewInt(1)
+	case RoundTowardPositive:
+		toward_zero = sign
+	case RoundTowardNegative:
+		toward_zero = f.Api.Sub(big.NewInt(1), sign)
+		f.Api.Compiler().MarkBoolean(toward_zero)
+	default:
+		// Enforce that `exponent < E_MAX`, i.e., the result does not overflow.
+		f.Gadget.AssertBitLength(f.Api.Sub(new(big.Int).Sub(f.E_MAX, big.NewInt(1)), exponent), f.E+1, gadget.Loose)
+		return mantissa, exponent, big.NewInt(0), nil
+	}
+	// In the directed rounding modes, a result that overflows toward zero becomes the largest finite number
+	// with the same sign, and a result that overflows away from zero is not allowed.
+	is_overflow := f.Gadget.IsPositive(f.Api.Sub(exponent, f.E_MAX), f.E+1)
+	f.Api.AssertIsEqual(f.Api.Mul(is_overflow, f.Api.Sub(big.NewInt(1), toward_zero)), 0)
+
+	return f.Api.Select(
+			is_overflow,
+			new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), f.M+1), big.NewInt(1)),
+			mantissa,
+		), f.Api.Select(
+			is_overflow,
+			new(big.Int).Sub(f.E_MAX, big.NewInt(1)),
+			exponent,
+		), big.NewInt(0), is_overflow
 }
 
 // Enforce the equality between two numbers.
 func (f *Context) AssertIsEqual(x, y FloatVar) {
 	is_nan := f.Api.Or(
-		f.Api.And(x.IsAbnormal, f.Api.IsZero(x.Mantissa)),
-		f.Api.And(y.IsAbnormal, f.Api.IsZero(y.Mantissa)),
+		f.IsNaN(x),
+		f.IsNaN(y),
 	)
 	f.Api.AssertIsEqual(f.Api.Select(
 		is_nan,
@@ -329,8 +899,8 @@ func (f *Context) AssertIsEqual(x, y FloatVar) {
 // Enforce the equality between two numbers, relaxed to checking ULP <1 (optimized)
 func (f *Context) AssertIsEqualOrULP(x, y FloatVar) {
 	is_nan := f.Api.Or(
-		f.Api.And(x.IsAbnormal, f.Api.IsZero(x.Mantissa)),
-		f.Api.And(y.IsAbnormal, f.Api.IsZero(y.Mantissa)),
+		f.IsNaN(x),
+		f.IsNaN(y),
 	)
 	f.Api.AssertIsEqual(f.Api.Select(
 		is_nan,
@@ -385,8 +955,8 @@ func (f *Context) AssertIsEqualOrULP(x, y FloatVar) {
 // Enforce the equality between two numbers, relaxed to checking ULP <X
 func (f *Context) AssertIsEqualOrCustomULP32(x, y FloatVar, ulp float32) {
 	is_nan := f.Api.Or(
-		f.Api.And(x.IsAbnormal, f.Api.IsZero(x.Mantissa)),
-		f.Api.And(y.IsAbnormal, f.Api.IsZero(y.Mantissa)),
+		f.IsNaN(x),
+		f.IsNaN(y),
 	)
 	f.Api.AssertIsEqual(f.Api.Select(
 		is_nan,
@@ -416,8 +986,8 @@ func (f *Context) AssertIsEqualOrCustomULP32(x, y FloatVar, ulp float32) {
 // Enforce the equality between two numbers, relaxed to checking ULP <X
 func (f *Context) AssertIsEqualOrCustomULP64(x, y FloatVar, ulp float64) {
 	is_nan := f.Api.Or(
-		f.Api.And(x.IsAbnormal, f.Api.IsZero(x.Mantissa)),
-		f.Api.And(y.IsAbnormal, f.Api.IsZero(y.Mantissa)),
+		f.IsNaN(x),
+		f.IsNaN(y),
 	)
 	f.Api.AssertIsEqual(f.Api.Select(
 		is_nan,
@@ -444,8 +1014,64 @@ func (f *Context) AssertIsEqualOrCustomULP64(x, y FloatVar, ulp float64) {
 	f.Api.AssertIsEqual(f.Api.Or(condOne, condTwo), 1)
 }
 
+// Return whether the distance between `x` and `y` is at most `n` ULPs, i.e., there are at most `n - 1`
+// representable numbers strictly between `x` and `y`.
+// The distance is measured on the ordered-integer encoding, where a number is mapped to its encoded magnitude
+// (the encoded form without the sign bit) if it is non-negative, and to the negated encoded magnitude
+// otherwise. Therefore, the distance is well-defined across exponent boundaries and signs, +0 and -0 have
+// distance 0, and infinity is 1 ULP away from the largest finite number with the same sign.
+// As in `Self::assert_is_equal`, two NaNs are considered equal, while NaN is not within any distance of
+// a non-NaN number.
+func (f *Context) UlpDistanceLe(x, y FloatVar, n uint) frontend.Variable {
+	if result, ok := f.foldUlpDistanceLe(x, y, n); ok {
+		return result
+	}
+
+	// Compute the encoded magnitude of `v`, which is `(exponent - E_NORMAL_MIN) * 2^M + mantissa` for normal
+	// numbers and infinity, and `mantissa >> (E_NORMAL_MIN - exponent)` for subnormal numbers and zero.
+	// Both cases can be unified as `(exponent + k - E_NORMAL_MIN) * 2^M + mantissa / 2^k`, where
+	// `k = max(E_NORMAL_MIN - exponent, 0)` is at most `M + 1`, and the division is exact since the mantissa
+	// of a subnormal number is obtained by left shifting the encoded mantissa by `k - 1` bits.
+	encode := func(v FloatVar) frontend.Variable {
+		k := f.Gadget.Max(f.Api.Sub(f.E_NORMAL_MIN, v.Exponent), big.NewInt(0), f.E+1)
+		magnitude := f.Api.Add(
+			f.Api.Mul(f.Api.Sub(f.Api.Add(v.Exponent, k), f.E_NORMAL_MIN), new(big.Int).Lsh(big.NewInt(1), f.M)),
+			f.Api.Div(v.Mantissa, f.Gadget.QueryPowerOf2(k)),
+		)
+		return f.Api.Select(v.Sign, f.Api.Neg(magnitude), magnitude)
+	}
+
+	// The encoded magnitudes have at most `E + M` bits, so their difference has at most `E + M + 1` bits.
+	length := f.E + f.M + 2
+	if l := uint(big.NewInt(int64(n)).BitLen()) + 1; l > length {
+		length = l
+	}
+	distance, _ := f.Gadget.Abs(f.Api.Sub(encode(x), encode(y)), length)
+	is_le := f.Gadget.IsPositive(f.Api.Sub(n, distance), length)
+
+	x_is_nan := f.IsNaN(x)
+	y_is_nan := f.IsNaN(y)
+	return f.Api.Select(
+		f.Api.Or(x_is_nan, y_is_nan),
+		f.Api.And(x_is_nan, y_is_nan),
+		is_le,
+	)
+}
+
 // Add two numbers.
 func (f *Context) Add(x, y FloatVar) FloatVar {
+	if result, ok := f.foldAdd(x, y); ok {
+		return result
+	}
+
+	// Adding a constant zero is exact, so we only need to fix the sign of the result.
+	if c, ok := f.constantComponents(y); ok && c[2].Sign() == 0 && c[3].Sign() == 0 {
+		return f.addZero(x, c[0])
+	}
+	if c, ok := f.constantComponents(x); ok && c[2].Sign() == 0 && c[3].Sign() == 0 {
+		return f.addZero(y, c[0])
+	}
+
 	// Compute `y.exponent - x.exponent`'s absolute value and sign.
 	// Since `delta` is the absolute value, `delta >= 0`.
 	delta, ex_le_ey := f.Gadget.Abs(f.Api.Sub(y.Exponent, x.Exponent), f.E+1)
@@ -570,13 +1196,18 @@ func (f *Context) Add(x, y FloatVar) FloatVar {
 	// `mantissa_ge_0` can be directly used to determine the sign of the result, except for the case
 	// `-0 + -0`. Therefore, we first check whether the signs of `x` and `y` are the same. If so,
 	// we use `x`'s sign as the sign of the result. Otherwise, we use the negation of `mantissa_ge_0`.
+	// When rounding toward negative infinity, the exact zero sum of two numbers with different signs
+	// is `-0` instead of `+0`.
+	if f.RoundingMode == RoundTowardNegative {
+		mantissa_lt_0 = f.Api.Or(mantissa_lt_0, mantissa_is_zero)
+	}
 	sign := f.Api.Select(
 		f.Gadget.IsEq(x.Sign, y.Sign),
 		x.Sign,
 		mantissa_lt_0,
 	)
 
-	mantissa = f.round(
+	mantissa, is_inexact := f.round(
 		mantissa,
 		mantissa_bit_length,
 		// If the result is subnormal, we need to clear the lowest `E_NORMAL_MIN - exponent` bits of rounded
@@ -592,19 +1223,40 @@ func (f *Context) Add(x, y FloatVar) FloatVar {
 		big.NewInt(0),
 		0,
 		1,
+		sign,
 	)
+	// The result of adding two normal numbers may still be subnormal, e.g., in `x - y` where `x` and `y` are
+	// close, so we need to flush it if `FlushSubnormals` is set.
+	// Such a result is exact, and hence the carry of rounding never makes a tiny result normal.
+	var is_tiny frontend.Variable
+	if f.FlushSubnormals {
+		mantissa, is_inexact, is_tiny = f.flushSubnormal(mantissa, exponent, is_inexact, false)
+		mantissa_is_zero = f.Api.Or(mantissa_is_zero, is_tiny)
+	}
 
-	mantissa, exponent, is_abnormal := f.fixOverflow(
+	mantissa, exponent, is_abnormal, is_overflow := f.fixOverflow(
 		mantissa,
 		mantissa_is_zero,
 		exponent,
 		f.Api.Or(x.IsAbnormal, y.IsAbnormal),
+		sign,
 	)
 
+	if f.FiniteOnly {
+		result := FloatVar{
+			Sign:       sign,
+			Exponent:   exponent,
+			Mantissa:   mantissa,
+			IsAbnormal: is_abnormal,
+		}
+		f.raiseFlags([]FloatVar{x, y}, result, nil, is_inexact, is_tiny, is_overflow)
+		return result
+	}
+
 	y_is_not_abnormal := f.Api.Sub(big.NewInt(1), y.IsAbnormal)
 	f.Api.Compiler().MarkBoolean(y_is_not_abnormal)
 
-	return FloatVar{
+	result := FloatVar{
 		Sign:     sign,
 		Exponent: exponent,
 		// Rule of addition:
@@ -639,6 +1291,34 @@ func (f *Context) Add(x, y FloatVar) FloatVar {
 		),
 		IsAbnormal: is_abnormal,
 	}
+	f.raiseFlags([]FloatVar{x, y}, result, nil, is_inexact, is_tiny, is_overflow)
+	return result
+}
+
+// Add a constant zero with sign `zero_sign` to `x`.
+// The result is `x` itself unless `x` is also zero, in which case the sign of the result follows the rules of
+// `Self::add`, i.e., the sum of two zeros with different signs is `+0`, or `-0` when rounding toward negative
+// infinity. No exception is raised.
+func (f *Context) addZero(x FloatVar, zero_sign *big.Int) FloatVar {
+	sign := x.Sign
+	if (zero_sign.Sign() != 0) == (f.RoundingMode == RoundTowardNegative) {
+		x_is_zero := f.Api.IsZero(x.Mantissa)
+		if zero_sign.Sign() != 0 {
+			// `x + -0` is `-0` for both zeros when rounding toward negative infinity.
+			sign = f.Api.Or(x.Sign, x_is_zero)
+		} else {
+			// `x + +0` is `+0` for both zeros in the other modes.
+			x_is_not_zero := f.Api.Sub(big.NewInt(1), x_is_zero)
+			f.Api.Compiler().MarkBoolean(x_is_not_zero)
+			sign = f.Api.And(x.Sign, x_is_not_zero)
+		}
+	}
+	return FloatVar{
+		Sign:       sign,
+		Exponent:   x.Exponent,
+		Mantissa:   x.Mantissa,
+		IsAbnormal: x.IsAbnormal,
+	}
 }
 
 // Compute the absolute value of the number.
@@ -663,6 +1343,17 @@ func (f *Context) Neg(x FloatVar) FloatVar {
 	}
 }
 
+// Return a number with the magnitude of `x` and the sign of `y`, as specified by the `copySign` operation in
+// IEEE 754, which is also applied to NaN and raises no exception.
+func (f *Context) Copysign(x, y FloatVar) FloatVar {
+	return FloatVar{
+		Sign:       y.Sign,
+		Exponent:   x.Exponent,
+		Mantissa:   x.Mantissa,
+		IsAbnormal: x.IsAbnormal,
+	}
+}
+
 // Subtract two numbers.
 // This is implemented by negating `y` and adding it to `x`.
 func (f *Context) Sub(x, y FloatVar) FloatVar {
@@ -671,6 +1362,17 @@ func (f *Context) Sub(x, y FloatVar) FloatVar {
 
 // Multiply two numbers.
 func (f *Context) Mul(x, y FloatVar) FloatVar {
+	if result, ok := f.foldMul(x, y); ok {
+		return result
+	}
+
+	if c, ok := f.constantComponents(y); ok && c[3].Sign() == 0 {
+		return f.mulConstant(x, y, c[0], c[1], c[2])
+	}
+	if c, ok := f.constantComponents(x); ok && c[3].Sign() == 0 {
+		return f.mulConstant(y, x, c[0], c[1], c[2])
+	}
+
 	// The result is negative if and only if the signs of x and y are different.
 	sign := f.Api.Xor(x.Sign, y.Sign)
 	mantissa := f.Api.Mul(x.Mantissa, y.Mantissa)
@@ -711,60 +1413,145 @@ func (f *Context) Mul(x, y FloatVar) FloatVar {
 	// carries, i.e., if the MSB of the mantissa is 1.
 	exponent := f.Api.Add(f.Api.Add(x.Exponent, y.Exponent), mantissa_msb)
 
-	shift_max := f.M + 2
-	mantissa = f.round(
-		mantissa,
-		mantissa_bit_length,
-		// If `exponent >= E_NORMAL_MIN`, i.e., the result is normal, we don't need to clear the lower bits.
-		// Otherwise, we need to clear `min(E_NORMAL_MIN - exponent, shift_max)` bits of the rounded mantissa.
-		f.Gadget.Max(
-			f.Gadget.Min(
-				f.Api.Sub(f.E_NORMAL_MIN, exponent),
-				big.NewInt(int64(shift_max)),
-				f.E+1,
+	mantissa, exponent, is_inexact, is_tiny := f.roundSubnormal(mantissa, mantissa_bit_length, exponent, f.M+2, 1, sign)
+
+	return f.mulResult(x, y, f.Api.Or(x.IsAbnormal, y.IsAbnormal), sign, mantissa, exponent, is_inexact, is_tiny)
+}
+
+// Multiply `x` by the constant `y`, which is finite and whose components are `c_sign`, `c_exponent` and
+// `c_mantissa`.
+// Since `c_mantissa` is known, the product of the mantissas is a linear combination, and its MSB can be found
+// by comparing `x.mantissa` with a precomputed threshold. Moreover, the trailing zeros of `c_mantissa` are
+// dropped, so that the product to be rounded is shorter.
+// If `y` is a power of two that is at least 1, the result is exact and only the exponent changes.
+func (f *Context) mulConstant(x, y FloatVar, c_sign, c_exponent, c_mantissa *big.Int) FloatVar {
+	sign := x.Sign
+	if c_sign.Sign() != 0 {
+		sign = f.Api.Sub(big.NewInt(1), x.Sign)
+		f.Api.Compiler().MarkBoolean(sign)
+	}
+
+	if c_mantissa.Sign() == 0 {
+		// `x * 0` is zero, unless `x` is NaN or infinity, in which case the result is NaN.
+		result := FloatVar{
+			Sign:       sign,
+			Exponent:   f.Api.Select(x.IsAbnormal, f.E_MAX, f.E_MIN),
+			Mantissa:   big.NewInt(0),
+			IsAbnormal: x.IsAbnormal,
+		}
+		f.raiseFlags([]FloatVar{x, y}, result, nil, nil, nil, nil)
+		return result
+	}
+
+	// Write `c_mantissa` as `c * 2^tz`, where `c` is odd and has `b` bits.
+	tz := c_mantissa.TrailingZeroBits()
+	c := new(big.Int).Rsh(c_mantissa, tz)
+	b := uint(c.BitLen())
+
+	var mantissa, exponent, is_inexact, is_tiny frontend.Variable
+	if b == 1 && c_exponent.Sign() >= 0 {
+		// `y` is `2^c_exponent`, and the product is exact. This holds even if `x` is subnormal, because the
+		// lower bits of `x.mantissa` that should be 0 remain 0 when the exponent increases.
+		mantissa = x.Mantissa
+		exponent = f.Api.Add(x.Exponent, c_exponent)
+	} else {
+		// `x.mantissa * c` is in the range `[2^(M + b - 1), 2^(M + b + 1))` unless it is 0, and hence requires
+		// `M + b + 1` bits to represent.
+		mantissa = f.Api.Mul(x.Mantissa, c)
+		mantissa_bit_length := f.M + b + 1
+		// The MSB of the product is 1 if and only if `x.mantissa >= ceil(2^(M + b) / c)`, which is never the
+		// case if `c` is 1.
+		var mantissa_msb frontend.Variable = big.NewInt(0)
+		if b > 1 {
+			threshold := new(big.Int).Lsh(big.NewInt(1), f.M+b)
+			threshold.Add(threshold, new(big.Int).Sub(c, big.NewInt(1)))
+			threshold.Div(threshold, c)
+			mantissa_msb = f.Gadget.IsPositive(f.Api.Sub(x.Mantissa, threshold), f.M+2)
+		}
+		// As in `Self::mul`, double the product if the MSB is 0, and increment the exponent otherwise.
+		// The product `x.mantissa * c_mantissa` is in units of `2^(x.exponent + c_exponent - 2M)`, hence the
+		// product `x.mantissa * c` is in units of `2^(x.exponent + c_exponent - 2M + tz)`, where `tz = M + 1 - b`.
+		mantissa = f.Api.Add(
+			mantissa,
+			f.Api.Select(
+				mantissa_msb,
+				big.NewInt(0),
+				mantissa,
 			),
-			big.NewInt(0),
-			f.E+1,
-		),
-		shift_max,
-		1,
-	)
+		)
+		exponent = f.Api.Add(f.Api.Add(x.Exponent, c_exponent), mantissa_msb)
+		mantissa, exponent, is_inexact, is_tiny = f.roundSubnormal(mantissa, mantissa_bit_length, exponent, f.M+2, 1, sign)
+	}
+
+	return f.mulResult(x, y, x.IsAbnormal, sign, mantissa, exponent, is_inexact, is_tiny)
+}
 
+// Fix the overflow of the rounded product of `x` and `y` and handle the abnormal cases, where the arguments
+// are the intermediate values of `Self::mul`.
+func (f *Context) mulResult(
+	x, y FloatVar,
+	input_is_abnormal frontend.Variable,
+	sign frontend.Variable,
+	mantissa frontend.Variable,
+	exponent frontend.Variable,
+	is_inexact frontend.Variable,
+	is_tiny frontend.Variable,
+) FloatVar {
 	mantissa_is_zero := f.Api.IsZero(mantissa)
-	input_is_abnormal := f.Api.Or(x.IsAbnormal, y.IsAbnormal)
-	mantissa, exponent, is_abnormal := f.fixOverflow(
+	mantissa, exponent, is_abnormal, is_overflow := f.fixOverflow(
 		mantissa,
 		mantissa_is_zero,
 		exponent,
 		input_is_abnormal,
+		sign,
 	)
 
-	return FloatVar{
-		Sign:     sign,
-		Exponent: exponent,
+	if !f.FiniteOnly {
 		// If the mantissa before fixing overflow is zero, we reset the final mantissa to 0,
 		// as `Self::fix_overflow` incorrectly sets NaN's mantissa to infinity's mantissa.
-		Mantissa: f.Api.Select(
+		mantissa = f.Api.Select(
 			mantissa_is_zero,
 			big.NewInt(0),
 			mantissa,
-		),
+		)
+	}
+	result := FloatVar{
+		Sign:       sign,
+		Exponent:   exponent,
+		Mantissa:   mantissa,
 		IsAbnormal: is_abnormal,
 	}
+	f.raiseFlags([]FloatVar{x, y}, result, nil, is_inexact, is_tiny, is_overflow)
+	return result
 }
 
 // Divide two numbers.
 func (f *Context) Div(x, y FloatVar) FloatVar {
+	if result, ok := f.foldDiv(x, y); ok {
+		return result
+	}
+
+	if c, ok := f.constantComponents(y); ok && c[2].Sign() != 0 && c[3].Sign() == 0 {
+		return f.divConstant(x, y, c[0], c[1], c[2])
+	}
+
 	// The result is negative if and only if the signs of `x` and `y` are different.
 	sign := f.Api.Xor(x.Sign, y.Sign)
-	y_is_zero := f.Api.IsZero(y.Mantissa)
-
-	// If the divisor is 0, we increase it to `2^M`, because we cannot represent an infinite value in circuit.
-	y_mantissa := f.Api.Select(
-		y_is_zero,
-		new(big.Int).Lsh(big.NewInt(1), f.M),
-		y.Mantissa,
-	)
+	var y_is_zero, y_mantissa frontend.Variable
+	if f.FiniteOnly {
+		// Enforce that the divisor is nonzero, as the result would be infinity or NaN otherwise.
+		f.Api.AssertIsDifferent(y.Mantissa, 0)
+		y_is_zero = big.NewInt(0)
+		y_mantissa = y.Mantissa
+	} else {
+		y_is_zero = f.Api.IsZero(y.Mantissa)
+		// If the divisor is 0, we increase it to `2^M`, because we cannot represent an infinite value in circuit.
+		y_mantissa = f.Api.Select(
+			y_is_zero,
+			new(big.Int).Lsh(big.NewInt(1), f.M),
+			y.Mantissa,
+		)
+	}
 	// The result's mantissa is the quotient of `x.mantissa << (M + 2)` and `y_mantissa`.
 	// Since both `x.mantissa` and `y_mantissa` are in the range `[2^M, 2^(M + 1))`, the quotient is in the range
 	// `(2^(M + 1), 2^(M + 3))` and requires `M + 3` bits to represent.
@@ -811,53 +1598,120 @@ func (f *Context) Div(x, y FloatVar) FloatVar {
 	// borrows, i.e., if the MSB of the mantissa is 0.
 	exponent := f.Api.Sub(f.Api.Sub(x.Exponent, y.Exponent), flipped_mantissa_msb)
 
-	shift_max := f.M + 2
-	mantissa = f.round(
-		mantissa,
-		mantissa_bit_length,
-		// If `exponent >= E_NORMAL_MIN`, i.e., the result is normal, we don't need to clear the lower bits.
-		// Otherwise, we need to clear `min(E_NORMAL_MIN - exponent, shift_max)` bits of the rounded mantissa.
-		f.Gadget.Max(
-			f.Gadget.Min(
-				f.Api.Sub(f.E_NORMAL_MIN, exponent),
-				big.NewInt(int64(shift_max)),
-				f.E+1,
-			),
-			big.NewInt(0),
-			f.E+1,
-		),
-		shift_max,
-		f.Api.IsZero(remainder),
-	)
+	mantissa, exponent, is_inexact, is_tiny := f.roundSubnormal(mantissa, mantissa_bit_length, exponent, f.M+2, f.Api.IsZero(remainder), sign)
 
 	// If `y` is infinity, the result is zero.
 	// If `y` is NaN, the result is NaN.
 	// Since both zero and NaN have mantissa 0, we can combine both cases and set the mantissa to 0
 	// when `y` is abnormal.
-	mantissa_is_zero := f.Api.Or(f.Api.IsZero(mantissa), y.IsAbnormal)
+	mantissa_is_zero := f.Api.IsZero(mantissa)
+	if !f.FiniteOnly {
+		mantissa_is_zero = f.Api.Or(mantissa_is_zero, y.IsAbnormal)
+	}
 
-	mantissa, exponent, is_abnormal := f.fixOverflow(
+	mantissa, exponent, is_abnormal, is_overflow := f.fixOverflow(
 		mantissa,
 		mantissa_is_zero,
 		exponent,
 		f.Api.Or(x.IsAbnormal, y_is_zero),
+		sign,
 	)
 
-	return FloatVar{
-		Sign:     sign,
-		Exponent: exponent,
+	if !f.FiniteOnly {
 		// If the mantissa before fixing overflow is zero, we reset the final mantissa to 0,
 		// as `Self::fix_overflow` incorrectly sets NaN's mantissa to infinity's mantissa.
-		Mantissa: f.Api.Select(
+		mantissa = f.Api.Select(
 			mantissa_is_zero,
 			big.NewInt(0),
 			mantissa,
-		),
-		IsAbnormal: is_abnormal,
+		)
 	}
+	result := FloatVar{
+		Sign:       sign,
+		Exponent:   exponent,
+		Mantissa:   mantissa,
+		IsAbnormal: is_abnormal,
+	}
+
+	// Dividing a finite nonzero number by zero raises the division-by-zero exception.
+	var is_div_by_zero frontend.Variable
+	if f.Flags != nil && !f.FiniteOnly {
+		x_is_finite_nonzero := f.Api.Sub(big.NewInt(1), f.Api.Or(x.IsAbnormal, f.Api.IsZero(x.Mantissa)))
+		y_is_finite := f.Api.Sub(big.NewInt(1), y.IsAbnormal)
+		f.Api.Compiler().MarkBoolean(x_is_finite_nonzero)
+		f.Api.Compiler().MarkBoolean(y_is_finite)
+		is_div_by_zero = f.Api.And(f.Api.And(y_is_zero, y_is_finite), x_is_finite_nonzero)
+	}
+	f.raiseFlags([]FloatVar{x, y}, result, is_div_by_zero, is_inexact, is_tiny, is_overflow)
+	return result
+}
+
+// Divide `x` by the constant `y`, which is finite and nonzero, and whose components are `c_sign`, `c_exponent`
+// and `c_mantissa`.
+// If `y` is a power of two, the division is an exact multiplication by the reciprocal `2^(-c_exponent)`.
+// Otherwise, the quotient is verified by multiplying it with the constant divisor, which is a linear
+// combination, and the divisor is shortened by dropping the trailing zeros of `c_mantissa`, which makes the
+// range checks on the remainder cheaper. Also, we don't need to handle a zero or abnormal divisor.
+func (f *Context) divConstant(x, y FloatVar, c_sign, c_exponent, c_mantissa *big.Int) FloatVar {
+	if c_mantissa.Cmp(new(big.Int).Lsh(big.NewInt(1), f.M)) == 0 {
+		return f.mulConstant(x, y, c_sign, new(big.Int).Neg(c_exponent), c_mantissa)
+	}
+
+	sign := x.Sign
+	if c_sign.Sign() != 0 {
+		sign = f.Api.Sub(big.NewInt(1), x.Sign)
+		f.Api.Compiler().MarkBoolean(sign)
+	}
+	// Write `c_mantissa` as `c * 2^tz`, where `c` is odd and has `b` bits.
+	// Then the quotient `(x.mantissa << (M + 2)) / c_mantissa` in `Self::div` is equal to
+	// `(x.mantissa << (M + 2 - tz)) / c`, and the remainder is less than `c`.
+	tz := c_mantissa.TrailingZeroBits()
+	c := new(big.Int).Rsh(c_mantissa, tz)
+	b := uint(c.BitLen())
+	mantissa_bit_length := (f.M + 2) + 1
+	outputs, err := f.Api.Compiler().NewHint(hint.DivHint, 1, x.Mantissa, c, big.NewInt(int64(f.M+2-tz)))
+	if err != nil {
+		panic(err)
+	}
+	mantissa := outputs[0]
+	outputs, err = f.Api.Compiler().NewHint(hint.NthBitHint, 1, mantissa, big.NewInt(int64(f.M+2)))
+	if err != nil {
+		panic(err)
+	}
+	mantissa_msb := outputs[0]
+	f.Api.AssertIsBoolean(mantissa_msb)
+	flipped_mantissa_msb := f.Api.Sub(big.NewInt(1), mantissa_msb)
+	f.Api.Compiler().MarkBoolean(flipped_mantissa_msb)
+	// Compute the remainder and enforce that `0 <= remainder < c`.
+	remainder := f.Api.Sub(f.Api.Mul(x.Mantissa, new(big.Int).Lsh(big.NewInt(1), f.M+2-tz)), f.Api.Mul(mantissa, c))
+	f.Gadget.AssertBitLength(remainder, b, gadget.Loose)
+	f.Gadget.AssertBitLength(f.Api.Sub(new(big.Int).Sub(c, big.NewInt(1)), remainder), b, gadget.Loose)
+	// Enforce that `mantissa_msb` is indeed the MSB of the quotient, which also bounds the quotient so that the
+	// relation between the quotient and the remainder holds over the integers (see `Self::div`).
+	f.Gadget.AssertBitLength(f.Api.Sub(mantissa, f.Api.Mul(mantissa_msb, new(big.Int).Lsh(big.NewInt(1), mantissa_bit_length-1))), mantissa_bit_length-1, gadget.TightForUnknownRange)
+
+	mantissa = f.Api.Add(
+		mantissa,
+		f.Api.Select(
+			mantissa_msb,
+			big.NewInt(0),
+			mantissa,
+		),
+	)
+	exponent := f.Api.Sub(f.Api.Sub(x.Exponent, c_exponent), flipped_mantissa_msb)
+
+	mantissa, exponent, is_inexact, is_tiny := f.roundSubnormal(mantissa, mantissa_bit_length, exponent, f.M+2, f.Api.IsZero(remainder), sign)
+
+	// Since `y` is finite and nonzero, the result is abnormal if and only if `x` is abnormal or the result
+	// overflows, and the mantissa is 0 if and only if `x` is 0 or NaN, or the result underflows.
+	return f.mulResult(x, y, x.IsAbnormal, sign, mantissa, exponent, is_inexact, is_tiny)
 }
 
 func (f *Context) Sqrt(x FloatVar) FloatVar {
+	if result, ok := f.foldSqrt(x); ok {
+		return result
+	}
+
 	delta := f.E_MIN
 	if delta.Bit(0) == 1 {
 		delta = new(big.Int).Sub(delta, big.NewInt(1))
@@ -914,7 +1768,7 @@ func (f *Context) Sqrt(x FloatVar) FloatVar {
 	n_is_not_zero := f.Api.Sub(big.NewInt(1), n_is_zero)
 	f.Api.Compiler().MarkBoolean(n_is_not_zero)
 
-	mantissa := f.round(
+	mantissa, is_inexact := f.round(
 		n,
 		mantissa_bit_length,
 		// The result is always normal or 0, as `exponent = (x.exponent >> 1) - shift > E_NORMAL_MIN`.
@@ -922,7 +1776,36 @@ func (f *Context) Sqrt(x FloatVar) FloatVar {
 		big.NewInt(0),
 		0,
 		f.Api.IsZero(r),
+		x.Sign,
 	)
+	if f.RoundingMode == RoundTowardPositive {
+		// When rounding up, the mantissa may overflow, e.g., `sqrt(4 - 2^(1 - M))` is rounded to 2.
+		// In this case, we right shift the mantissa by 1 and increment the exponent.
+		mantissa_overflow := f.Gadget.IsEq(mantissa, new(big.Int).Lsh(big.NewInt(1), f.M+1))
+		mantissa = f.Api.Select(
+			mantissa_overflow,
+			new(big.Int).Lsh(big.NewInt(1), f.M),
+			mantissa,
+		)
+		exponent = f.Api.Add(exponent, mantissa_overflow)
+	}
+
+	if f.FiniteOnly {
+		// Enforce that `x` is not negative unless `x` is `-0`, as the result would be NaN otherwise.
+		f.Api.AssertIsEqual(f.Api.Mul(x.Sign, n), 0)
+		result := FloatVar{
+			Sign: x.Sign,
+			Exponent: f.Api.Select(
+				n_is_zero,
+				f.E_MIN,
+				exponent,
+			),
+			Mantissa:   mantissa,
+			IsAbnormal: big.NewInt(0),
+		}
+		f.raiseFlags([]FloatVar{x}, result, nil, is_inexact, nil, nil)
+		return result
+	}
 
 	// If `x` is negative and `x` is not `-0`, the result is NaN.
 	// If `x` is NaN, the result is NaN.
@@ -933,39 +1816,1308 @@ func (f *Context) Sqrt(x FloatVar) FloatVar {
 		x.IsAbnormal,
 	)
 
-	return FloatVar{
-		Sign: x.Sign, // Edge case: sqrt(-0.0) = -0.0
-		Exponent: f.Api.Select(
-			is_abnormal,
-			// If the result is abnormal, we set the exponent to infinity/NaN's exponent.
-			f.E_MAX,
-			f.Api.Select(
-				n_is_zero,
-				// If the result is 0, we set the exponent to 0's exponent.
-				f.E_MIN,
-				// Otherwise, return the original exponent.
-				exponent,
-			),
-		),
-		Mantissa: f.Api.Select(
-			x.Sign,
-			// If `x` is negative, we set the mantissa to NaN's mantissa.
+	result := FloatVar{
+		Sign: x.Sign, // Edge case: sqrt(-0.0) = -0.0
+		Exponent: f.Api.Select(
+			is_abnormal,
+			// If the result is abnormal, we set the exponent to infinity/NaN's exponent.
+			f.E_MAX,
+			f.Api.Select(
+				n_is_zero,
+				// If the result is 0, we set the exponent to 0's exponent.
+				f.E_MIN,
+				// Otherwise, return the original exponent.
+				exponent,
+			),
+		),
+		Mantissa: f.Api.Select(
+			x.Sign,
+			// If `x` is negative, we set the mantissa to NaN's mantissa.
+			big.NewInt(0),
+			mantissa,
+		),
+		IsAbnormal: is_abnormal,
+	}
+	f.raiseFlags([]FloatVar{x}, result, nil, is_inexact, nil, nil)
+	return result
+}
+
+// Compute the reciprocal square root `1 / sqrt(x)` with a single rounding, as specified by the `rSqrt`
+// operation in IEEE 754, i.e., `rsqrt(±0) = ±infinity` (which divides by zero), `rsqrt(+infinity) = +0`, and
+// the result of any other negative `x` is NaN.
+// As in `Self::sqrt`, the integer root is provided as a hint and verified by squaring it, but the dividend
+// `2^(2K)` is fixed and the mantissa of `x` becomes the divisor, so the result is never subnormal and never
+// overflows.
+// This panics for wide formats such as binary128, where the squared root of about `3M` bits does not fit in
+// the native field.
+func (f *Context) Rsqrt(x FloatVar) FloatVar {
+	// Write `x` as `m' * 2^t'` with an even `t'` and `m'` in the range `[2^(2h), 2^(2h + 2))`, where `2h` is
+	// `M` rounded up to an even number. Then `1 / sqrt(m')` is in the range `(2^(-h - 1), 2^(-h)]`, and
+	// `n = floor(2^K / sqrt(m'))` is in the range `[2^(M + 2), 2^(M + 3)]` for `K = M + 3 + h`.
+	c := f.M % 2
+	h := (f.M + c) / 2
+	K := f.M + 3 + h
+	// `(n + 1)^2 * m'` has at most `3M + c + 10` bits, which should not overflow the native field.
+	if 3*f.M+c+10 >= uint(f.Api.Compiler().Field().BitLen()) {
+		panic("rsqrt is not supported for this format, as its intermediate values overflow the native field")
+	}
+	if result, ok := f.foldRsqrt(x); ok {
+		return result
+	}
+
+	// Get the LSB of `t = x.exponent - M - c` and provide it as a hint to the circuit, where `delta` is an
+	// even lower bound of `t` that makes the operand of the hint non-negative.
+	t := f.Api.Sub(x.Exponent, big.NewInt(int64(f.M+c)))
+	delta := new(big.Int).Sub(f.E_MIN, big.NewInt(int64(f.M+c)))
+	if delta.Bit(0) == 1 {
+		delta.Sub(delta, big.NewInt(1))
+	}
+	outputs, err := f.Api.Compiler().NewHint(hint.NthBitHint, 1, f.Api.Sub(t, delta), 0)
+	if err != nil {
+		panic(err)
+	}
+	t_lsb := outputs[0]
+	f.Api.AssertIsBoolean(t_lsb)
+	// Compute `t' / 2 = t >> 1`, and enforce that it is small as in `Self::sqrt`, which ensures that `t_lsb` is
+	// indeed the LSB of `t`.
+	half_t := f.Api.Mul(f.Api.Sub(t, t_lsb), f.Api.Inverse(big.NewInt(2)))
+	f.Gadget.Abs(half_t, f.E)
+
+	mantissa_is_zero := f.Api.IsZero(x.Mantissa)
+	// `m' = x.mantissa * 2^(c + t_lsb)`, which is replaced with `2^(2h)` if `x` is 0 or NaN, so that the
+	// constraints below are satisfiable.
+	m := f.Api.Mul(x.Mantissa, new(big.Int).Lsh(big.NewInt(1), c))
+	m = f.Api.Select(
+		mantissa_is_zero,
+		new(big.Int).Lsh(big.NewInt(1), 2*h),
+		f.Api.Select(t_lsb, f.Api.Add(m, m), m),
+	)
+
+	// Compute `n = floor(sqrt(floor(2^(2K) / m')))`, which is equal to `floor(2^K / sqrt(m'))`, and provide it
+	// as a hint to the circuit.
+	outputs, err = f.Api.Compiler().NewHint(hint.DivHint, 1, big.NewInt(1), m, big.NewInt(int64(2*K)))
+	if err != nil {
+		panic(err)
+	}
+	outputs, err = f.Api.Compiler().NewHint(hint.SqrtHint, 1, outputs[0])
+	if err != nil {
+		panic(err)
+	}
+	n := outputs[0]
+	// Enforce that `n` is small, so that the products below are computed over the integers.
+	f.Gadget.AssertBitLength(n, f.M+4, gadget.Loose)
+
+	// Compute the remainder `r = 2^(2K) - n^2 * m'` and enforce that `n^2 * m' <= 2^(2K) < (n + 1)^2 * m'`.
+	// Both `r` and `(n + 1)^2 * m' - 2^(2K) - 1 = (2n + 1) * m' - r - 1` are less than `(2n + 1) * m'`, which
+	// has at most `2M + c + 7` bits.
+	r := f.Api.Sub(new(big.Int).Lsh(big.NewInt(1), 2*K), f.Api.Mul(n, n, m))
+	f.Gadget.AssertBitLength(r, 2*f.M+c+7, gadget.Loose)
+	f.Gadget.AssertBitLength(f.Api.Sub(f.Api.Mul(f.Api.Add(n, n, big.NewInt(1)), m), r, big.NewInt(1)), 2*f.M+c+7, gadget.Loose)
+
+	// `n` is `2^(M + 3)` if and only if `m' = 2^(2h)`, i.e., the result is an exact power of 2, in which case
+	// we right shift `n` by 1 and increment the exponent.
+	n_is_power_of_2 := f.Gadget.IsEq(n, new(big.Int).Lsh(big.NewInt(1), f.M+3))
+	n = f.Api.Sub(n, f.Api.Mul(n_is_power_of_2, new(big.Int).Lsh(big.NewInt(1), f.M+2)))
+	// `1 / sqrt(x) = 2^(-t' / 2) / sqrt(m') ≈ n * 2^(-t' / 2 - K)`, whose MSB has the exponent
+	// `M + 2 - t' / 2 - K = -1 - h - t' / 2`.
+	exponent := f.Api.Add(f.Api.Sub(new(big.Int).Neg(big.NewInt(int64(1+h))), half_t), n_is_power_of_2)
+
+	mantissa, is_inexact := f.round(n, f.M+3, big.NewInt(0), 0, f.Api.IsZero(r), 0)
+	if f.RoundingMode == RoundTowardPositive {
+		// When rounding up, the mantissa may overflow, e.g., if `x` is slightly larger than a power of 4.
+		// In this case, we right shift the mantissa by 1 and increment the exponent.
+		mantissa_overflow := f.Gadget.IsEq(mantissa, new(big.Int).Lsh(big.NewInt(1), f.M+1))
+		mantissa = f.Api.Select(
+			mantissa_overflow,
+			new(big.Int).Lsh(big.NewInt(1), f.M),
+			mantissa,
+		)
+		exponent = f.Api.Add(exponent, mantissa_overflow)
+	}
+
+	if f.FiniteOnly {
+		// Enforce that `x` is positive, as the result would be infinity or NaN otherwise.
+		f.Api.AssertIsEqual(f.Api.Or(x.Sign, mantissa_is_zero), 0)
+		result := FloatVar{
+			Sign:       0,
+			Exponent:   exponent,
+			Mantissa:   mantissa,
+			IsAbnormal: big.NewInt(0),
+		}
+		f.raiseFlags([]FloatVar{x}, result, nil, is_inexact, nil, nil)
+		return result
+	}
+
+	mantissa_is_not_zero := f.Api.Sub(big.NewInt(1), mantissa_is_zero)
+	f.Api.Compiler().MarkBoolean(mantissa_is_not_zero)
+	is_finite := f.Api.Sub(big.NewInt(1), x.IsAbnormal)
+	f.Api.Compiler().MarkBoolean(is_finite)
+	// If `x` is NaN, or `x` is negative and `x` is not `-0`, the result is NaN.
+	is_nan := f.Api.Or(
+		f.Api.And(x.Sign, mantissa_is_not_zero),
+		f.Api.And(x.IsAbnormal, mantissa_is_zero),
+	)
+	// If `x` is `±0`, the result is `±infinity`.
+	is_div_by_zero := f.Api.And(is_finite, mantissa_is_zero)
+	// If `x` is `+infinity`, the result is `+0`.
+	is_zero := f.Api.And(x.IsAbnormal, mantissa_is_not_zero)
+	is_abnormal := f.Api.Or(is_nan, is_div_by_zero)
+
+	result := FloatVar{
+		// The sign is only meaningful for `rsqrt(-0) = -infinity`, and is 0 for any other non-NaN result.
+		Sign: x.Sign,
+		Exponent: f.Api.Select(
+			is_abnormal,
+			f.E_MAX,
+			f.Api.Select(is_zero, f.E_MIN, exponent),
+		),
+		Mantissa: f.Api.Select(
+			f.Api.Or(is_nan, is_zero),
+			big.NewInt(0),
+			f.Api.Select(is_div_by_zero, new(big.Int).Lsh(big.NewInt(1), f.M), mantissa),
+		),
+		IsAbnormal: is_abnormal,
+	}
+	f.raiseFlags([]FloatVar{x}, result, is_div_by_zero, is_inexact, nil, nil)
+	return result
+}
+
+// Compute the cube root of `x` with a single rounding, as specified by the `rootn(x, 3)` operation in
+// IEEE 754, except that `cbrt(-x) = -cbrt(x)` for all `x`, i.e., the result of a negative `x` is negative, and
+// `±0` and `±infinity` are returned as is.
+// The integer root is provided as a hint and verified by cubing it, and the result is never subnormal and
+// never overflows.
+// This panics for wide formats such as binary128, where the cubed root of about `3M` bits does not fit in
+// the native field.
+func (f *Context) Cbrt(x FloatVar) FloatVar {
+	// Write `x` as `m' * 2^t'` with `t'` divisible by 3 and `m'` in the range `[2^(3h), 2^(3h + 3))`, where
+	// `3h` is `M` rounded up to a multiple of 3. Then `n = floor(cbrt(m' * 2^(3S)))` is in the range
+	// `[2^(M + 2), 2^(M + 3))` for `S = M + 2 - h`.
+	c := (3 - f.M%3) % 3
+	h := (f.M + c) / 3
+	S := f.M + 2 - h
+	// `(n + 1)^3` has at most `3M + 9` bits, which should not overflow the native field.
+	if 3*f.M+10 >= uint(f.Api.Compiler().Field().BitLen()) {
+		panic("cbrt is not supported for this format, as its intermediate values overflow the native field")
+	}
+	if result, ok := f.foldCbrt(x); ok {
+		return result
+	}
+
+	// Compute `t = x.exponent - M - c` modulo 3 by providing `q = floor((t - delta) / 3)` as a hint to the
+	// circuit, where `delta` is a lower bound of `t` divisible by 3, which makes the dividend non-negative.
+	t := f.Api.Sub(x.Exponent, big.NewInt(int64(f.M+c)))
+	delta := new(big.Int).Sub(f.E_MIN, big.NewInt(int64(f.M+c)))
+	delta.Sub(delta, new(big.Int).Mod(delta, big.NewInt(3)))
+	outputs, err := f.Api.Compiler().NewHint(hint.DivHint, 1, f.Api.Sub(t, delta), big.NewInt(3), big.NewInt(0))
+	if err != nil {
+		panic(err)
+	}
+	q := outputs[0]
+	// Enforce that `j = t - delta - 3q` is in `{0, 1, 2}` and `q` is small, which ensures that `q` is indeed the
+	// quotient, as otherwise `q = (t - delta - j) / 3` is not an integer and will not fit in `E` bits.
+	j := f.Api.Sub(f.Api.Sub(t, delta), f.Api.Mul(q, big.NewInt(3)))
+	f.Api.AssertIsEqual(f.Api.Mul(j, f.Api.Sub(j, big.NewInt(1)), f.Api.Sub(j, big.NewInt(2))), 0)
+	f.Gadget.AssertBitLength(q, f.E, gadget.Loose)
+	// `t' / 3 = q + delta / 3`
+	third_t := f.Api.Add(q, new(big.Int).Quo(delta, big.NewInt(3)))
+
+	mantissa_is_zero := f.Api.IsZero(x.Mantissa)
+	// `m' * 2^(3S) = x.mantissa * 2^(c + j + 3S)`, where `2^j = (j^2 + j + 2) / 2` for `j` in `{0, 1, 2}`.
+	// As in `Self::rsqrt`, `m'` is replaced with `2^(3h)` if `x` is 0 or NaN.
+	m := f.Api.Mul(
+		x.Mantissa,
+		f.Api.Add(f.Api.Mul(j, j), j, big.NewInt(2)),
+		new(big.Int).Lsh(big.NewInt(1), c+3*S-1),
+	)
+	m = f.Api.Select(mantissa_is_zero, new(big.Int).Lsh(big.NewInt(1), 3*(h+S)), m)
+
+	// Compute `n = floor(cbrt(m' * 2^(3S)))` and provide it as a hint to the circuit.
+	outputs, err = f.Api.Compiler().NewHint(hint.CbrtHint, 1, m)
+	if err != nil {
+		panic(err)
+	}
+	n := outputs[0]
+	// Enforce that `n` is in the range `[2^(M + 2), 2^(M + 3))`, so that the products below are computed over
+	// the integers.
+	f.Gadget.AssertBitLength(f.Api.Sub(n, new(big.Int).Lsh(big.NewInt(1), f.M+2)), f.M+2, gadget.TightForUnknownRange)
+
+	// Compute the remainder `r = m' * 2^(3S) - n^3` and enforce that `n^3 <= m' * 2^(3S) < (n + 1)^3`.
+	// Both `r` and `(n + 1)^3 - m' * 2^(3S) - 1 = 3n^2 + 3n - r` are less than `3n^2 + 3n + 1`, which has at
+	// most `2M + 8` bits.
+	n_square := f.Api.Mul(n, n)
+	r := f.Api.Sub(m, f.Api.Mul(n_square, n))
+	f.Gadget.AssertBitLength(r, 2*f.M+8, gadget.Loose)
+	f.Gadget.AssertBitLength(f.Api.Sub(f.Api.Mul(f.Api.Add(n_square, n), big.NewInt(3)), r), 2*f.M+8, gadget.Loose)
+
+	// `cbrt(x) = 2^(t' / 3) * cbrt(m') ≈ n * 2^(t' / 3 - S)`, whose MSB has the exponent
+	// `M + 2 + t' / 3 - S = t' / 3 + h`.
+	exponent := f.Api.Add(third_t, big.NewInt(int64(h)))
+
+	mantissa, is_inexact := f.round(n, f.M+3, big.NewInt(0), 0, f.Api.IsZero(r), x.Sign)
+	// Unlike `Self::sqrt`, the mantissa may overflow even when rounding to nearest, e.g., if `x` is slightly
+	// smaller than a power of 8, in which case we right shift the mantissa by 1 and increment the exponent.
+	mantissa_overflow := f.Gadget.IsEq(mantissa, new(big.Int).Lsh(big.NewInt(1), f.M+1))
+	mantissa = f.Api.Select(
+		mantissa_overflow,
+		new(big.Int).Lsh(big.NewInt(1), f.M),
+		mantissa,
+	)
+	exponent = f.Api.Add(exponent, mantissa_overflow)
+
+	// If `x` is 0, infinity or NaN, the result is `x` itself.
+	is_special := f.Api.Or(mantissa_is_zero, x.IsAbnormal)
+	result := FloatVar{
+		Sign:       x.Sign,
+		Exponent:   f.Api.Select(is_special, x.Exponent, exponent),
+		Mantissa:   f.Api.Select(is_special, x.Mantissa, mantissa),
+		IsAbnormal: x.IsAbnormal,
+	}
+	f.raiseFlags([]FloatVar{x}, result, nil, is_inexact, nil, nil)
+	return result
+}
+
+// Compute `sqrt(x^2 + y^2)` with a single rounding, as specified by the `hypot` operation in IEEE 754, i.e.,
+// the squares are computed exactly and never overflow or underflow by themselves, and only the final
+// result may overflow. `hypot(±infinity, y)` is `+infinity` even if `y` is NaN.
+// The smaller operand is aligned to the larger one, but only up to `M + 2` bits, below which it only
+// contributes a sticky bit, and the integer square root of the sum of the squared mantissas is provided as a
+// hint and verified by squaring it.
+// This panics for wide formats such as binary128, where the squared root of about `4M` bits does not fit in
+// the native field.
+func (f *Context) Hypot(x, y FloatVar) FloatVar {
+	// `n` has at most `L = 2M + 4` bits, and `(n + 1)^2` has at most `4M + 9` bits, which should not overflow
+	// the native field.
+	L := 2*f.M + 4
+	if 2*L+1 >= uint(f.Api.Compiler().Field().BitLen()) {
+		panic("hypot is not supported for this format, as its intermediate values overflow the native field")
+	}
+	if result, ok := f.foldHypot(x, y); ok {
+		return result
+	}
+
+	// Swap `x` and `y` so that `|a|` has the larger exponent than `|b|`, and compute the difference `delta`
+	// between their exponents.
+	delta, x_is_larger := f.Gadget.Abs(f.Api.Sub(x.Exponent, y.Exponent), f.E+1)
+	a_mantissa := f.Api.Select(x_is_larger, x.Mantissa, y.Mantissa)
+	b_mantissa := f.Api.Select(x_is_larger, y.Mantissa, x.Mantissa)
+	a_exponent := f.Api.Select(x_is_larger, x.Exponent, y.Exponent)
+
+	// `x^2 + y^2 = (a.mantissa^2 * 2^(2M + 4) + b.mantissa^2 * 2^(2(M + 2 - delta))) * 2^(2(a.exponent - 2M - 2))`
+	// If `delta > M + 2`, we replace `delta` with `M + 2`, which keeps `floor(sqrt(s))` unchanged and the
+	// remainder nonzero for a nonzero `b`. This is because `a.mantissa^2 * 2^(2M + 4)` is a perfect square,
+	// and the aligned `b.mantissa^2` is less than `2^(2M + 2)`, whose contribution to the square root is less
+	// than `2^(2M + 2) / (2 * 2^(2M + 2)) < 1`.
+	delta = f.Gadget.Min(delta, big.NewInt(int64(f.M+2)), f.E+1)
+	two_to_delta := f.Gadget.QueryPowerOf2(f.Api.Sub(big.NewInt(int64(f.M+2)), delta))
+	s := f.Api.Add(
+		f.Api.Mul(a_mantissa, a_mantissa, new(big.Int).Lsh(big.NewInt(1), 2*f.M+4)),
+		f.Api.Mul(b_mantissa, b_mantissa, two_to_delta, two_to_delta),
+	)
+
+	// Compute `n = floor(sqrt(s))` and provide it as a hint to the circuit, together with its MSB.
+	// `n` is in the range `[2^(L - 2), 2^L)` unless both `x` and `y` are 0.
+	outputs, err := f.Api.Compiler().NewHint(hint.SqrtHint, 1, s)
+	if err != nil {
+		panic(err)
+	}
+	n := outputs[0]
+	outputs, err = f.Api.Compiler().NewHint(hint.NthBitHint, 1, n, big.NewInt(int64(L-1)))
+	if err != nil {
+		panic(err)
+	}
+	n_msb := outputs[0]
+	f.Api.AssertIsBoolean(n_msb)
+	// Enforce that `n_msb` is indeed the MSB of `n`, which also bounds `n` to `L` bits, so that `n^2` is
+	// computed over the integers.
+	f.Gadget.AssertBitLength(f.Api.Sub(n, f.Api.Mul(n_msb, new(big.Int).Lsh(big.NewInt(1), L-1))), L-1, gadget.TightForUnknownRange)
+	// Compute the remainder `r = s - n^2` and enforce that `n^2 <= s < (n + 1)^2`.
+	r := f.Api.Sub(s, f.Api.Mul(n, n))
+	f.Gadget.AssertBitLength(r, L+1, gadget.Loose)
+	f.Gadget.AssertBitLength(f.Api.Sub(f.Api.Add(n, n), r), L+1, gadget.Loose)
+
+	// Shift `n` to the left to make the MSB 1, and compute the exponent of the result, which is
+	// `a.exponent - 2M - 2 + L - 1 - (1 - n_msb) = a.exponent + n_msb`.
+	mantissa := f.Api.Add(n, f.Api.Select(n_msb, big.NewInt(0), n))
+	exponent := f.Api.Add(a_exponent, n_msb)
+
+	// The result may be subnormal if both `x` and `y` are, and it may overflow.
+	mantissa, exponent, is_inexact, is_tiny := f.roundSubnormal(mantissa, L, exponent, f.M+2, f.Api.IsZero(r), 0)
+	mantissa_is_zero := f.Api.IsZero(mantissa)
+	mantissa, exponent, is_abnormal, is_overflow := f.fixOverflow(
+		mantissa,
+		mantissa_is_zero,
+		exponent,
+		f.Api.Or(x.IsAbnormal, y.IsAbnormal),
+		0,
+	)
+
+	if !f.FiniteOnly {
+		// If either `x` or `y` is infinity, the result is `+infinity`, which is already the case after
+		// `Self::fix_overflow`. Otherwise, if either `x` or `y` is NaN, the result is NaN.
+		is_nan := f.Api.And(
+			f.Api.Or(f.IsNaN(x), f.IsNaN(y)),
+			f.Api.Sub(big.NewInt(1), f.Api.Or(f.IsInf(x), f.IsInf(y))),
+		)
+		mantissa = f.Api.Select(is_nan, big.NewInt(0), mantissa)
+	}
+	result := FloatVar{
+		Sign:       0,
+		Exponent:   exponent,
+		Mantissa:   mantissa,
+		IsAbnormal: is_abnormal,
+	}
+	f.raiseFlags([]FloatVar{x, y}, result, nil, is_inexact, is_tiny, is_overflow)
+	return result
+}
+
+// Compute `x * y + z` with a single rounding, i.e., the exact value of `x * y + z` is rounded only once,
+// as specified by the `fusedMultiplyAdd` operation in IEEE 754.
+// For wide formats such as binary128, where the aligned sum of `3M + 7` bits does not fit in the native field,
+// the operands are aligned in a narrower window by `Self::align_wide_fma`, which needs the native field to have
+// at least `2M + 8` bits, and this panics otherwise.
+func (f *Context) FMA(x, y, z FloatVar) FloatVar {
+	if 2*f.M+8 > uint(f.Api.Compiler().Field().BitLen()) {
+		panic("FMA is not supported for this format, as its intermediate values overflow the native field")
+	}
+	if result, ok := f.foldFMA(x, y, z); ok {
+		return result
+	}
+
+	// The product of the mantissas is exact and has at most `2M + 2` bits, and `x * y` is equal to
+	// `p * 2^(x.exponent + y.exponent - 2M)`.
+	p := f.Api.Mul(x.Mantissa, y.Mantissa)
+	p_sign := f.Api.Xor(x.Sign, y.Sign)
+	p_is_zero := f.Api.IsZero(p)
+	z_is_zero := f.Api.IsZero(z.Mantissa)
+
+	// `|x * y| < 2^(x.exponent + y.exponent + 2)` and `|z| < 2^(z.exponent + 1)`.
+	// Compute the difference between these two upper bounds, together with whether `z`'s upper bound is
+	// at least `x * y`'s.
+	// The difference is in the range `[E_MIN - 2 * E_MAX - 1, E_MAX - 2 * E_MIN - 1]`, which fits in `E + 2` bits.
+	delta, z_is_larger := f.Gadget.Abs(
+		f.Api.Sub(z.Exponent, f.Api.Add(f.Api.Add(x.Exponent, y.Exponent), big.NewInt(1))),
+		f.E+2,
+	)
+	// If one of the operands is zero, its exponent is meaningless, so we always treat the other one as the
+	// larger one. In particular, when both are zero, it does not matter which one we choose.
+	z_is_larger = f.Api.Select(
+		p_is_zero,
+		big.NewInt(1),
+		f.Api.Select(
+			z_is_zero,
+			big.NewInt(0),
+			z_is_larger,
+		),
+	)
+	f.Api.Compiler().MarkBoolean(z_is_larger)
+
+	// The exponent of the larger upper bound, which corresponds to the MSB of the window in which the operands
+	// are aligned below.
+	exponent := f.Api.Select(
+		z_is_larger,
+		f.Api.Add(z.Exponent, big.NewInt(1)),
+		f.Api.Add(f.Api.Add(x.Exponent, y.Exponent), big.NewInt(2)),
+	)
+	// The aligned sum in `Self::align_fma` has `3M + 7` bits, which should not overflow the native field even
+	// when negated. This rules out wide formats such as binary128 over the usual 254-bit fields.
+	var mantissa, s_lt_0, s_is_zero frontend.Variable
+	if 3*f.M+8 < uint(f.Api.Compiler().Field().BitLen()) {
+		mantissa, exponent, s_lt_0, s_is_zero = f.alignFMA(p, p_sign, z, delta, z_is_larger, exponent)
+	} else {
+		mantissa, exponent, s_lt_0, s_is_zero = f.alignWideFMA(p, p_sign, z, delta, z_is_larger, exponent)
+	}
+	mantissa_bit_length := f.M + 4
+
+	// Similar to `Self::add`, the sign of the result is the sign of `s`, except for the case where the signs
+	// of `x * y` and `z` are the same, and the exact zero result is `-0` when rounding toward negative infinity.
+	if f.RoundingMode == RoundTowardNegative {
+		s_lt_0 = f.Api.Or(s_lt_0, s_is_zero)
+	}
+	sign := f.Api.Select(
+		f.Gadget.IsEq(p_sign, z.Sign),
+		p_sign,
+		s_lt_0,
+	)
+
+	mantissa, exponent, is_inexact, is_tiny := f.roundSubnormal(mantissa, mantissa_bit_length, exponent, f.M+2, 1, sign)
+
+	p_is_abnormal := f.Api.Or(x.IsAbnormal, y.IsAbnormal)
+	input_is_abnormal := f.Api.Or(p_is_abnormal, z.IsAbnormal)
+	mantissa, exponent, is_abnormal, is_overflow := f.fixOverflow(
+		mantissa,
+		f.Api.IsZero(mantissa),
+		exponent,
+		input_is_abnormal,
+		sign,
+	)
+
+	// `x * y` is NaN if `x` or `y` is NaN, or if one of them is infinity and the other is 0. In both cases,
+	// one of `x` and `y` is abnormal, and one of the mantissas is 0, i.e., `p` is 0.
+	// The result is NaN if `x * y` is NaN, `z` is NaN, or `x * y` and `z` are infinities with different signs.
+	is_nan := f.Api.Or(
+		f.Api.And(p_is_abnormal, p_is_zero),
+		f.Api.Or(
+			f.Api.And(z.IsAbnormal, z_is_zero),
+			f.Api.And(f.Api.And(p_is_abnormal, z.IsAbnormal), f.Api.Xor(p_sign, z.Sign)),
+		),
+	)
+
+	result := FloatVar{
+		Sign: f.Api.Select(
+			input_is_abnormal,
+			// If the result is infinity, its sign is the sign of the infinite operand.
+			f.Api.Select(
+				p_is_abnormal,
+				p_sign,
+				z.Sign,
+			),
+			sign,
+		),
+		Exponent: exponent,
+		Mantissa: f.Api.Select(
+			is_nan,
+			big.NewInt(0),
+			mantissa,
+		),
+		IsAbnormal: is_abnormal,
+	}
+	f.raiseFlags([]FloatVar{x, y, z}, result, nil, is_inexact, is_tiny, is_overflow)
+	return result
+}
+
+// Align `p = x.mantissa * y.mantissa` and `z.mantissa` in a window of `3M + 7` bits for `Self::fma`, add them
+// together, and normalize the sum, where `exponent` corresponds to the MSB of the window.
+// Return the `M + 4`-bit mantissa whose LSB is a sticky bit, the exponent of its MSB, and whether the sum is
+// negative or zero.
+func (f *Context) alignFMA(
+	p, p_sign frontend.Variable,
+	z FloatVar,
+	delta, z_is_larger, exponent frontend.Variable,
+) (frontend.Variable, frontend.Variable, frontend.Variable, frontend.Variable) {
+	// Align `p` and `z.mantissa` in a window of `3M + 7` bits.
+	// The larger one is placed such that its upper bound is `2^(3M + 6)`, i.e., `p` is left shifted by `M + 4`
+	// bits, or `z.mantissa` is left shifted by `2M + 5` bits.
+	// The smaller one should be placed `delta` bits lower, i.e., left shifted by `M + 4 - delta` bits if it
+	// is `p`, or `2M + 5 - delta` bits if it is `z.mantissa`.
+	// If the shift count is negative, the smaller one lies entirely below the lowest bit of the window.
+	// Similar to `Self::add`, we then shift it by 0 bits instead. This is fine because the smaller one still
+	// lies entirely below the `M + 3` MSBs of the sum, so it only affects the result through the sticky bit
+	// and the possible borrow, both of which remain unchanged.
+	small_shift := f.Gadget.Max(
+		f.Api.Sub(
+			f.Api.Select(
+				z_is_larger,
+				big.NewInt(int64(f.M+4)),
+				big.NewInt(int64(2*f.M+5)),
+			),
+			delta,
+		),
+		big.NewInt(0),
+		f.E+2,
+	)
+	// `small_shift` may exceed the size of the powers-of-two table, so we use the variant supporting
+	// larger exponents.
+	two_to_small_shift := f.Gadget.QueryLargePowerOf2(small_shift)
+
+	// Compute the signed and aligned operands, and add them together.
+	pp := f.Api.Mul(
+		f.Api.Select(
+			p_sign,
+			f.Api.Neg(p),
+			p,
+		),
+		f.Api.Select(
+			z_is_larger,
+			two_to_small_shift,
+			new(big.Int).Lsh(big.NewInt(1), f.M+4),
+		),
+	)
+	zz := f.Api.Mul(
+		f.Api.Select(
+			z.Sign,
+			f.Api.Neg(z.Mantissa),
+			z.Mantissa,
+		),
+		f.Api.Select(
+			z_is_larger,
+			new(big.Int).Lsh(big.NewInt(1), 2*f.M+5),
+			two_to_small_shift,
+		),
+	)
+	s := f.Api.Add(pp, zz)
+	// Both aligned operands are less than `2^(3M + 6)`, hence `s` has at most `3M + 7` bits.
+	s_bit_length := 3*f.M + 7
+
+	// Get the sign of `s` and find how many bits to shift `|s|` to the left to have the
+	// `s_bit_length - 1`-th bit equal to 1.
+	// Prodive these values as hints to the circuit.
+	outputs, err := f.Api.Compiler().NewHint(hint.AbsHint, 2, s)
+	if err != nil {
+		panic(err)
+	}
+	s_ge_0 := outputs[0]
+	s_abs := outputs[1]
+	f.Api.AssertIsBoolean(s_ge_0)
+	s_lt_0 := f.Api.Sub(big.NewInt(1), s_ge_0)
+	f.Api.Compiler().MarkBoolean(s_lt_0)
+	outputs, err = f.Api.Compiler().NewHint(hint.NormalizeHint, 1, s_abs, big.NewInt(int64(s_bit_length)))
+	if err != nil {
+		panic(err)
+	}
+	shift := outputs[0]
+
+	// Unlike `Self::add`, we cannot left shift `|s|` by `shift` bits, as the result would have `4M + 10` bits
+	// after multiplying by `2^shift_max` in `Self::round`, which overflows the field for f64.
+	// Instead, we split `|s|` into `hi || lo`, where `hi` contains the `M + 3` MSBs and `lo` contains the
+	// remaining `k = 2M + 4 - shift` bits, and replace `lo` with a sticky bit, which is sufficient for rounding.
+	k := f.Api.Sub(big.NewInt(int64(2*f.M+4)), shift)
+	// `k` is only guaranteed to be in the range `[0, 2M + 4]` by the checks below, so we use the variant of
+	// `QueryPowerOf2` supporting larger exponents.
+	two_to_k := f.Gadget.QueryLargePowerOf2(k)
+	outputs, err = f.Api.Compiler().NewHint(hint.TruncHint, 1, s_abs, k)
+	if err != nil {
+		panic(err)
+	}
+	hi := outputs[0]
+	s_abs = f.Api.Select(
+		s_ge_0,
+		s,
+		f.Api.Neg(s),
+	)
+	lo := f.Api.Sub(s_abs, f.Api.Mul(hi, two_to_k))
+
+	s_is_zero := f.Api.IsZero(s_abs)
+	s_is_not_zero := f.Api.Sub(big.NewInt(1), s_is_zero)
+	f.Api.Compiler().MarkBoolean(s_is_not_zero)
+
+	// Enforce that `hi` has exactly `M + 3` bits (unless `s` is zero) and `0 <= lo < 2^k`.
+	// Soundness holds because
+	// * `|s|` is determined by `s` and `s_ge_0`, where the latter is enforced by the range checks as well,
+	// since `-s` (if `s > 0`) or `s` (if `s < 0`) is a huge field element and cannot be decomposed.
+	// * If `k` is greater than the actual value, then `hi * 2^k > |s|`, and `lo` will be negative.
+	// * If `k` is less than the actual value, then `hi * 2^k < 2^(M + 3 + k)`, and `lo` will be at least `2^k`.
+	// * If `|s|` is zero, then `hi` is zero and `lo` is zero.
+	f.Gadget.AssertBitLength(
+		f.Api.Sub(hi, f.Api.Mul(s_is_not_zero, new(big.Int).Lsh(big.NewInt(1), f.M+2))),
+		f.M+2,
+		gadget.TightForSmallAbs,
+	)
+	f.Gadget.AssertBitLength(lo, 2*f.M+4, gadget.Loose)
+	f.Gadget.AssertBitLength(f.Api.Sub(f.Api.Sub(two_to_k, lo), big.NewInt(1)), 2*f.M+4, gadget.Loose)
+
+	// Append the sticky bit to `hi`, so that the mantissa has `M + 4` bits.
+	mantissa := f.Api.Add(f.Api.Add(hi, hi), f.Api.Sub(big.NewInt(1), f.Api.IsZero(lo)))
+	// Decrement the exponent by `shift`.
+	exponent = f.Api.Sub(exponent, shift)
+
+	return mantissa, exponent, s_lt_0, s_is_zero
+}
+
+// Same as `Self::align_fma`, but for wide formats such as binary128, whose aligned sum of `3M + 7` bits overflows
+// the native field.
+// The window has `T = F::MODULUS_BIT_SIZE - 4` bits instead, and the larger operand is placed at its top as
+// before, but the smaller one may now extend below its lowest bit. These bits are replaced with a sticky bit in an
+// extra LSB of the sum, which is sufficient for rounding if `T >= 2M + 4`:
+// * The smaller operand is truncated only if its upper bound is at least 3 bits lower than the larger one's, in
+// which case `|x * y + z| > 2^(exponent - 3)` (recall that `|x * y| >= 2^(exponent - 2)` if it is the larger one),
+// i.e., `|s| > 2^(T - 2) >= 2^(M + 3)`.
+// * The exact sum and the truncated one lie strictly between the same two consecutive multiples of 2 in units of
+// the extra LSB, so they agree on the `M + 3` MSBs and on whether the remaining bits are zero.
+func (f *Context) alignWideFMA(
+	p, p_sign frontend.Variable,
+	z FloatVar,
+	delta, z_is_larger, exponent frontend.Variable,
+) (frontend.Variable, frontend.Variable, frontend.Variable, frontend.Variable) {
+	t := uint(f.Api.Compiler().Field().BitLen()) - 4
+
+	// The larger one is placed such that its upper bound is `2^T`, i.e., `p` is left shifted by `T - 2M - 2`
+	// bits, or `z.mantissa` is left shifted by `T - M - 1` bits.
+	pp := f.Api.Select(
+		p_sign,
+		f.Api.Neg(p),
+		p,
+	)
+	zz := f.Api.Select(
+		z.Sign,
+		f.Api.Neg(z.Mantissa),
+		z.Mantissa,
+	)
+	large := f.Api.Mul(
+		f.Api.Select(
+			z_is_larger,
+			zz,
+			pp,
+		),
+		f.Api.Select(
+			z_is_larger,
+			new(big.Int).Lsh(big.NewInt(1), t-f.M-1),
+			new(big.Int).Lsh(big.NewInt(1), t-2*f.M-2),
+		),
+	)
+
+	// The smaller one should be placed `delta` bits lower, i.e., left shifted by `T - 2M - 2 - delta` bits if it
+	// is `p`, or `T - M - 1 - delta` bits if it is `z.mantissa`, where the shift count may be negative.
+	// To keep the intermediate values small, we split `p` into `p_hi || p_lo` with `M + 1` bits each, so that the
+	// smaller one consists of two `M + 1`-bit halves in both cases, which are aligned separately.
+	outputs, err := f.Api.Compiler().NewHint(hint.TruncHint, 1, p, f.M+1)
+	if err != nil {
+		panic(err)
+	}
+	p_hi := outputs[0]
+	p_lo := f.Api.Sub(p, f.Api.Mul(p_hi, new(big.Int).Lsh(big.NewInt(1), f.M+1)))
+	// Enforce that `0 <= p_lo < 2^(M + 1)`, which makes the split unique, since `p_hi * 2^(M + 1) + p_lo` cannot
+	// overflow the native field.
+	f.Gadget.AssertBitLength(p_hi, f.M+1, gadget.Loose)
+	f.Gadget.AssertBitLength(p_lo, f.M+1, gadget.Loose)
+	f.Gadget.AssertBitLength(f.Api.Sub(new(big.Int).Lsh(big.NewInt(1), f.M+1), f.Api.Add(p_lo, big.NewInt(1))), f.M+1, gadget.Loose)
+	halves := []frontend.Variable{
+		f.Api.Select(
+			z_is_larger,
+			p_lo,
+			z.Mantissa,
+		),
+		f.Api.Select(
+			z_is_larger,
+			p_hi,
+			big.NewInt(0),
+		),
+	}
+	shift := f.Api.Sub(
+		f.Api.Select(
+			z_is_larger,
+			big.NewInt(int64(t-2*f.M-2)),
+			big.NewInt(int64(t-f.M-1)),
+		),
+		delta,
+	)
+
+	// The `i`-th half is left shifted by `shift_i = shift + i * (M + 1)` bits. If `shift_i` is negative, we split
+	// the half into `hi || lo`, where `lo` contains the `k = min(-shift_i, M + 1)` bits below the window, and
+	// `hi` is not shifted. Otherwise, `k` is 0, and `hi` is the whole half, which is left shifted by `shift_i`
+	// bits. This is `k = shift_hi - c` and `shift_hi = max(c, 0)` with `c = max(shift_i, -(M + 1))`.
+	var small, rest frontend.Variable = 0, 0
+	for i, half := range halves {
+		c := f.Gadget.Max(
+			f.Api.Add(shift, big.NewInt(int64(uint(i)*(f.M+1)))),
+			big.NewInt(-int64(f.M+1)),
+			f.E+2,
+		)
+		shift_hi := f.Gadget.Max(c, big.NewInt(0), uint(bits.Len(t)))
+		k := f.Api.Sub(shift_hi, c)
+		two_to_k := f.Gadget.QueryBoundedPowerOf2(k, f.M+1)
+		outputs, err := f.Api.Compiler().NewHint(hint.TruncHint, 1, half, k)
+		if err != nil {
+			panic(err)
+		}
+		hi := outputs[0]
+		lo := f.Api.Sub(half, f.Api.Mul(hi, two_to_k))
+		// Enforce that `0 <= lo < 2^k` as in `Self::align_fma`, where `hi * 2^k + lo` cannot overflow the native
+		// field either.
+		f.Gadget.AssertBitLength(hi, f.M+1, gadget.Loose)
+		f.Gadget.AssertBitLength(lo, f.M+1, gadget.Loose)
+		f.Gadget.AssertBitLength(f.Api.Sub(f.Api.Sub(two_to_k, lo), big.NewInt(1)), f.M+1, gadget.Loose)
+
+		small = f.Api.Add(small, f.Api.Mul(hi, f.Gadget.QueryBoundedPowerOf2(shift_hi, t)))
+		rest = f.Api.Add(rest, lo)
+	}
+	// Append the sticky bit to the aligned smaller operand, which is nonzero if any `lo` is nonzero.
+	small = f.Api.Add(f.Api.Add(small, small), f.Api.Sub(big.NewInt(1), f.Api.IsZero(rest)))
+
+	// Both aligned operands are less than `2^T` in magnitude, hence `s` has at most `T + 2` bits with the extra
+	// LSB, which corresponds to the exponent `exponent - T - 1`.
+	s := f.Api.Add(
+		f.Api.Add(large, large),
+		f.Api.Select(
+			f.Api.Select(
+				z_is_larger,
+				p_sign,
+				z.Sign,
+			),
+			f.Api.Neg(small),
+			small,
+		),
+	)
+	return f.normalizeSticky(s, t+2, f.Api.Sub(exponent, big.NewInt(int64(t+1))))
+}
+
+// Compute the dot product `xs[0] * ys[0] + ... + xs[n - 1] * ys[n - 1]` with a single rounding.
+// Instead of rounding every product and every partial sum as the chained `Self::mul` and `Self::add` do, we
+// accumulate the exact products in a fixed-point accumulator in the native field and round it only once, which
+// is more accurate, e.g., the result is exact whenever the exact dot product is representable, and is also
+// cheaper for binary32 and binary64 dot products of four or more terms (see `TestFloatCircuitConstraintsDot`).
+// A full (Kulisch) accumulator covering all possible products needs more than 500 bits for binary32, so the
+// accumulator is a window instead, which starts from the largest product and extends
+// `D = F::MODULUS_BIT_SIZE - 2 - (2M + 2) - ceil(log2(n))` bits below its lowest bit, e.g., `D` is about 200
+// for binary32 and 140 for binary64 over BN254.
+// Hence, the result is the exact dot product rounded once if every nonzero product is at least `2^(-D)` times
+// the largest one in magnitude, which always holds for binary16. Otherwise, the products below the window are
+// ignored and the result is inexact, i.e., in addition to the rounding error, the error is less than `n * 2^(-D)`
+// times the largest product in magnitude.
+// The special cases are the same as those of the chained operations, i.e., the result is NaN if any product is
+// NaN or there are infinite products with different signs, and is infinity if there is any infinite product.
+// An exact zero result is `-0` if all products are `-0`, and `+0` otherwise, except that it is `-0` unless all
+// products are `+0` when rounding toward negative infinity. The empty dot product is `+0`.
+// This panics if `xs` and `ys` have different lengths, or if the window is narrower than `M + 3` bits, e.g.,
+// for binary128.
+func (f *Context) Dot(xs, ys []FloatVar) FloatVar {
+	if len(xs) != len(ys) {
+		panic("the operands of the dot product should have the same length")
+	}
+	switch len(xs) {
+	case 0:
+		return f.newFolded(f.constZero(false))
+	case 1:
+		return f.Mul(xs[0], ys[0])
+	}
+	// The exponent of a nonzero finite number is in the range `[E_NORMAL_MIN - M, E_MAX - 1]`, and `x * y` is
+	// equal to `x.mantissa * y.mantissa * 2^(x.exponent + y.exponent - 2M)`.
+	exponent_min := 2*int(f.E_NORMAL_MIN.Int64()) - 4*int(f.M)
+	exponent_max := 2*int(f.E_MAX.Int64()) - 2 - 2*int(f.M)
+	if result, ok := f.foldDot(xs, ys, exponent_min); ok {
+		return result
+	}
+
+	n := len(xs)
+	signs := make([]frontend.Variable, n)
+	mantissas := make([]frontend.Variable, n)
+	exponents := make([]frontend.Variable, n)
+	is_abnormal := make([]frontend.Variable, n)
+	for i := range xs {
+		// The product of the mantissas is exact and has at most `2M + 2` bits.
+		signs[i] = f.Api.Xor(xs[i].Sign, ys[i].Sign)
+		mantissas[i] = f.Api.Mul(xs[i].Mantissa, ys[i].Mantissa)
+		exponents[i] = f.Api.Sub(f.Api.Add(xs[i].Exponent, ys[i].Exponent), big.NewInt(int64(2*f.M)))
+		is_abnormal[i] = f.Api.Or(xs[i].IsAbnormal, ys[i].IsAbnormal)
+	}
+	return f.sumExact(append(slices.Clone(xs), ys...), signs, mantissas, exponents, is_abnormal, 2*f.M+2, exponent_min, exponent_max)
+}
+
+// Compute the sum `xs[0] + ... + xs[n - 1]` with a single rounding.
+// As in `Self::dot`, the numbers are accumulated exactly in a window, which extends
+// `D = F::MODULUS_BIT_SIZE - 2 - (M + 1) - ceil(log2(n))` bits below the lowest bit of the largest number,
+// e.g., `D` is about 225 for binary32 and 195 for binary64 over BN254, and the same guarantees hold with the
+// products replaced by the numbers.
+func (f *Context) Sum(xs []FloatVar) FloatVar {
+	switch len(xs) {
+	case 0:
+		return f.newFolded(f.constZero(false))
+	case 1:
+		return xs[0]
+	}
+	// `x` is equal to `x.mantissa * 2^(x.exponent - M)`.
+	exponent_min := int(f.E_NORMAL_MIN.Int64()) - 2*int(f.M)
+	exponent_max := int(f.E_MAX.Int64()) - 1 - int(f.M)
+	if result, ok := f.foldSum(xs, exponent_min); ok {
+		return result
+	}
+
+	n := len(xs)
+	signs := make([]frontend.Variable, n)
+	mantissas := make([]frontend.Variable, n)
+	exponents := make([]frontend.Variable, n)
+	is_abnormal := make([]frontend.Variable, n)
+	for i, x := range xs {
+		signs[i] = x.Sign
+		mantissas[i] = x.Mantissa
+		exponents[i] = f.Api.Sub(x.Exponent, big.NewInt(int64(f.M)))
+		is_abnormal[i] = x.IsAbnormal
+	}
+	return f.sumExact(xs, signs, mantissas, exponents, is_abnormal, f.M+1, exponent_min, exponent_max)
+}
+
+// Return the width of the window in `Self::sum_exact`, i.e., how many bits the accumulator extends below the
+// lowest bit of the largest term, for `n` terms whose mantissas have at most `mantissa_bit_length` bits.
+// The accumulator has `F::MODULUS_BIT_SIZE - 2` bits, so that its absolute value fits in the native field and
+// its sign can be determined.
+func (f *Context) sumWindow(mantissa_bit_length uint, n int) int {
+	window := f.Api.Compiler().Field().BitLen() - 2 - int(mantissa_bit_length) - bits.Len(uint(n-1))
+	if window < int(f.M+3) {
+		panic("the exact sum is not supported for this format, as its accumulator overflows the native field")
+	}
+	return window
+}
+
+// Normalize the signed integer `s * 2^exponent`, where `|s|` has at most `s_bit_length` bits, into its sign and
+// an `M + 4`-bit mantissa whose LSB is a sticky bit, which is shared by `Self::sum_exact` and
+// `Self::normalize`. The returned exponent is that of the mantissa's MSB, which is ready for
+// `Self::round_subnormal`. `s_bit_length` should be at least `M + 3` and less than `F::MODULUS_BIT_SIZE - 1`.
+func (f *Context) normalizeSticky(
+	s frontend.Variable,
+	s_bit_length uint,
+	exponent frontend.Variable,
+) (frontend.Variable, frontend.Variable, frontend.Variable, frontend.Variable) {
+	// Get the sign of `s` and find how many bits to shift `|s|` to the left to have the
+	// `s_bit_length - 1`-th bit equal to 1.
+	// Prodive these values as hints to the circuit.
+	outputs, err := f.Api.Compiler().NewHint(hint.AbsHint, 2, s)
+	if err != nil {
+		panic(err)
+	}
+	s_ge_0 := outputs[0]
+	s_abs := outputs[1]
+	f.Api.AssertIsBoolean(s_ge_0)
+	s_lt_0 := f.Api.Sub(big.NewInt(1), s_ge_0)
+	f.Api.Compiler().MarkBoolean(s_lt_0)
+	outputs, err = f.Api.Compiler().NewHint(hint.NormalizeHint, 1, s_abs, big.NewInt(int64(s_bit_length)))
+	if err != nil {
+		panic(err)
+	}
+	shift := outputs[0]
+	f.Gadget.AssertBitLength(shift, uint(bits.Len(s_bit_length)), gadget.Loose)
+
+	// As in `Self::align_fma`, we split `|s|` into `hi || lo`, where `hi` contains the `M + 3` MSBs and `lo` contains
+	// the remaining `k = max(k_max - shift, 0)` bits, and replace `lo` with a sticky bit.
+	// However, `|s|` may be shorter than `M + 3` bits after cancellation, in which case `k` is 0, and we left
+	// shift `hi` by `a = max(shift - k_max, 0)` bits instead. Note that at most one of `k` and `a` is nonzero.
+	k_max := s_bit_length - f.M - 3
+	k := f.Gadget.Max(f.Api.Sub(big.NewInt(int64(k_max)), shift), big.NewInt(0), uint(bits.Len(s_bit_length)))
+	a := f.Api.Sub(f.Api.Add(k, shift), big.NewInt(int64(k_max)))
+	two_to_k := f.Gadget.QueryBoundedPowerOf2(k, k_max)
+	two_to_a := f.Gadget.QueryPowerOf2(a)
+	outputs, err = f.Api.Compiler().NewHint(hint.TruncHint, 1, s_abs, k)
+	if err != nil {
+		panic(err)
+	}
+	hi := outputs[0]
+	s_abs = f.Api.Select(
+		s_ge_0,
+		s,
+		f.Api.Neg(s),
+	)
+	lo := f.Api.Sub(s_abs, f.Api.Mul(hi, two_to_k))
+	hi = f.Api.Mul(hi, two_to_a)
+
+	s_is_zero := f.Api.IsZero(s_abs)
+	s_is_not_zero := f.Api.Sub(big.NewInt(1), s_is_zero)
+	f.Api.Compiler().MarkBoolean(s_is_not_zero)
+
+	// Enforce that `hi` has exactly `M + 3` bits (unless `s` is zero) and `0 <= lo < 2^k`.
+	// Soundness holds for the same reasons as in `Self::align_fma`, where `|s| = hi || lo` has at most `s_bit_length`
+	// bits and cannot overflow the native field. Moreover, if `a` is nonzero, then `k` is 0, which forces `lo` to
+	// be 0, and hence `hi` is exactly `|s| * 2^a`.
+	f.Gadget.AssertBitLength(
+		f.Api.Sub(hi, f.Api.Mul(s_is_not_zero, new(big.Int).Lsh(big.NewInt(1), f.M+2))),
+		f.M+2,
+		gadget.TightForSmallAbs,
+	)
+	f.Gadget.AssertBitLength(lo, k_max, gadget.Loose)
+	f.Gadget.AssertBitLength(f.Api.Sub(f.Api.Sub(two_to_k, lo), big.NewInt(1)), k_max, gadget.Loose)
+
+	// Append the sticky bit to `hi`, so that the mantissa has `M + 4` bits, whose MSB is in units of
+	// `2^(exponent + M + 2 + k - a)`.
+	mantissa := f.Api.Add(f.Api.Add(hi, hi), f.Api.Sub(big.NewInt(1), f.Api.IsZero(lo)))
+	exponent = f.Api.Sub(f.Api.Add(exponent, k, big.NewInt(int64(f.M+2))), a)
+
+	return mantissa, exponent, s_lt_0, s_is_zero
+}
+
+// Round the exact sum of the terms `(-1)^signs[i] * mantissas[i] * 2^exponents[i]` only once, which is shared
+// by `Self::dot` and `Self::sum`, where `inputs` are the operands of the caller.
+// Each mantissa has at most `mantissa_bit_length` bits, and the exponent of each nonzero finite term is in the
+// range `[exponent_min, exponent_max]`. If `is_abnormal[i]` is true, the term is infinity, or NaN if its
+// mantissa is 0.
+func (f *Context) sumExact(
+	inputs []FloatVar,
+	signs, mantissas, exponents, is_abnormal []frontend.Variable,
+	mantissa_bit_length uint,
+	exponent_min, exponent_max int,
+) FloatVar {
+	window := f.sumWindow(mantissa_bit_length, len(mantissas))
+	accumulator_bit_length := uint(f.Api.Compiler().Field().BitLen() - 2)
+	// The difference between the exponents of two nonzero finite terms is at most `delta_max`.
+	delta_max := exponent_max - exponent_min
+
+	mantissas = slices.Clone(mantissas)
+	exponents = slices.Clone(exponents)
+	is_zero := make([]frontend.Variable, len(mantissas))
+	var input_is_abnormal, is_nan, is_pos_inf, is_neg_inf frontend.Variable = 0, 0, 0, 0
+	for i := range mantissas {
+		is_zero[i] = f.Api.IsZero(mantissas[i])
+		if !f.FiniteOnly {
+			// A term is NaN if it is abnormal and its mantissa is 0, e.g., `inf * 0`, and infinity otherwise.
+			term_is_nan := f.Api.And(is_abnormal[i], is_zero[i])
+			term_is_inf := f.Api.Sub(is_abnormal[i], term_is_nan)
+			f.Api.Compiler().MarkBoolean(term_is_inf)
+			not_sign := f.Api.Sub(big.NewInt(1), signs[i])
+			f.Api.Compiler().MarkBoolean(not_sign)
+			is_nan = f.Api.Or(is_nan, term_is_nan)
+			is_pos_inf = f.Api.Or(is_pos_inf, f.Api.And(term_is_inf, not_sign))
+			is_neg_inf = f.Api.Or(is_neg_inf, f.Api.And(term_is_inf, signs[i]))
+			input_is_abnormal = f.Api.Or(input_is_abnormal, is_abnormal[i])
+			// An abnormal term alone determines the result, so we exclude it from the accumulator.
+			mantissas[i] = f.Api.Select(is_abnormal[i], big.NewInt(0), mantissas[i])
+			is_zero[i] = f.Api.Or(is_zero[i], is_abnormal[i])
+		}
+		// The exponent of a zero term is meaningless, so we replace it with `exponent_min`, which prevents it from
+		// being the largest one, e.g., in `0 * 2^(E_MAX - 1) + 2^(E_NORMAL_MIN - M) * 1`.
+		exponents[i] = f.Api.Select(is_zero[i], big.NewInt(int64(exponent_min)), exponents[i])
+	}
+	// The sum of infinities with different signs is NaN.
+	is_nan = f.Api.Or(is_nan, f.Api.And(is_pos_inf, is_neg_inf))
+
+	// Find the largest exponent `top`.
+	top := exponents[0]
+	for _, exponent := range exponents[1:] {
+		top = f.Gadget.Max(top, exponent, uint(bits.Len(uint(delta_max))))
+	}
+
+	// Align the terms in the window by left shifting each term by `window - (top - exponent)` bits, so that the
+	// accumulator `s` is the exact sum in units of `2^(top - window)`.
+	// If the shift count is negative, the term lies entirely below the window and is ignored, which is only
+	// possible if `delta_max` exceeds the window. Otherwise, the shift count is at least `window - delta_max`,
+	// and we only need to query the power of 2 of the remaining part.
+	shift_min := max(window-delta_max, 0)
+	var s frontend.Variable = big.NewInt(0)
+	var is_ignored frontend.Variable = big.NewInt(0)
+	// An exact zero sum is `-0` if all terms are `-0`, or if not all terms are `+0` when rounding toward negative
+	// infinity, as in `Self::add`.
+	var zero_sign frontend.Variable = big.NewInt(1)
+	for i := range mantissas {
+		if f.RoundingMode == RoundTowardNegative {
+			not_sign := f.Api.Sub(big.NewInt(1), signs[i])
+			f.Api.Compiler().MarkBoolean(not_sign)
+			zero_sign = f.Api.And(zero_sign, f.Api.And(is_zero[i], not_sign))
+		} else {
+			zero_sign = f.Api.And(zero_sign, f.Api.And(is_zero[i], signs[i]))
+		}
+
+		mantissa := mantissas[i]
+		shift := f.Api.Add(f.Api.Sub(exponents[i], top), big.NewInt(int64(window)))
+		if delta_max > window {
+			// The sign of 0 is ambiguous in `Self::is_positive`, so we check `-shift - 1` instead of `-shift`. The
+			// honest prover takes the ambiguous case `shift = -1` as ignored, and a malicious prover cannot take it
+			// as kept, as `2^(-1)` is not in the powers-of-two table.
+			is_not_kept := f.Gadget.IsPositive(f.Api.Sub(big.NewInt(-1), shift), uint(bits.Len(uint(max(delta_max, window+1)))))
+			is_kept := f.Api.Sub(big.NewInt(1), is_not_kept)
+			f.Api.Compiler().MarkBoolean(is_kept)
+			if f.Flags != nil {
+				is_not_zero := f.Api.Sub(big.NewInt(1), is_zero[i])
+				f.Api.Compiler().MarkBoolean(is_not_zero)
+				is_ignored = f.Api.Or(is_ignored, f.Api.And(is_not_kept, is_not_zero))
+			}
+			mantissa = f.Api.Select(is_kept, mantissa, big.NewInt(0))
+			shift = f.Api.Select(is_kept, shift, big.NewInt(0))
+		}
+		term := f.Api.Mul(
+			mantissa,
+			f.Gadget.QueryBoundedPowerOf2(f.Api.Sub(shift, big.NewInt(int64(shift_min))), uint(window-shift_min)),
+			new(big.Int).Lsh(big.NewInt(1), uint(shift_min)),
+		)
+		s = f.Api.Add(s, f.Api.Select(signs[i], f.Api.Neg(term), term))
+	}
+	if f.RoundingMode == RoundTowardNegative {
+		zero_sign = f.Api.Sub(big.NewInt(1), zero_sign)
+		f.Api.Compiler().MarkBoolean(zero_sign)
+	}
+
+	mantissa, exponent, s_lt_0, s_is_zero := f.normalizeSticky(
+		s,
+		accumulator_bit_length,
+		f.Api.Sub(top, big.NewInt(int64(window))),
+	)
+
+	sign := f.Api.Select(
+		s_is_zero,
+		zero_sign,
+		s_lt_0,
+	)
+
+	mantissa, exponent, is_inexact, is_tiny := f.roundSubnormal(mantissa, f.M+4, exponent, f.M+2, 1, sign)
+	if f.Flags != nil {
+		// The ignored terms make the result inexact. However, if the kept terms sum to zero, whether the exact
+		// result is tiny is unknown, and the underflow exception is not raised.
+		s_is_not_zero := f.Api.Sub(big.NewInt(1), s_is_zero)
+		f.Api.Compiler().MarkBoolean(s_is_not_zero)
+		is_inexact = f.Api.Or(is_inexact, is_ignored)
+		is_tiny = f.Api.And(is_tiny, s_is_not_zero)
+	}
+
+	mantissa, exponent, is_abnormal_result, is_overflow := f.fixOverflow(
+		mantissa,
+		f.Api.IsZero(mantissa),
+		exponent,
+		input_is_abnormal,
+		sign,
+	)
+
+	result := FloatVar{
+		Sign:       sign,
+		Exponent:   exponent,
+		Mantissa:   mantissa,
+		IsAbnormal: is_abnormal_result,
+	}
+	if !f.FiniteOnly {
+		// If the result is infinity, its sign is the sign of the infinite terms.
+		result.Sign = f.Api.Select(input_is_abnormal, is_neg_inf, sign)
+		result.Mantissa = f.Api.Select(is_nan, big.NewInt(0), mantissa)
+	}
+	f.raiseFlags(inputs, result, nil, is_inexact, is_tiny, is_overflow)
+	return result
+}
+
+// Compute the remainder of `x` divided by `y`, as specified by the `remainder` operation in IEEE 754,
+// i.e., `x - n * y`, where `n` is the integer nearest to `x / y` (and the even one in case of a tie).
+// The result is always exact, and its magnitude is at most `|y| / 2`.
+func (f *Context) Rem(x, y FloatVar) FloatVar {
+	return f.remainder(x, y, true)
+}
+
+// Compute the remainder of `x` divided by `y` with the quotient truncated toward zero, as `fmod` in C,
+// i.e., `x - n * y`, where `n` is the integer part of `x / y`.
+// The result is always exact, has the same sign as `x`, and its magnitude is less than `|y|`.
+func (f *Context) Fmod(x, y FloatVar) FloatVar {
+	return f.remainder(x, y, false)
+}
+
+// Compute `a mod m`, where the quotient is known to have at most `q_bit_length` bits, and `m` is known to
+// be positive and have at most `m_bit_length` bits.
+// `q_bit_length + m_bit_length` should be less than `F::MODULUS_BIT_SIZE`.
+func (f *Context) modulo(a, m frontend.Variable, q_bit_length, m_bit_length uint) frontend.Variable {
+	outputs, err := f.Api.Compiler().NewHint(hint.DivHint, 1, a, m, 0)
+	if err != nil {
+		panic(err)
+	}
+	q := outputs[0]
+	r := f.Api.Sub(a, f.Api.Mul(q, m))
+	// Enforce that `q` is small and `0 <= r < m`, so that `a = q * m + r` holds over the integers.
+	// A tight bound on `q` is required to prevent `q * m` from overflowing.
+	f.Gadget.AssertBitLength(q, q_bit_length, gadget.TightForUnknownRange)
+	f.Gadget.AssertBitLength(r, m_bit_length, gadget.Loose)
+	f.Gadget.AssertBitLength(f.Api.Sub(m, f.Api.Add(r, big.NewInt(1))), m_bit_length, gadget.Loose)
+	return r
+}
+
+// Compute the remainder of `x` divided by `y`, where the quotient is rounded to the nearest integer if
+// `is_nearest` is true, and truncated otherwise.
+func (f *Context) remainder(x, y FloatVar, is_nearest bool) FloatVar {
+	if result, ok := f.foldRemainder(x, y, is_nearest); ok {
+		return result
+	}
+
+	// The result is NaN if `x` is NaN or infinity, or `y` is NaN or zero, where the latter two cases can be
+	// combined as both have mantissa 0.
+	y_mantissa_is_zero := f.Api.IsZero(y.Mantissa)
+	is_nan := f.Api.Or(x.IsAbnormal, y_mantissa_is_zero)
+	// If `y` is NaN or zero, we increase its mantissa to `2^M` to avoid dividing by zero below.
+	y_mantissa := f.Api.Select(
+		y_mantissa_is_zero,
+		new(big.Int).Lsh(big.NewInt(1), f.M),
+		y.Mantissa,
+	)
+
+	// Let `d = x.exponent - y.exponent` and `L = min(x.exponent, y.exponent)`. In units of `2^(L - M)`,
+	// `x` and `y` are `X = x.mantissa * 2^max(d, 0)` and `Y = y.mantissa * 2^max(-d, 0)` respectively.
+	// If `d <= -2`, then `|x| < |y| / 2` and the result is `x` itself, so we clamp `-d` to at most 2, which
+	// does not change the result but keeps `Y` small.
+	// On the other hand, `X` may be huge if `d` is large, so we don't compute `X` directly. Instead, we
+	// compute `R = X mod 2Y = (x.mantissa * (2^max(d, 0) mod 2Y)) mod 2Y`, from which both the remainder
+	// modulo `Y` and the parity of the truncated quotient can be derived.
+	diff_length := f.E + 2
+	d := f.Api.Sub(x.Exponent, y.Exponent)
+	d_pos := f.Gadget.Max(d, big.NewInt(0), diff_length)
+	d_neg := f.Gadget.Min(f.Api.Sub(d_pos, d), big.NewInt(2), diff_length)
+	exponent := f.Api.Sub(x.Exponent, d_pos)
+	// `Y` and `2Y` have at most `M + 3` and `M + 4` bits respectively.
+	y_scaled := f.Api.Mul(y_mantissa, f.Gadget.QueryPowerOf2(d_neg))
+	modulus := f.Api.Add(y_scaled, y_scaled)
+
+	// Compute `2^max(d, 0) mod 2Y` by square-and-multiply, where `max(d, 0)` is at most `E_MAX - E_MIN`.
+	// In each step, the product has at most `2 * (M + 4) + 1` bits, and hence the quotient has at most
+	// `M + 5` bits.
+	d_bits := f.Api.ToBinary(d_pos, new(big.Int).Sub(f.E_MAX, f.E_MIN).BitLen())
+	var power frontend.Variable = 1
+	for i := len(d_bits) - 1; i >= 0; i-- {
+		power = f.modulo(f.Api.Mul(power, power, f.Api.Add(d_bits[i], 1)), modulus, f.M+5, f.M+4)
+	}
+	r := f.modulo(f.Api.Mul(x.Mantissa, power), modulus, f.M+5, f.M+4)
+
+	// Now `R` is in the range `[0, 2Y)`, and the truncated quotient is odd if and only if `R >= Y`.
+	var v frontend.Variable
+	sign := x.Sign
+	if is_nearest {
+		// `n` is `0` if `R <= Y / 2`, `1` if `Y / 2 < R < 3Y / 2`, and `2` if `R >= 3Y / 2`, where the ties
+		// `R = Y / 2` and `R = 3Y / 2` are rounded to even quotients. The result `R - n * Y` is in the range
+		// `[-Y / 2, Y / 2]`.
+		two_r := f.Api.Add(r, r)
+		n := f.Api.Add(
+			f.Api.Sub(big.NewInt(1), f.Gadget.IsPositive(f.Api.Sub(y_scaled, two_r), f.M+5)),
+			f.Gadget.IsPositive(f.Api.Sub(two_r, f.Api.Mul(y_scaled, 3)), f.M+5),
+		)
+		abs, is_positive := f.Gadget.Abs(f.Api.Sub(r, f.Api.Mul(n, y_scaled)), f.M+3)
+		v = abs
+		// The result has the opposite sign of `x` if `R - n * Y` is negative.
+		is_negative := f.Api.Sub(big.NewInt(1), is_positive)
+		f.Api.Compiler().MarkBoolean(is_negative)
+		sign = f.Api.Xor(sign, is_negative)
+	} else {
+		// The result `R mod Y` is in the range `[0, Y)`.
+		v = f.Api.Sub(r, f.Api.Mul(f.Gadget.IsPositive(f.Api.Sub(r, y_scaled), f.M+5), y_scaled))
+	}
+
+	// The magnitude of the result is `v * 2^(L - M)`, where `v` has at most `M + 1` bits, since `Y` has at
+	// most `M + 1` bits if `d >= 0`, and `v = x.mantissa` otherwise.
+	// The result is exact, so we only need to normalize it as in `Self::new_float`.
+	v_is_zero := f.Api.IsZero(v)
+	v_is_not_zero := f.Api.Sub(big.NewInt(1), v_is_zero)
+	f.Api.Compiler().MarkBoolean(v_is_not_zero)
+	outputs, err := f.Api.Compiler().NewHint(hint.NormalizeHint, 1, v, f.M+1)
+	if err != nil {
+		panic(err)
+	}
+	shift := outputs[0]
+	f.Gadget.AssertBitLength(shift, uint(big.NewInt(int64(f.M)).BitLen()), gadget.Loose)
+	mantissa := f.Api.Mul(v, f.Gadget.QueryPowerOf2(shift))
+	f.Gadget.AssertBitLength(
+		f.Api.Sub(
+			mantissa,
+			f.Api.Mul(v_is_not_zero, new(big.Int).Lsh(big.NewInt(1), f.M)),
+		),
+		f.M,
+		gadget.TightForSmallAbs,
+	)
+	exponent = f.Api.Sub(exponent, shift)
+	// The exact result may be subnormal, so we need to flush it if `FlushSubnormals` is set.
+	exponent_is_min := v_is_zero
+	var is_inexact, is_tiny frontend.Variable
+	if f.FlushSubnormals {
+		if f.Flags != nil {
+			is_inexact = big.NewInt(0)
+		}
+		mantissa, is_inexact, is_tiny = f.flushSubnormal(mantissa, exponent, is_inexact, false)
+		exponent_is_min = f.Api.Or(v_is_zero, is_tiny)
+	}
+
+	// If `y` is infinity and `x` is finite, the result is `x`.
+	y_is_inf := f.Api.And(y.IsAbnormal, f.Api.Sub(big.NewInt(1), y_mantissa_is_zero))
+	result := f.Select(
+		is_nan,
+		FloatVar{
+			Sign:       0,
+			Exponent:   f.E_MAX,
+			Mantissa:   0,
+			IsAbnormal: 1,
+		},
+		f.Select(
+			y_is_inf,
+			x,
+			FloatVar{
+				// A zero result has the same sign as `x`.
+				Sign:       f.Api.Select(v_is_zero, x.Sign, sign),
+				Exponent:   f.Api.Select(exponent_is_min, f.E_MIN, exponent),
+				Mantissa:   mantissa,
+				IsAbnormal: 0,
+			},
+		),
+	)
+	f.raiseFlags([]FloatVar{x, y}, result, nil, is_inexact, is_tiny, nil)
+	return result
+}
+
+// Convert `x` from the format of `from` to the format of `to`, as specified by the `convertFormat`
+// operation in IEEE 754.
+// If every number in `from`'s format is representable in `to`'s format (e.g., from f32 to f64), the
+// conversion is exact and only relabels the components. Otherwise, the result is rounded according to
+// `to`'s rounding mode, and the exception flags of `to` are raised if they are tracked.
+// Both contexts should be created from the same API.
+func Convert(from, to *Context, x FloatVar) FloatVar {
+	if result, ok := foldConvert(from, to, x); ok {
+		return result
+	}
+
+	if to.E >= from.E && to.M >= from.M {
+		// Our representation of `x`'s mantissa has an explicit leading 1 even if `x` is subnormal, so we only
+		// need to pad the mantissa, as `x` is normal in `to`'s format.
+		// The mantissas of NaN, infinity and zero (`0`, `2^M` and `0`) are padded correctly as well, but their
+		// exponents depend on the format.
+		return FloatVar{
+			Sign: x.Sign,
+			Exponent: to.Api.Select(
+				x.IsAbnormal,
+				to.E_MAX,
+				to.Api.Select(
+					to.Api.IsZero(x.Mantissa),
+					to.E_MIN,
+					x.Exponent,
+				),
+			),
+			Mantissa:   to.Api.Mul(x.Mantissa, new(big.Int).Lsh(big.NewInt(1), to.M-from.M)),
+			IsAbnormal: x.IsAbnormal,
+		}
+	}
+
+	// `Self::round` requires the mantissa to have at least `M + 2` bits, so we pad the mantissa if necessary.
+	mantissa := x.Mantissa
+	mantissa_bit_length := from.M + 1
+	if mantissa_bit_length < to.M+2 {
+		mantissa = to.Api.Mul(mantissa, new(big.Int).Lsh(big.NewInt(1), to.M+2-mantissa_bit_length))
+		mantissa_bit_length = to.M + 2
+	}
+
+	// Clamp the exponent to `[E_NORMAL_MIN - (M + 3), E_MAX]` of `to`'s format, which keeps the differences
+	// computed in `Self::round_subnormal` and `Self::fix_overflow` small.
+	// This does not change the result, since a number below the lower bound is still less than half of the
+	// smallest subnormal number, and a number above the upper bound still overflows.
+	diff_length := to.E + 2
+	if from.E > to.E {
+		diff_length = from.E + 2
+	}
+	exponent := to.Gadget.Min(
+		to.Gadget.Max(
+			x.Exponent,
+			new(big.Int).Sub(to.E_NORMAL_MIN, big.NewInt(int64(to.M+3))),
+			diff_length,
+		),
+		to.E_MAX,
+		diff_length,
+	)
+
+	mantissa, exponent, is_inexact, is_tiny := to.roundSubnormal(mantissa, mantissa_bit_length, exponent, to.M+2, 1, x.Sign)
+
+	mantissa_is_zero := to.Api.IsZero(mantissa)
+	mantissa, exponent, is_abnormal, is_overflow := to.fixOverflow(
+		mantissa,
+		mantissa_is_zero,
+		exponent,
+		x.IsAbnormal,
+		x.Sign,
+	)
+
+	result := FloatVar{
+		Sign:     x.Sign,
+		Exponent: exponent,
+		// If the mantissa before fixing overflow is zero, we reset the final mantissa to 0,
+		// as `Self::fix_overflow` incorrectly sets NaN's mantissa to infinity's mantissa.
+		Mantissa: to.Api.Select(
+			mantissa_is_zero,
 			big.NewInt(0),
 			mantissa,
 		),
 		IsAbnormal: is_abnormal,
 	}
+	to.raiseFlags([]FloatVar{x}, result, nil, is_inexact, is_tiny, is_overflow)
+	return result
 }
 
 func (f *Context) less(x, y FloatVar, allow_eq uint) frontend.Variable {
+	if result, ok := f.foldLess(x, y, allow_eq); ok {
+		return result
+	}
+
 	xe_ge_ye := f.Gadget.IsPositive(f.Api.Sub(x.Exponent, y.Exponent), f.E+1)
 	xm_ge_ym := f.Gadget.IsPositive(f.Api.Sub(x.Mantissa, y.Mantissa), f.M+1)
 
+	is_nan := f.Api.Or(
+		f.IsNaN(x),
+		f.IsNaN(y),
+	)
+	// The comparisons are signaling, i.e., comparing NaN raises the invalid exception.
+	if f.Flags != nil {
+		f.Flags.Invalid = f.Api.Or(f.Flags.Invalid, is_nan)
+	}
+
 	b := f.Api.Select(
-		f.Api.Or(
-			f.Api.And(x.IsAbnormal, f.Api.IsZero(x.Mantissa)),
-			f.Api.And(y.IsAbnormal, f.Api.IsZero(y.Mantissa)),
-		),
+		is_nan,
 		// If either `x` or `y` is NaN, the result is always false.
 		0,
 		/*
@@ -1028,6 +3180,56 @@ func (f *Context) less(x, y FloatVar, allow_eq uint) frontend.Variable {
 	return b
 }
 
+// Return whether `x` is equal to `y`, as specified by the `compareQuietEqual` operation in IEEE 754, i.e.,
+// NaN is unequal to everything (including itself), and +0 is equal to -0.
+// Since the comparison is quiet, it does not raise the invalid flag for quiet NaNs.
+func (f *Context) IsEq(x, y FloatVar) frontend.Variable {
+	if result, ok := f.foldIsEq(x, y); ok {
+		return result
+	}
+
+	is_nan := f.Api.Or(f.IsNaN(x), f.IsNaN(y))
+	is_not_nan := f.Api.Sub(big.NewInt(1), is_nan)
+	f.Api.Compiler().MarkBoolean(is_not_nan)
+	// Two non-NaN numbers are equal if and only if they are both zero (regardless of the signs), or all
+	// their components are equal.
+	is_eq := f.Api.And(
+		is_not_nan,
+		f.Api.Or(
+			f.Api.IsZero(f.Api.Add(x.Mantissa, y.Mantissa)),
+			f.Api.And(
+				f.Gadget.IsEq(x.Sign, y.Sign),
+				f.Api.And(
+					f.Gadget.IsEq(x.Exponent, y.Exponent),
+					f.Gadget.IsEq(x.Mantissa, y.Mantissa),
+				),
+			),
+		),
+	)
+	return is_eq
+}
+
+// Return whether `x` is less than or equal to `y` in the total order specified by the `totalOrder`
+// predicate in IEEE 754, i.e., -NaN < -inf < ... < -0 < +0 < ... < +inf < +NaN.
+// Since the payloads of NaNs are not recorded in the circuit, NaNs with the same sign are considered equal.
+func (f *Context) TotalOrder(x, y FloatVar) frontend.Variable {
+	if result, ok := f.foldTotalOrder(x, y); ok {
+		return result
+	}
+
+	// We move NaN above infinity in `Self::compare_for_min_max` by setting its mantissa to `2^(M + 1)`,
+	// which makes NaN greater than any number with the same sign in magnitude.
+	lift := func(v FloatVar) FloatVar {
+		v.Mantissa = f.Api.Add(v.Mantissa, f.Api.Mul(f.IsNaN(v), new(big.Int).Lsh(big.NewInt(1), f.M+1)))
+		return v
+	}
+	// `x <= y` if and only if `y < x` is false.
+	y_lt_x, _, _ := f.compareForMinMax(lift(y), lift(x))
+	x_le_y := f.Api.Sub(big.NewInt(1), y_lt_x)
+	f.Api.Compiler().MarkBoolean(x_le_y)
+	return x_le_y
+}
+
 func (f *Context) IsLt(x, y FloatVar) frontend.Variable {
 	return f.less(x, y, 0)
 }
@@ -1044,7 +3246,216 @@ func (f *Context) IsGe(x, y FloatVar) frontend.Variable {
 	return f.less(y, x, 1)
 }
 
+// `Class` is the class of a number returned by `Classify`, as specified by the `class` operation in IEEE 754.
+// Signaling NaNs are not distinguished from quiet NaNs in the circuit, so all NaNs are classified as `ClassNaN`.
+// The classes are ordered such that the class of a non-NaN number is monotonic in its value.
+type Class uint
+
+const (
+	ClassNaN Class = iota
+	ClassNegativeInfinity
+	ClassNegativeNormal
+	ClassNegativeSubnormal
+	ClassNegativeZero
+	ClassPositiveZero
+	ClassPositiveSubnormal
+	ClassPositiveNormal
+	ClassPositiveInfinity
+)
+
+// Return whether `x` is NaN.
+func (f *Context) IsNaN(x FloatVar) frontend.Variable {
+	return f.Api.And(x.IsAbnormal, f.Api.IsZero(x.Mantissa))
+}
+
+// Return whether `x` is positive or negative infinity.
+func (f *Context) IsInf(x FloatVar) frontend.Variable {
+	// Infinity is the only abnormal number with a nonzero mantissa.
+	is_inf := f.Api.Sub(x.IsAbnormal, f.IsNaN(x))
+	f.Api.Compiler().MarkBoolean(is_inf)
+	return is_inf
+}
+
+// Return whether `x` is +0 or -0.
+func (f *Context) IsZero(x FloatVar) frontend.Variable {
+	// Zero is the only normal number with a zero mantissa.
+	is_zero := f.Api.Sub(f.Api.IsZero(x.Mantissa), f.IsNaN(x))
+	f.Api.Compiler().MarkBoolean(is_zero)
+	return is_zero
+}
+
+// Return whether `x` is subnormal, i.e., nonzero and less than the smallest normal number in magnitude.
+func (f *Context) IsSubnormal(x FloatVar) frontend.Variable {
+	if result, ok := f.foldIsSubnormal(x); ok {
+		return result
+	}
+
+	// Both subnormal numbers and zero have exponents less than `E_NORMAL_MIN`, but only the latter has a zero
+	// mantissa.
+	exponent_is_small := f.Gadget.IsPositive(f.Api.Sub(new(big.Int).Sub(f.E_NORMAL_MIN, big.NewInt(1)), x.Exponent), f.E+1)
+	mantissa_is_not_zero := f.Api.Sub(big.NewInt(1), f.Api.IsZero(x.Mantissa))
+	f.Api.Compiler().MarkBoolean(mantissa_is_not_zero)
+	return f.Api.And(exponent_is_small, mantissa_is_not_zero)
+}
+
+// Return whether `x` is normal, i.e., finite, nonzero and not subnormal.
+func (f *Context) IsNormal(x FloatVar) frontend.Variable {
+	is_normal := f.Api.Sub(big.NewInt(1), f.Api.Add(x.IsAbnormal, f.IsZero(x), f.IsSubnormal(x)))
+	f.Api.Compiler().MarkBoolean(is_normal)
+	return is_normal
+}
+
+// Return the class of `x` as a variable whose value is one of the `Class` constants.
+func (f *Context) Classify(x FloatVar) frontend.Variable {
+	is_nan := f.IsNaN(x)
+	is_inf := f.Api.Sub(x.IsAbnormal, is_nan)
+	is_zero := f.Api.Sub(f.Api.IsZero(x.Mantissa), is_nan)
+	is_subnormal := f.IsSubnormal(x)
+	// The distance between the class of `x` and the class of zero with the same sign, which is 0 for zero,
+	// 1 for subnormal numbers, 2 for normal numbers, and 3 for infinity.
+	// Note that this is 2 for NaN, which is fine as NaN is handled separately.
+	distance := f.Api.Add(
+		f.Api.Sub(big.NewInt(2), f.Api.Add(is_zero, is_zero)),
+		f.Api.Sub(is_inf, is_subnormal),
+	)
+	return f.Api.Select(
+		is_nan,
+		uint64(ClassNaN),
+		f.Api.Select(
+			x.Sign,
+			f.Api.Sub(uint64(ClassNegativeZero), distance),
+			f.Api.Add(uint64(ClassPositiveZero), distance),
+		),
+	)
+}
+
+// Compare `x` and `y` for the min/max family and `Self::total_order`, where -0 is considered less than +0,
+// and the outputs are meaningless if `x` or `y` is NaN.
+// Return whether `x < y`, `|x| < |y|` and `|y| < |x|`.
+func (f *Context) compareForMinMax(x, y FloatVar) (frontend.Variable, frontend.Variable, frontend.Variable) {
+	// Since the mantissa has an explicit leading 1, `|x| < |y|` if and only if `(x.exponent, x.mantissa)` is
+	// lexicographically less than `(y.exponent, y.mantissa)`, which holds for zero and infinity as well.
+	// Therefore, we can compare the magnitudes with a single subtraction of
+	// `(exponent - E_MIN) * 2^(M + 1) + mantissa`, which has at most `E + M + 2` bits.
+	key := func(v FloatVar) frontend.Variable {
+		return f.Api.Add(
+			f.Api.Mul(f.Api.Sub(v.Exponent, f.E_MIN), new(big.Int).Lsh(big.NewInt(1), f.M+1)),
+			v.Mantissa,
+		)
+	}
+	diff := f.Api.Sub(key(x), key(y))
+	_, x_mag_ge_y := f.Gadget.Abs(diff, f.E+f.M+3)
+	x_mag_lt_y := f.Api.Sub(big.NewInt(1), x_mag_ge_y)
+	f.Api.Compiler().MarkBoolean(x_mag_lt_y)
+	y_mag_lt_x := f.Api.Sub(x_mag_ge_y, f.Api.IsZero(diff))
+	f.Api.Compiler().MarkBoolean(y_mag_lt_x)
+
+	// If the signs are different, `x < y` if and only if `x` is negative, which also holds for -0 and +0.
+	// Otherwise, the magnitudes are compared in the reverse order for negative numbers.
+	x_lt_y := f.Api.Select(
+		f.Api.Xor(x.Sign, y.Sign),
+		x.Sign,
+		f.Api.Select(
+			x.Sign,
+			y_mag_lt_x,
+			x_mag_lt_y,
+		),
+	)
+	f.Api.Compiler().MarkBoolean(x_lt_y)
+	return x_lt_y, x_mag_lt_y, y_mag_lt_x
+}
+
+// Return the smaller of `x` and `y`, as specified by the `minimum` operation in IEEE 754-2019, where -0 is
+// less than +0, and the result is NaN if either `x` or `y` is NaN.
+func (f *Context) Min(x, y FloatVar) FloatVar {
+	if result, ok := f.foldMinMax(x, y, func(x, y constFloat, x_lt_y, _, _ bool) bool {
+		return x.isNaN() || x_lt_y && !y.isNaN()
+	}); ok {
+		return result
+	}
+
+	x_lt_y, _, _ := f.compareForMinMax(x, y)
+	x_is_nan := f.IsNaN(x)
+	return f.Select(f.Api.Or(x_is_nan, f.Api.And(x_lt_y, f.Api.Sub(big.NewInt(1), f.IsNaN(y)))), x, y)
+}
+
+// Return the larger of `x` and `y`, as specified by the `maximum` operation in IEEE 754-2019, where -0 is
+// less than +0, and the result is NaN if either `x` or `y` is NaN.
+func (f *Context) Max(x, y FloatVar) FloatVar {
+	if result, ok := f.foldMinMax(x, y, func(x, y constFloat, x_lt_y, _, _ bool) bool {
+		return !(y.isNaN() || x_lt_y && !x.isNaN())
+	}); ok {
+		return result
+	}
+
+	x_lt_y, _, _ := f.compareForMinMax(x, y)
+	y_is_nan := f.IsNaN(y)
+	return f.Select(f.Api.Or(y_is_nan, f.Api.And(x_lt_y, f.Api.Sub(big.NewInt(1), f.IsNaN(x)))), y, x)
+}
+
+// Return the smaller of `x` and `y`, as specified by the `minimumNumber` operation in IEEE 754-2019, where
+// -0 is less than +0, and a NaN operand is treated as missing data, i.e., the other operand is returned.
+func (f *Context) MinNum(x, y FloatVar) FloatVar {
+	if result, ok := f.foldMinMax(x, y, func(x, y constFloat, x_lt_y, _, _ bool) bool {
+		return y.isNaN() || x_lt_y && !x.isNaN()
+	}); ok {
+		return result
+	}
+
+	x_lt_y, _, _ := f.compareForMinMax(x, y)
+	return f.Select(f.Api.Or(f.IsNaN(y), f.Api.And(x_lt_y, f.Api.Sub(big.NewInt(1), f.IsNaN(x)))), x, y)
+}
+
+// Return the larger of `x` and `y`, as specified by the `maximumNumber` operation in IEEE 754-2019, where
+// -0 is less than +0, and a NaN operand is treated as missing data, i.e., the other operand is returned.
+func (f *Context) MaxNum(x, y FloatVar) FloatVar {
+	if result, ok := f.foldMinMax(x, y, func(x, y constFloat, x_lt_y, _, _ bool) bool {
+		return !(x.isNaN() || x_lt_y && !y.isNaN())
+	}); ok {
+		return result
+	}
+
+	x_lt_y, _, _ := f.compareForMinMax(x, y)
+	return f.Select(f.Api.Or(f.IsNaN(x), f.Api.And(x_lt_y, f.Api.Sub(big.NewInt(1), f.IsNaN(y)))), y, x)
+}
+
+// Return the one of `x` and `y` with smaller magnitude, or `Self::min(x, y)` if the magnitudes are equal,
+// as specified by the `minimumMagnitude` operation in IEEE 754-2019.
+// The result is NaN if either `x` or `y` is NaN.
+func (f *Context) MinMagnitude(x, y FloatVar) FloatVar {
+	if result, ok := f.foldMinMax(x, y, func(x, y constFloat, x_lt_y, x_mag_lt_y, y_mag_lt_x bool) bool {
+		return x.isNaN() || (x_mag_lt_y || x_lt_y && !y_mag_lt_x) && !y.isNaN()
+	}); ok {
+		return result
+	}
+
+	x_lt_y, x_mag_lt_y, y_mag_lt_x := f.compareForMinMax(x, y)
+	// Choose `x` if `|x| < |y|`, or if the magnitudes are equal and `x < y`.
+	choose_x := f.Api.Or(x_mag_lt_y, f.Api.And(x_lt_y, f.Api.Sub(big.NewInt(1), y_mag_lt_x)))
+	return f.Select(f.Api.Or(f.IsNaN(x), f.Api.And(choose_x, f.Api.Sub(big.NewInt(1), f.IsNaN(y)))), x, y)
+}
+
+// Return the one of `x` and `y` with larger magnitude, or `Self::max(x, y)` if the magnitudes are equal,
+// as specified by the `maximumMagnitude` operation in IEEE 754-2019.
+// The result is NaN if either `x` or `y` is NaN.
+func (f *Context) MaxMagnitude(x, y FloatVar) FloatVar {
+	if result, ok := f.foldMinMax(x, y, func(x, y constFloat, x_lt_y, x_mag_lt_y, y_mag_lt_x bool) bool {
+		return !(y.isNaN() || (x_mag_lt_y || x_lt_y && !y_mag_lt_x) && !x.isNaN())
+	}); ok {
+		return result
+	}
+
+	x_lt_y, x_mag_lt_y, y_mag_lt_x := f.compareForMinMax(x, y)
+	// Choose `y` if `|x| < |y|`, or if the magnitudes are equal and `x < y`.
+	choose_y := f.Api.Or(x_mag_lt_y, f.Api.And(x_lt_y, f.Api.Sub(big.NewInt(1), y_mag_lt_x)))
+	return f.Select(f.Api.Or(f.IsNaN(y), f.Api.And(choose_y, f.Api.Sub(big.NewInt(1), f.IsNaN(x)))), y, x)
+}
+
 func (f *Context) Trunc(x FloatVar) FloatVar {
+	if result, ok := f.foldRoundToIntegral(x, RoundTowardZero); ok {
+		return result
+	}
+
 	e_ge_0 := f.Gadget.IsPositive(x.Exponent, f.E)
 	e := f.Api.Select(
 		e_ge_0,
@@ -1079,6 +3490,10 @@ func (f *Context) Trunc(x FloatVar) FloatVar {
 }
 
 func (f *Context) Floor(x FloatVar) FloatVar {
+	if result, ok := f.foldRoundToIntegral(x, RoundTowardNegative); ok {
+		return result
+	}
+
 	e_ge_0 := f.Gadget.IsPositive(x.Exponent, f.E)
 	e := f.Api.Select(
 		e_ge_0,
@@ -1135,11 +3550,85 @@ func (f *Context) Ceil(x FloatVar) FloatVar {
 	return f.Neg(f.Floor(f.Neg(x)))
 }
 
+// Round `x` to the nearest integral value, and to the one with larger magnitude in case of a tie, as
+// specified by the `roundToIntegralTiesToAway` operation in IEEE 754.
+func (f *Context) Round(x FloatVar) FloatVar {
+	return f.roundToIntegral(x, RoundNearestAway)
+}
+
+// Round `x` to the nearest integral value, and to the even one in case of a tie, as specified by the
+// `roundToIntegralTiesToEven` operation in IEEE 754.
+func (f *Context) RoundEven(x FloatVar) FloatVar {
+	return f.roundToIntegral(x, RoundNearestEven)
+}
+
+// Round `x` to an integral value according to `mode` instead of the context's rounding mode.
+// As in `Self::to_int_checked`, this is equivalent to rounding the mantissa as if the result is subnormal
+// with `E_NORMAL_MIN = M`, and the shift is clamped to `[0, M + 2]`.
+func (f *Context) roundToIntegral(x FloatVar, mode RoundingMode) FloatVar {
+	if result, ok := f.foldRoundToIntegral(x, mode); ok {
+		return result
+	}
+
+	shift := f.Gadget.Max(
+		f.Gadget.Min(
+			f.Api.Sub(f.M, x.Exponent),
+			big.NewInt(int64(f.M+2)),
+			f.E+1,
+		),
+		big.NewInt(0),
+		f.E+1,
+	)
+	// The rounding is done in `mode` without tracking the flags, as `roundToIntegral` does not raise the
+	// inexact flag.
+	g := *f
+	g.RoundingMode = mode
+	g.Flags = nil
+	mantissa, _ := g.round(f.Api.Add(x.Mantissa, x.Mantissa), f.M+2, shift, f.M+2, 1, x.Sign)
+	// If `shift` is 0, `x` is already integral (or abnormal) and the exponent is unchanged. Otherwise, the
+	// rounded mantissa is in units of `2^(M - shift)`, which is `x`'s exponent unless the shift is clamped.
+	exponent := f.Api.Select(
+		f.Api.IsZero(shift),
+		x.Exponent,
+		f.Api.Sub(f.M, shift),
+	)
+	// When `x` is less than 1 in magnitude, i.e., `shift` is `M + 1` or `M + 2`, the rounded mantissa is
+	// either 0 or `2^shift`, and the latter overflows. Also, the mantissa may overflow to `2^(M + 1)` after
+	// rounding up as usual. In these cases, we right shift the mantissa to `2^M` and adjust the exponent.
+	mantissa_overflow := f.Gadget.IsEq(mantissa, new(big.Int).Lsh(big.NewInt(1), f.M+1))
+	mantissa_overflow_twice := f.Gadget.IsEq(mantissa, new(big.Int).Lsh(big.NewInt(1), f.M+2))
+	exponent = f.Api.Add(exponent, mantissa_overflow, mantissa_overflow_twice, mantissa_overflow_twice)
+	mantissa = f.Api.Select(
+		f.Api.Or(mantissa_overflow, mantissa_overflow_twice),
+		new(big.Int).Lsh(big.NewInt(1), f.M),
+		mantissa,
+	)
+
+	return FloatVar{
+		Sign: x.Sign,
+		Exponent: f.Api.Select(
+			f.Api.And(
+				f.Api.IsZero(mantissa),
+				f.Api.Sub(big.NewInt(1), x.IsAbnormal),
+			),
+			f.E_MIN,
+			exponent,
+		),
+		Mantissa:   mantissa,
+		IsAbnormal: x.IsAbnormal,
+	}
+}
+
 // Convert a float to an integer (f64 to i64, f32 to i32).
 // A negative integer will be represented as `r - |x|`, where `r` is the order of the native field.
 // The caller should ensure that `x` is obtained from `Trunc`, `Floor` or `Ceil`.
-// Also, `x`'s exponent should not be too large. Otherwise, the proof verification will fail.
+// Also, `x`'s exponent should be less than the size of the gadget's powers-of-two table, which is `E + M + 1`
+// unless the gadget is shared with a wider format. Otherwise, the proof verification will fail.
 func (f *Context) ToInt(x FloatVar) frontend.Variable {
+	if result, ok := f.foldToInt(x); ok {
+		return result
+	}
+
 	exponent_is_min := f.Gadget.IsEq(x.Exponent, f.E_MIN)
 	two_to_e := f.Gadget.QueryPowerOf2(f.Api.Select(
 		exponent_is_min,
@@ -1154,6 +3643,390 @@ func (f *Context) ToInt(x FloatVar) frontend.Variable {
 	)
 }
 
+// Convert a float to an integer with `bits` bits, as specified by the `convertToInteger` operations in
+// IEEE 754, where `x` is first rounded to an integral value according to `mode` instead of the context's
+// rounding mode, i.e., `RoundTowardZero` for trunc, `RoundTowardNegative` for floor, `RoundTowardPositive`
+// for ceil, and `RoundNearestEven` or `RoundNearestAway` for nearest.
+// If `signed` is true, the integer is in the range `[-2^(bits - 1), 2^(bits - 1) - 1]`, where a negative
+// integer is represented as `r - |x|` as in `Self::to_int`. Otherwise, the integer is in the range
+// `[0, 2^bits - 1]`.
+// Unlike `Self::to_int`, any `x` is accepted, and the second output indicates whether the conversion is
+// invalid, i.e., `x` is NaN or infinity, or the rounded value is out of range. In this case, the integer is
+// the x86 "integer indefinite" value, i.e., `-2^(bits - 1)` if `signed` is true, and `2^bits - 1` otherwise.
+// The invalid flag is raised if the exception flags are tracked, while the inexact flag is never raised.
+func (f *Context) ToIntChecked(x FloatVar, bits uint, signed bool, mode RoundingMode) (frontend.Variable, frontend.Variable) {
+	// The magnitude computed below has at most `bits + 1` bits, and we need to compare it with the bounds.
+	if bits+3 >= uint(f.Api.Compiler().Field().BitLen()) {
+		panic("bits is too large for the native field")
+	}
+	if v, is_invalid, ok := f.foldToIntChecked(x, bits, signed, mode); ok {
+		return v, is_invalid
+	}
+
+	// All differences between exponents below are bounded by `bits + M + 2^(E - 1)` in absolute value.
+	diff_length := f.E + 1
+	if l := uint(big.NewInt(int64(bits+f.M+2)).BitLen()) + 1; l > diff_length {
+		diff_length = l
+	}
+
+	// If `x`'s exponent is at least `bits`, `x` is definitely out of range. Otherwise, we can safely compute
+	// the integer.
+	// In the former case, we replace the exponent with `E_MIN` to keep the subsequent computation small.
+	exponent_is_small := f.Gadget.IsPositive(f.Api.Sub(bits-1, x.Exponent), diff_length)
+	exponent := f.Api.Select(exponent_is_small, x.Exponent, f.E_MIN)
+
+	// Rounding `x` to an integral value is equivalent to rounding the mantissa as if the result is subnormal
+	// with `E_NORMAL_MIN = M`, i.e., right shifting the mantissa by `M - exponent` bits.
+	// As in `Self::round_subnormal`, the shift is clamped to `[0, M + 2]`, which does not change the result,
+	// since `x` is less than 1/2 in magnitude when `M - exponent >= M + 2`.
+	// The mantissa is padded to `M + 2` bits as required by `Self::round`.
+	shift := f.Gadget.Max(
+		f.Gadget.Min(
+			f.Api.Sub(f.M, exponent),
+			big.NewInt(int64(f.M+2)),
+			diff_length,
+		),
+		big.NewInt(0),
+		diff_length,
+	)
+	// The rounding is done in `mode` without tracking the flags, as `convertToInteger` does not raise the
+	// inexact flag.
+	g := *f
+	g.RoundingMode = mode
+	g.Flags = nil
+	mantissa, _ := g.round(f.Api.Add(x.Mantissa, x.Mantissa), f.M+2, shift, f.M+2, 1, x.Sign)
+	// `Self::round` returns the rounded mantissa left shifted by `shift` bits, so we shift it back to obtain
+	// the magnitude of the integer if `exponent <= M`, and left shift it by `exponent - M` bits otherwise.
+	magnitude := f.Api.Div(mantissa, f.Gadget.QueryPowerOf2(shift))
+	if bits-1 > f.M {
+		magnitude = f.Api.Mul(
+			magnitude,
+			f.Gadget.QueryBoundedPowerOf2(
+				f.Gadget.Max(f.Api.Sub(exponent, f.M), big.NewInt(0), diff_length),
+				bits-1-f.M,
+			),
+		)
+	}
+
+	// Check whether the magnitude is in range, where the upper bound is `2^(bits - 1) - 1 + sign` if `signed`
+	// is true, and `(2^bits - 1) * (1 - sign)` otherwise (i.e., only -0 is allowed for negative `x`).
+	var bound frontend.Variable
+	if signed {
+		bound = f.Api.Add(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits-1), big.NewInt(1)), x.Sign)
+	} else {
+		bound = f.Api.Mul(
+			f.Api.Sub(big.NewInt(1), x.Sign),
+			new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits), big.NewInt(1)),
+		)
+	}
+	is_in_range := f.Gadget.IsPositive(f.Api.Sub(bound, magnitude), bits+2)
+
+	is_not_abnormal := f.Api.Sub(big.NewInt(1), x.IsAbnormal)
+	f.Api.Compiler().MarkBoolean(is_not_abnormal)
+	is_valid := f.Api.And(f.Api.And(exponent_is_small, is_in_range), is_not_abnormal)
+	is_invalid := f.Api.Sub(big.NewInt(1), is_valid)
+	f.Api.Compiler().MarkBoolean(is_invalid)
+	if f.Flags != nil {
+		f.Flags.Invalid = f.Api.Or(f.Flags.Invalid, is_invalid)
+	}
+
+	var indefinite frontend.Variable
+	if signed {
+		indefinite = f.Api.Neg(new(big.Int).Lsh(big.NewInt(1), bits-1))
+	} else {
+		indefinite = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits), big.NewInt(1))
+	}
+	return f.Api.Select(
+		is_valid,
+		f.Api.Select(
+			x.Sign,
+			f.Api.Neg(magnitude),
+			magnitude,
+		),
+		indefinite,
+	), is_invalid
+}
+
+// Convert an integer `v` with `bitWidth` bits to a float, as specified by the `convertFromInt` operation
+// in IEEE 754.
+// If `signed` is true, `v` should be in the range `[-2^(bitWidth - 1), 2^(bitWidth - 1) - 1]`, where a
+// negative integer is represented as `r - |v|` as in `Self::to_int`. Otherwise, `v` should be in the range
+// `[0, 2^bitWidth - 1]`. The range of `v` is enforced by the circuit.
+// If `v` is not representable, the result is rounded according to the rounding mode, and the inexact and
+// overflow flags are raised if they are tracked.
+func (f *Context) FromInt(v frontend.Variable, bitWidth uint, signed bool) FloatVar {
+	// The exact conversion is possible if every integer with `bitWidth` bits fits in the mantissa and is less
+	// than `2^E_MAX`.
+	is_exact := bitWidth <= f.M+1 && f.E_MAX.Cmp(big.NewInt(int64(bitWidth))) >= 0
+	// We normalize the integer to a `width`-bit mantissa with the most significant bit 1, where `width` is
+	// `M + 1` in the exact case, and at least `M + 2` otherwise as required by `Self::round`.
+	width := bitWidth
+	if is_exact {
+		width = f.M + 1
+	} else if width < f.M+2 {
+		width = f.M + 2
+	}
+	// The normalized mantissa has at most `bitWidth + width - 1` bits, which should not overflow.
+	if bitWidth+width-1 >= uint(f.Api.Compiler().Field().BitLen()) {
+		panic("bitWidth is too large for the native field")
+	}
+	if result, ok := f.foldFromInt(v, bitWidth, signed); ok {
+		return result
+	}
+
+	var magnitude, sign frontend.Variable
+	if signed {
+		// Enforce that `v + 2^(bitWidth - 1)` has `bitWidth` bits, i.e., `v` is a signed integer with
+		// `bitWidth` bits.
+		f.Gadget.AssertBitLength(
+			f.Api.Add(v, new(big.Int).Lsh(big.NewInt(1), bitWidth-1)),
+			bitWidth,
+			gadget.TightForUnknownRange,
+		)
+		abs, is_positive := f.Gadget.Abs(v, bitWidth)
+		magnitude = abs
+		sign = f.Api.Sub(big.NewInt(1), is_positive)
+		f.Api.Compiler().MarkBoolean(sign)
+	} else {
+		f.Gadget.AssertBitLength(v, bitWidth, gadget.TightForUnknownRange)
+		magnitude = v
+		sign = big.NewInt(0)
+	}
+	magnitude_is_zero := f.Api.IsZero(magnitude)
+	magnitude_is_not_zero := f.Api.Sub(big.NewInt(1), magnitude_is_zero)
+	f.Api.Compiler().MarkBoolean(magnitude_is_not_zero)
+	// The integer 0 is converted to +0, so we clear the sign in this case, which is otherwise chosen by
+	// the prover.
+	sign = f.Api.And(sign, magnitude_is_not_zero)
+
+	// Find how many bits to shift the magnitude to the left to have the `(width - 1)`-th bit equal to 1
+	// and prodive it as a hint to the circuit
+	outputs, err := f.Api.Compiler().NewHint(hint.NormalizeHint, 1, magnitude, width)
+	if err != nil {
+		panic(err)
+	}
+	shift := outputs[0]
+	// Enforce that `shift <= width - 1`, while `shift >= 0` is guaranteed by `QueryBoundedPowerOf2`.
+	// Unlike `Self::new_float`, a tight upper bound is required here, since `width` may be so large that
+	// `magnitude * 2^shift` overflows if `shift` is only loosely bounded.
+	f.Gadget.AssertBitLength(
+		f.Api.Sub(width-1, shift),
+		uint(big.NewInt(int64(width-1)).BitLen()),
+		gadget.TightForUnknownRange,
+	)
+	two_to_shift := f.Gadget.QueryBoundedPowerOf2(shift, width-1)
+	normalized := f.Api.Mul(magnitude, two_to_shift)
+	// Enforce the normalized mantissa, after removing the leading bit, has only `width - 1` bits, which
+	// implies that `shift` is correct when the magnitude is nonzero (see `Self::new_float`).
+	f.Gadget.AssertBitLength(
+		f.Api.Sub(
+			normalized,
+			f.Api.Mul(magnitude_is_not_zero, new(big.Int).Lsh(big.NewInt(1), width-1)),
+		),
+		width-1,
+		gadget.TightForSmallAbs,
+	)
+	exponent := f.Api.Sub(width-1, shift)
+
+	if is_exact {
+		return FloatVar{
+			Sign:       sign,
+			Exponent:   f.Api.Select(magnitude_is_zero, f.E_MIN, exponent),
+			Mantissa:   normalized,
+			IsAbnormal: 0,
+		}
+	}
+
+	mantissa, is_inexact := f.round(normalized, width, 0, 0, 1, sign)
+
+	if f.E_MAX.Cmp(big.NewInt(int64(width-1))) < 0 {
+		// Clamp the exponent to `E_MAX`, which keeps the difference computed in `Self::fix_overflow` small
+		// but does not change the result, as in `Convert`.
+		diff_length := uint(big.NewInt(int64(width)).BitLen())
+		if diff_length < f.E {
+			diff_length = f.E
+		}
+		exponent = f.Gadget.Min(exponent, f.E_MAX, diff_length+1)
+	}
+	mantissa, exponent, is_abnormal, is_overflow := f.fixOverflow(
+		mantissa,
+		magnitude_is_zero,
+		exponent,
+		0,
+		sign,
+	)
+
+	result := FloatVar{
+		Sign:       sign,
+		Exponent:   exponent,
+		Mantissa:   mantissa,
+		IsAbnormal: is_abnormal,
+	}
+	f.raiseFlags(nil, result, nil, is_inexact, nil, is_overflow)
+	return result
+}
+
+// Compute `x * 2^n` for an integer `n` with `bitWidth` bits, as specified by the `scaleB` operation in IEEE 754,
+// where `n` should be in the range `[-2^(bitWidth - 1), 2^(bitWidth - 1) - 1]` and a negative integer is
+// represented as in `Self::from_int`. The range of `n` is enforced by the circuit.
+// Only the exponent of `x` changes, so the result is exact unless it overflows, or it is subnormal and the lower
+// bits of the mantissa are rounded off according to the rounding mode. NaN, infinity and zero are returned as is.
+func (f *Context) ScaleB(x FloatVar, n frontend.Variable, bitWidth uint) FloatVar {
+	if c, ok := f.intConstant(n, bitWidth, true); ok {
+		return f.scaleBConstant(x, c)
+	}
+
+	// Enforce that `n + 2^(bitWidth - 1)` has `bitWidth` bits, i.e., `n` is a signed integer with `bitWidth`
+	// bits.
+	bound := new(big.Int).Lsh(big.NewInt(1), bitWidth-1)
+	f.Gadget.AssertBitLength(f.Api.Add(n, bound), bitWidth, gadget.TightForUnknownRange)
+	return f.scaleB(x, n, new(big.Int).Neg(bound), new(big.Int).Sub(bound, big.NewInt(1)))
+}
+
+// Compute `x * 2^n` for an integer `n` known at compile time, which is cheaper than `Self::scale_b`, as `n` needs
+// no range check, and a non-negative `n` never requires rounding.
+// To scale by an exponent computed in the circuit, e.g., the one returned by `Self::frexp`, use `Self::scale_b`.
+func (f *Context) Ldexp(x FloatVar, n int) FloatVar {
+	return f.scaleBConstant(x, big.NewInt(int64(n)))
+}
+
+// Compute `x * 2^n` for a constant `n`.
+func (f *Context) scaleBConstant(x FloatVar, n *big.Int) FloatVar {
+	// Scaling a nonzero finite number by `2^(2^E + M)` always overflows, and scaling it by `2^-(2^E + M)` always
+	// rounds it to zero or to the smallest subnormal number, so we clamp `n` to this range.
+	n_max := new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), f.E), big.NewInt(int64(f.M)))
+	if n.CmpAbs(n_max) > 0 {
+		n = new(big.Int).Mul(n_max, big.NewInt(int64(n.Sign())))
+	}
+	if result, ok := f.foldScaleB(x, n); ok {
+		return result
+	}
+	return f.scaleB(x, n, n, n)
+}
+
+// Compute `x * 2^n`, where `n` is known to be in the range `[n_min, n_max]`.
+func (f *Context) scaleB(x FloatVar, n frontend.Variable, n_min, n_max *big.Int) FloatVar {
+	exponent := f.Api.Add(x.Exponent, n)
+	// If `n` is large in magnitude, we clamp the exponent to `[E_NORMAL_MIN - M - 2, E_MAX]`, which keeps the
+	// differences computed in `Self::round_subnormal` and `Self::fix_overflow` small but does not change the
+	// result, as an exponent of at least `E_MAX` overflows, and a number whose exponent is at most
+	// `E_NORMAL_MIN - M - 2` is less than half of the smallest subnormal number.
+	// The distance between the exponent and either bound is less than `2^E + M + max(|n_min|, |n_max|)`.
+	n_abs := new(big.Int).Abs(n_min)
+	if n_max.CmpAbs(n_abs) > 0 {
+		n_abs = new(big.Int).Abs(n_max)
+	}
+	diff_length := uint(new(big.Int).Add(new(big.Int).Add(new(big.Int).Lsh(big.NewInt(1), f.E), big.NewInt(int64(f.M))), n_abs).BitLen())
+	if n_max.Cmp(f.E_MAX) > 0 {
+		exponent = f.Gadget.Min(exponent, f.E_MAX, diff_length)
+	}
+	if new(big.Int).Neg(n_min).Cmp(f.E_MAX) > 0 {
+		exponent = f.Gadget.Max(exponent, new(big.Int).Sub(f.E_NORMAL_MIN, big.NewInt(int64(f.M+2))), diff_length)
+	}
+
+	mantissa := x.Mantissa
+	var is_inexact, is_tiny frontend.Variable
+	if n_min.Sign() < 0 {
+		// The result may be subnormal, in which case its lower bits are rounded off. As `Self::round` requires a
+		// mantissa with at least `M + 2` bits, we double the mantissa, whose lowest bit is then 0.
+		// The exponent of infinity is kept, so that its mantissa is not rounded to 0, which would turn it into NaN.
+		if !f.FiniteOnly {
+			exponent = f.Api.Select(x.IsAbnormal, x.Exponent, exponent)
+		}
+		mantissa, exponent, is_inexact, is_tiny = f.roundSubnormal(
+			f.Api.Add(x.Mantissa, x.Mantissa),
+			f.M+2,
+			exponent,
+			f.M+2,
+			1,
+			x.Sign,
+		)
+	}
+
+	mantissa_is_zero := f.Api.IsZero(mantissa)
+	mantissa, exponent, is_abnormal, is_overflow := f.fixOverflow(
+		mantissa,
+		mantissa_is_zero,
+		exponent,
+		x.IsAbnormal,
+		x.Sign,
+	)
+	if !f.FiniteOnly {
+		// As in `Self::mul`, reset NaN's mantissa to 0.
+		mantissa = f.Api.Select(
+			mantissa_is_zero,
+			big.NewInt(0),
+			mantissa,
+		)
+	}
+	result := FloatVar{
+		Sign:       x.Sign,
+		Exponent:   exponent,
+		Mantissa:   mantissa,
+		IsAbnormal: is_abnormal,
+	}
+	f.raiseFlags([]FloatVar{x}, result, nil, is_inexact, is_tiny, is_overflow)
+	return result
+}
+
+// Return the number of bits of the signed integers that represent the exponents of all nonzero finite numbers,
+// which are in the range `[E_NORMAL_MIN - M, E_MAX - 1]`. This is `E + 1` for the IEEE 754 formats.
+func (f *Context) exponentBitWidth() uint {
+	return uint(new(big.Int).Sub(big.NewInt(int64(f.M)), f.E_NORMAL_MIN).BitLen()) + 1
+}
+
+// Decompose `x` into a fraction `frac` and an integer `exp` such that `x = frac * 2^exp` and
+// `0.5 <= |frac| < 1`, as `frexp` in C. If `x` is zero, NaN or infinity, `frac` is `x` itself and `exp` is 0.
+// This only moves the exponent of `x` and raises no exception. `exp` is represented as in `Self::from_int` and
+// has `E + 1` bits for the IEEE 754 formats, e.g., `Self::scale_b(frac, exp, E + 1)` is `x`.
+func (f *Context) Frexp(x FloatVar) (FloatVar, frontend.Variable) {
+	// `x` is kept as is if it is abnormal, or if it is zero, which is the only finite number with a zero mantissa.
+	is_special := f.Api.Select(x.IsAbnormal, 1, f.Api.IsZero(x.Mantissa))
+	return FloatVar{
+		Sign:       x.Sign,
+		Exponent:   f.Api.Select(is_special, x.Exponent, big.NewInt(-1)),
+		Mantissa:   x.Mantissa,
+		IsAbnormal: x.IsAbnormal,
+	}, f.Api.Select(is_special, big.NewInt(0), f.Api.Add(x.Exponent, big.NewInt(1)))
+}
+
+// Return the exponent of `x` as a number, i.e., `floor(log2(|x|))` for nonzero finite `x`, as specified by the
+// `logB` operation in IEEE 754 with a floating-point result, which is `logb` in C. Since the mantissa of a
+// subnormal number is normalized, its exponent is less than `E_NORMAL_MIN`, e.g., -149 for 2^-149 in binary32.
+// `logb(±0)` is -infinity and raises the division by zero exception, `logb(±infinity)` is +infinity, and
+// `logb(NaN)` is NaN. In the finite-only mode, the circuit is unsatisfiable if `x` is zero.
+func (f *Context) Logb(x FloatVar) FloatVar {
+	if result, ok := f.foldLogb(x); ok {
+		return result
+	}
+
+	var x_is_zero frontend.Variable
+	if f.FiniteOnly {
+		f.Api.AssertIsDifferent(x.Mantissa, 0)
+		x_is_zero = big.NewInt(0)
+	} else {
+		x_is_zero = f.IsZero(x)
+	}
+	is_special := f.Api.Select(x.IsAbnormal, 1, f.Api.IsZero(x.Mantissa))
+	// The exponent of a nonzero finite number is an integer with `exponentBitWidth` bits, which is converted
+	// exactly.
+	exponent := f.FromInt(f.Api.Select(is_special, big.NewInt(0), x.Exponent), f.exponentBitWidth(), true)
+
+	result := f.Select(
+		x_is_zero,
+		FloatVar{
+			Sign:       big.NewInt(1),
+			Exponent:   f.E_MAX,
+			Mantissa:   new(big.Int).Lsh(big.NewInt(1), f.M),
+			IsAbnormal: big.NewInt(1),
+		},
+		// `logb` of infinity is +infinity, and NaN is propagated.
+		f.Select(x.IsAbnormal, f.Abs(x), exponent),
+	)
+	f.raiseFlags([]FloatVar{x}, result, x_is_zero, nil, nil, nil)
+	return result
+}
+
 func (f *Context) Select(c frontend.Variable, x, y FloatVar) FloatVar {
 	return FloatVar{
 		Sign:       f.Api.Select(c, x.Sign, y.Sign),
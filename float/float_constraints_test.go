@@ -4,13 +4,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+
+	"github.com/tumberger/zk-Location/util"
 )
 
 type Param struct {
@@ -20,8 +25,11 @@ type Param struct {
 }
 
 var params = []Param{
+	{E: 5, M: 10, name: "F16"},
+	{E: 8, M: 7, name: "BF16"},
 	{E: 8, M: 23, name: "F32"},
 	{E: 11, M: 52, name: "F64"},
+	{E: 15, M: 112, name: "F128"},
 }
 
 type Constraints struct {
@@ -36,6 +44,7 @@ type FloatCircuit struct {
 	E      uint
 	M      uint
 	size   uint
+	option func(*Context) // An optional function that configures the context before allocating numbers
 	result []Constraints
 }
 
@@ -54,6 +63,9 @@ func count_constraints(ctx Context, f func()) Constraints {
 
 func (c *FloatCircuit) Define(api frontend.API) error {
 	ctx := NewContext(api, c.size, c.E, c.M)
+	if c.option != nil {
+		c.option(&ctx)
+	}
 
 	x := ctx.NewFloat(c.X)
 	y := ctx.NewFloat(c.Y)
@@ -135,3 +147,478 @@ func TestFloatCircuitConstraints(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// Compare the number of constraints for each operation between the default context and the context
+// configured by `option`, and write the results to `../benchmarks/float/<file>`.
+// The operations in `cheaper` should be strictly cheaper with `option`.
+func compareConstraints(t *testing.T, file string, option func(*Context), cheaper []string) {
+	ops := []string{"Init", "Add", "Sub", "Mul", "Div", "Sqrt", "Cmp"}
+
+	var result_all strings.Builder
+	result_all.WriteString("Type, T_RC, Op, #Constraints (Default), #Constraints (Configured)\n")
+
+	for _, param := range params {
+		for _, size := range []uint{8, 12, 16} {
+			var circuits [2]*FloatCircuit
+			for i, o := range []func(*Context){nil, option} {
+				circuits[i] = &FloatCircuit{E: param.E, M: param.M, size: size, option: o}
+				_, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuits[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for i, op := range ops {
+				c := circuits[0].result[i]
+				d := circuits[1].result[i]
+				result_all.WriteString(param.name + ", " + fmt.Sprint(size) + ", " + op + ", ")
+				result_all.WriteString(fmt.Sprint(c.native) + " + " + fmt.Sprint(c.lookup_query) + " + " + fmt.Sprint(c.lookup_global) + "/n, ")
+				result_all.WriteString(fmt.Sprint(d.native) + " + " + fmt.Sprint(d.lookup_query) + " + " + fmt.Sprint(d.lookup_global) + "/n\n")
+
+				if slices.Contains(cheaper, op) && d.native+d.lookup_query >= c.native+c.lookup_query {
+					t.Errorf("%s (T_RC = %d): %s costs %d constraints when configured, but %d by default", param.name, size, op, d.native+d.lookup_query, c.native+c.lookup_query)
+				}
+			}
+		}
+	}
+
+	err := os.WriteFile(filepath.Join(basepath, "../benchmarks/float/"+file), []byte(result_all.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFloatCircuitConstraintsFiniteOnly(t *testing.T) {
+	compareConstraints(
+		t,
+		"constraints_finite_only.csv",
+		func(ctx *Context) { ctx.FiniteOnly = true },
+		[]string{"Init", "Add", "Sub", "Mul", "Div", "Sqrt"},
+	)
+}
+
+func TestFloatCircuitConstraintsFlushSubnormals(t *testing.T) {
+	// `Add` and `Sub` are slightly more expensive, as their results need to be flushed.
+	// Whether the savings in the other operations outweigh this overhead depends on the workload, e.g., in
+	// `TestLoc2Index64Constraints`, flushing saves 1.7% of the PLONK constraints at the cost of 0.05% more
+	// R1CS constraints, while in `TestLoc2Index32Constraints`, it costs 3.3% and 1.0% more, respectively.
+	compareConstraints(
+		t,
+		"constraints_flush_subnormals.csv",
+		func(ctx *Context) { ctx.FlushSubnormals = true },
+		[]string{"Init", "Mul", "Div"},
+	)
+}
+
+// The operations with a constant right operand whose costs are compared in
+// `TestFloatCircuitConstraintsConstantOperand`.
+var constantOperandOps = []struct {
+	op string
+	c  float64
+}{
+	{"Add", 0},
+	{"Mul", 2},
+	{"Mul", 0.5},
+	{"Mul", 6},
+	{"Mul", util.Sin60_64},
+	{"Div", 2},
+	{"Div", 6},
+	{"Div", util.Sin60_64},
+}
+
+// `ConstantOperandConstraintsCircuit` measures each operation in `constantOperandOps` with a variable right
+// operand and with a constant one.
+type ConstantOperandConstraintsCircuit struct {
+	X      frontend.Variable `gnark:",secret"`
+	Y      frontend.Variable `gnark:",secret"`
+	E      uint
+	M      uint
+	size   uint
+	result [][2]Constraints
+}
+
+func (c *ConstantOperandConstraintsCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, c.size, c.E, c.M)
+
+	x := ctx.NewFloat(c.X)
+	y := ctx.NewFloat(c.Y)
+
+	for _, o := range constantOperandOps {
+		var result [2]Constraints
+		for i, operand := range []FloatVar{y, ctx.NewBigConstant(util.F64ToBits(o.c, uint64(c.E), uint64(c.M)))} {
+			result[i] = count_constraints(ctx, func() {
+				reflect.ValueOf(&ctx).MethodByName(o.op).Call([]reflect.Value{reflect.ValueOf(x), reflect.ValueOf(operand)})
+			})
+		}
+		c.result = append(c.result, result)
+	}
+
+	return nil
+}
+
+func TestFloatCircuitConstraintsConstantOperand(t *testing.T) {
+	var result_all strings.Builder
+	result_all.WriteString("Type, T_RC, Op, #Constraints (Variable), #Constraints (Constant)\n")
+
+	for _, param := range params {
+		for _, size := range []uint{8, 12, 16} {
+			circuit := &ConstantOperandConstraintsCircuit{E: param.E, M: param.M, size: size}
+			_, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, o := range constantOperandOps {
+				c := circuit.result[i][0]
+				d := circuit.result[i][1]
+				op := fmt.Sprintf("%s %v", o.op, o.c)
+				result_all.WriteString(param.name + ", " + fmt.Sprint(size) + ", " + op + ", ")
+				result_all.WriteString(fmt.Sprint(c.native) + " + " + fmt.Sprint(c.lookup_query) + " + " + fmt.Sprint(c.lookup_global) + "/n, ")
+				result_all.WriteString(fmt.Sprint(d.native) + " + " + fmt.Sprint(d.lookup_query) + " + " + fmt.Sprint(d.lookup_global) + "/n\n")
+
+				if d.native+d.lookup_query >= c.native+c.lookup_query {
+					t.Errorf("%s (T_RC = %d): %s costs %d constraints with a constant operand, but %d with a variable", param.name, size, op, d.native+d.lookup_query, c.native+c.lookup_query)
+				}
+			}
+		}
+	}
+
+	err := os.WriteFile(filepath.Join(basepath, "../benchmarks/float/constraints_constant_operand.csv"), []byte(result_all.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// `MixedFormatCircuit` computes with both binary32 and binary64 numbers, where the two contexts either have
+// their own gadgets or share a gadget sized to binary64.
+type MixedFormatCircuit struct {
+	X      frontend.Variable `gnark:",secret"`
+	Y      frontend.Variable `gnark:",secret"`
+	size   uint
+	shared bool
+}
+
+func (c *MixedFormatCircuit) Define(api frontend.API) error {
+	var f32, f64 Context
+	if c.shared {
+		g := NewGadget(api, c.size, 11, 52)
+		f32 = NewContextWithGadget(api, g, 8, 23)
+		f64 = NewContextWithGadget(api, g, 11, 52)
+	} else {
+		f32 = NewContext(api, c.size, 8, 23)
+		f64 = NewContext(api, c.size, 11, 52)
+	}
+
+	x := f32.NewFloat(c.X)
+	y := f64.NewFloat(c.Y)
+	// Compute `x * x + y` in binary64, and divide the result rounded to binary32 by `x`.
+	xx := Convert(&f32, &f64, x)
+	z := Convert(&f64, &f32, f64.Add(f64.Mul(xx, xx), y))
+	f32.Div(z, x)
+
+	return nil
+}
+
+func TestFloatCircuitConstraintsSharedGadget(t *testing.T) {
+	var result_all strings.Builder
+	result_all.WriteString("T_RC, Backend, #Constraints (Separate), #Constraints (Shared)\n")
+
+	for _, size := range []uint{8, 12, 16} {
+		for _, b := range []struct {
+			name    string
+			builder frontend.NewBuilder
+		}{
+			{"R1CS", r1cs.NewBuilder},
+			{"PLONK", scs.NewBuilder},
+		} {
+			var result [2]int
+			for i, shared := range []bool{false, true} {
+				cs, err := frontend.Compile(ecc.BN254.ScalarField(), b.builder, &MixedFormatCircuit{size: size, shared: shared})
+				if err != nil {
+					t.Fatal(err)
+				}
+				result[i] = cs.GetNbConstraints()
+			}
+			result_all.WriteString(fmt.Sprint(size) + ", " + b.name + ", " + fmt.Sprint(result[0]) + ", " + fmt.Sprint(result[1]) + "\n")
+
+			if result[1] >= result[0] {
+				t.Errorf("%s (T_RC = %d): sharing the gadget costs %d constraints, but %d without sharing", b.name, size, result[1], result[0])
+			}
+		}
+	}
+
+	err := os.WriteFile(filepath.Join(basepath, "../benchmarks/float/constraints_shared_gadget.csv"), []byte(result_all.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The numbers of terms for which `TestFloatCircuitConstraintsDot` compares `Dot` and `Sum` with chained
+// operations.
+var dotLengths = []int{2, 3, 4, 8, 16}
+
+// `DotConstraintsCircuit` measures the dot product and the sum of `n` numbers for each `n` in `dotLengths`,
+// computed by `Dot` and `Sum` and by chained `Mul` and `Add` respectively. The dot product is skipped for
+// binary128, whose products overflow the native field.
+type DotConstraintsCircuit struct {
+	X      frontend.Variable `gnark:",secret"`
+	Y      frontend.Variable `gnark:",secret"`
+	E      uint
+	M      uint
+	size   uint
+	result [][4]Constraints
+}
+
+func (c *DotConstraintsCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, c.size, c.E, c.M)
+
+	x := ctx.NewFloat(c.X)
+	y := ctx.NewFloat(c.Y)
+
+	for _, n := range dotLengths {
+		xs := make([]FloatVar, n)
+		ys := make([]FloatVar, n)
+		for i := range xs {
+			xs[i] = x
+			ys[i] = y
+		}
+		var result [4]Constraints
+		if c.E+c.M+1 <= 64 {
+			result[0] = count_constraints(ctx, func() {
+				z := ctx.Mul(xs[0], ys[0])
+				for i := 1; i < n; i++ {
+					z = ctx.Add(z, ctx.Mul(xs[i], ys[i]))
+				}
+			})
+			result[1] = count_constraints(ctx, func() { ctx.Dot(xs, ys) })
+		}
+		result[2] = count_constraints(ctx, func() {
+			z := xs[0]
+			for i := 1; i < n; i++ {
+				z = ctx.Add(z, xs[i])
+			}
+		})
+		result[3] = count_constraints(ctx, func() { ctx.Sum(xs) })
+		c.result = append(c.result, result)
+	}
+
+	return nil
+}
+
+func TestFloatCircuitConstraintsDot(t *testing.T) {
+	var result_all strings.Builder
+	result_all.WriteString("Type, T_RC, Op, n, #Constraints (Chained), #Constraints (Exact)\n")
+
+	for _, param := range params {
+		for _, size := range []uint{8, 12, 16} {
+			circuit := &DotConstraintsCircuit{E: param.E, M: param.M, size: size}
+			_, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, n := range dotLengths {
+				for j, op := range []string{"Dot", "Sum"} {
+					if op == "Dot" && param.E+param.M+1 > 64 {
+						continue
+					}
+					c := circuit.result[i][2*j]
+					d := circuit.result[i][2*j+1]
+					result_all.WriteString(param.name + ", " + fmt.Sprint(size) + ", " + op + ", " + fmt.Sprint(n) + ", ")
+					result_all.WriteString(fmt.Sprint(c.native) + " + " + fmt.Sprint(c.lookup_query) + " + " + fmt.Sprint(c.lookup_global) + "/n, ")
+					result_all.WriteString(fmt.Sprint(d.native) + " + " + fmt.Sprint(d.lookup_query) + " + " + fmt.Sprint(d.lookup_global) + "/n\n")
+
+					// Aligning each product to the wide accumulator takes a power of two of about 200 bits, i.e.,
+					// several lookups in the small tables of the small formats, so we only require binary32 and
+					// binary64 dot products of four or more terms to be cheaper.
+					if op == "Dot" && n >= 4 && param.M >= 23 && d.native+d.lookup_query >= c.native+c.lookup_query {
+						t.Errorf("%s (T_RC = %d): Dot of %d terms costs %d constraints, but %d when chained", param.name, size, n, d.native+d.lookup_query, c.native+c.lookup_query)
+					}
+				}
+			}
+		}
+	}
+
+	err := os.WriteFile(filepath.Join(basepath, "../benchmarks/float/constraints_dot.csv"), []byte(result_all.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// `WideConstraintsCircuit` measures a sum of `n` numbers and a Horner evaluation of a polynomial of degree `n`
+// for each `n` in `dotLengths`, computed by chained `Add` and `Mul` and by the same chain on `WideFloat`s with
+// a single `Normalize` at the end respectively.
+type WideConstraintsCircuit struct {
+	X      frontend.Variable `gnark:",secret"`
+	Y      frontend.Variable `gnark:",secret"`
+	E      uint
+	M      uint
+	size   uint
+	result [][4]Constraints
+}
+
+func (c *WideConstraintsCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, c.size, c.E, c.M)
+
+	x := ctx.NewFloat(c.X)
+	y := ctx.NewFloat(c.Y)
+
+	for _, n := range dotLengths {
+		var result [4]Constraints
+		result[0] = count_constraints(ctx, func() {
+			z := x
+			for i := 1; i < n; i++ {
+				z = ctx.Add(z, x)
+			}
+		})
+		result[1] = count_constraints(ctx, func() {
+			w := ctx.Widen(x)
+			z := w
+			for i := 1; i < n; i++ {
+				z = ctx.WideAdd(z, w)
+			}
+			ctx.Normalize(z)
+		})
+		result[2] = count_constraints(ctx, func() {
+			z := x
+			for i := 0; i < n; i++ {
+				z = ctx.Add(ctx.Mul(z, y), x)
+			}
+		})
+		result[3] = count_constraints(ctx, func() {
+			w := ctx.Widen(x)
+			v := ctx.Widen(y)
+			z := w
+			for i := 0; i < n; i++ {
+				z = ctx.WideAdd(ctx.WideMul(z, v), w)
+			}
+			ctx.Normalize(z)
+		})
+		c.result = append(c.result, result)
+	}
+
+	return nil
+}
+
+func TestFloatCircuitConstraintsWide(t *testing.T) {
+	var result_all strings.Builder
+	result_all.WriteString("Type, T_RC, Op, n, #Constraints (Rounded), #Constraints (Wide)\n")
+
+	for _, param := range params {
+		for _, size := range []uint{8, 12, 16} {
+			circuit := &WideConstraintsCircuit{E: param.E, M: param.M, size: size}
+			_, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, n := range dotLengths {
+				for j, op := range []string{"Sum", "Horner"} {
+					c := circuit.result[i][2*j]
+					d := circuit.result[i][2*j+1]
+					result_all.WriteString(param.name + ", " + fmt.Sprint(size) + ", " + op + ", " + fmt.Sprint(n) + ", ")
+					result_all.WriteString(fmt.Sprint(c.native) + " + " + fmt.Sprint(c.lookup_query) + " + " + fmt.Sprint(c.lookup_global) + "/n, ")
+					result_all.WriteString(fmt.Sprint(d.native) + " + " + fmt.Sprint(d.lookup_query) + " + " + fmt.Sprint(d.lookup_global) + "/n\n")
+
+					// `Normalize` costs about as much as one `Add`, which a sum of two numbers already needs, so
+					// wide sums only pay off from four terms on, while every wide Horner step saves a rounding.
+					if (op == "Horner" || n >= 4) && d.native+d.lookup_query >= c.native+c.lookup_query {
+						t.Errorf("%s (T_RC = %d): wide %s of %d terms costs %d constraints, but %d when rounded", param.name, size, op, n, d.native+d.lookup_query, c.native+c.lookup_query)
+					}
+				}
+			}
+		}
+	}
+
+	err := os.WriteFile(filepath.Join(basepath, "../benchmarks/float/constraints_wide.csv"), []byte(result_all.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The operations whose costs are compared in `TestFloatCircuitConstraintsRoot`, where "Unit" computes
+// `(x, y) / sqrt(x * x + y * y)` as in `loc2index.CalculateHex2d`.
+var rootOps = []string{"Rsqrt", "Hypot", "Unit"}
+
+// `RootConstraintsCircuit` measures each operation in `rootOps` computed by `Sqrt` and `Div` and by `Rsqrt`
+// and `Hypot` respectively. "Unit" divides by `Hypot` rather than multiplying by `Rsqrt`, since a `Mul` costs
+// about as much as a `Div` and the reciprocal would add a rounding error. Binary128 is not supported by `Rsqrt`
+// and `Hypot`, whose intermediate values overflow the native field.
+type RootConstraintsCircuit struct {
+	X      frontend.Variable `gnark:",secret"`
+	Y      frontend.Variable `gnark:",secret"`
+	E      uint
+	M      uint
+	size   uint
+	result [][2]Constraints
+}
+
+func (c *RootConstraintsCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, c.size, c.E, c.M)
+
+	x := ctx.NewFloat(c.X)
+	y := ctx.NewFloat(c.Y)
+	one := ctx.Const(1)
+
+	for _, ops := range [][2]func(){
+		{
+			func() { ctx.Div(one, ctx.Sqrt(x)) },
+			func() { ctx.Rsqrt(x) },
+		},
+		{
+			func() { ctx.Sqrt(ctx.Add(ctx.Mul(x, x), ctx.Mul(y, y))) },
+			func() { ctx.Hypot(x, y) },
+		},
+		{
+			func() {
+				z := ctx.Sqrt(ctx.Add(ctx.Mul(x, x), ctx.Mul(y, y)))
+				ctx.Div(x, z)
+				ctx.Div(y, z)
+			},
+			func() {
+				z := ctx.Hypot(x, y)
+				ctx.Div(x, z)
+				ctx.Div(y, z)
+			},
+		},
+	} {
+		c.result = append(c.result, [2]Constraints{count_constraints(ctx, ops[0]), count_constraints(ctx, ops[1])})
+	}
+
+	return nil
+}
+
+func TestFloatCircuitConstraintsRoot(t *testing.T) {
+	var result_all strings.Builder
+	result_all.WriteString("Type, T_RC, Op, #Constraints (Sqrt and Div), #Constraints (Root)\n")
+
+	for _, param := range params {
+		if param.M > 52 {
+			continue
+		}
+		for _, size := range []uint{8, 12, 16} {
+			circuit := &RootConstraintsCircuit{E: param.E, M: param.M, size: size}
+			_, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, op := range rootOps {
+				c := circuit.result[i][0]
+				d := circuit.result[i][1]
+				result_all.WriteString(param.name + ", " + fmt.Sprint(size) + ", " + op + ", ")
+				result_all.WriteString(fmt.Sprint(c.native) + " + " + fmt.Sprint(c.lookup_query) + " + " + fmt.Sprint(c.lookup_global) + "/n, ")
+				result_all.WriteString(fmt.Sprint(d.native) + " + " + fmt.Sprint(d.lookup_query) + " + " + fmt.Sprint(d.lookup_global) + "/n\n")
+
+				if d.native+d.lookup_query >= c.native+c.lookup_query {
+					t.Errorf("%s (T_RC = %d): %s costs %d constraints with roots, but %d with Sqrt and Div", param.name, size, op, d.native+d.lookup_query, c.native+c.lookup_query)
+				}
+			}
+		}
+	}
+
+	err := os.WriteFile(filepath.Join(basepath, "../benchmarks/float/constraints_root.csv"), []byte(result_all.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
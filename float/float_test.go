@@ -3,7 +3,9 @@ package float
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"math/big"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -15,52 +17,106 @@ import (
 	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/test"
+
+	"github.com/tumberger/zk-Location/util"
 )
 
 type F32UnaryCircuit struct {
-	X  frontend.Variable `gnark:",secret"`
-	Y  frontend.Variable `gnark:",public"`
-	op string
+	X     frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable `gnark:",public"`
+	op    string
+	mode  RoundingMode
+	flags string
 }
 
 func (c *F32UnaryCircuit) Define(api frontend.API) error {
 	ctx := NewContext(api, 0, 8, 23)
+	ctx.RoundingMode = c.mode
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
 	x := ctx.NewFloat(c.X)
 	y := ctx.NewFloat(c.Y)
 	ctx.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call([]reflect.Value{reflect.ValueOf(x)})[0].Interface().(FloatVar), y)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
 	return nil
 }
 
 type F32BinaryCircuit struct {
-	X  frontend.Variable `gnark:",secret"`
-	Y  frontend.Variable `gnark:",secret"`
-	Z  frontend.Variable `gnark:",public"`
-	op string
+	X     frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable `gnark:",public"`
+	op    string
+	mode  RoundingMode
+	flags string
 }
 
 func (c *F32BinaryCircuit) Define(api frontend.API) error {
 	ctx := NewContext(api, 0, 8, 23)
+	ctx.RoundingMode = c.mode
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
 	x := ctx.NewFloat(c.X)
 	y := ctx.NewFloat(c.Y)
 	z := ctx.NewFloat(c.Z)
 	ctx.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call([]reflect.Value{reflect.ValueOf(x), reflect.ValueOf(y)})[0].Interface().(FloatVar), z)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
+	return nil
+}
+
+type F32TernaryCircuit struct {
+	X     frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable `gnark:",secret"`
+	W     frontend.Variable `gnark:",public"`
+	op    string
+	mode  RoundingMode
+	flags string
+}
+
+func (c *F32TernaryCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, 8, 23)
+	ctx.RoundingMode = c.mode
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
+	x := ctx.NewFloat(c.X)
+	y := ctx.NewFloat(c.Y)
+	z := ctx.NewFloat(c.Z)
+	w := ctx.NewFloat(c.W)
+	ctx.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call([]reflect.Value{reflect.ValueOf(x), reflect.ValueOf(y), reflect.ValueOf(z)})[0].Interface().(FloatVar), w)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
 	return nil
 }
 
 type F32ComparisonCircuit struct {
-	X  frontend.Variable `gnark:",secret"`
-	Y  frontend.Variable `gnark:",secret"`
-	Z  frontend.Variable `gnark:",public"`
-	op string
+	X     frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable `gnark:",public"`
+	op    string
+	flags string
 }
 
 func (c *F32ComparisonCircuit) Define(api frontend.API) error {
 	ctx := NewContext(api, 0, 8, 23)
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
 	x := ctx.NewFloat(c.X)
 	y := ctx.NewFloat(c.Y)
 	z := c.Z
 	api.AssertIsBoolean(z)
 	api.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call([]reflect.Value{reflect.ValueOf(x), reflect.ValueOf(y)})[0].Interface(), z)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
 	return nil
 }
 
@@ -91,49 +147,101 @@ func (c *F32ConversionCircuit) Define(api frontend.API) error {
 }
 
 type F64UnaryCircuit struct {
-	X  frontend.Variable `gnark:",secret"`
-	Y  frontend.Variable `gnark:",public"`
-	op string
+	X     frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable `gnark:",public"`
+	op    string
+	mode  RoundingMode
+	flags string
 }
 
 func (c *F64UnaryCircuit) Define(api frontend.API) error {
 	ctx := NewContext(api, 0, 11, 52)
+	ctx.RoundingMode = c.mode
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
 	x := ctx.NewFloat(c.X)
 	y := ctx.NewFloat(c.Y)
 	ctx.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call([]reflect.Value{reflect.ValueOf(x)})[0].Interface().(FloatVar), y)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
 	return nil
 }
 
 type F64BinaryCircuit struct {
-	X  frontend.Variable `gnark:",secret"`
-	Y  frontend.Variable `gnark:",secret"`
-	Z  frontend.Variable `gnark:",public"`
-	op string
+	X     frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable `gnark:",public"`
+	op    string
+	mode  RoundingMode
+	flags string
 }
 
 func (c *F64BinaryCircuit) Define(api frontend.API) error {
 	ctx := NewContext(api, 0, 11, 52)
+	ctx.RoundingMode = c.mode
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
 	x := ctx.NewFloat(c.X)
 	y := ctx.NewFloat(c.Y)
 	z := ctx.NewFloat(c.Z)
 	ctx.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call([]reflect.Value{reflect.ValueOf(x), reflect.ValueOf(y)})[0].Interface().(FloatVar), z)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
+	return nil
+}
+
+type F64TernaryCircuit struct {
+	X     frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable `gnark:",secret"`
+	W     frontend.Variable `gnark:",public"`
+	op    string
+	mode  RoundingMode
+	flags string
+}
+
+func (c *F64TernaryCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, 11, 52)
+	ctx.RoundingMode = c.mode
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
+	x := ctx.NewFloat(c.X)
+	y := ctx.NewFloat(c.Y)
+	z := ctx.NewFloat(c.Z)
+	w := ctx.NewFloat(c.W)
+	ctx.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call([]reflect.Value{reflect.ValueOf(x), reflect.ValueOf(y), reflect.ValueOf(z)})[0].Interface().(FloatVar), w)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
 	return nil
 }
 
 type F64ComparisonCircuit struct {
-	X  frontend.Variable `gnark:",secret"`
-	Y  frontend.Variable `gnark:",secret"`
-	Z  frontend.Variable `gnark:",public"`
-	op string
+	X     frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable `gnark:",public"`
+	op    string
+	flags string
 }
 
 func (c *F64ComparisonCircuit) Define(api frontend.API) error {
 	ctx := NewContext(api, 0, 11, 52)
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
 	x := ctx.NewFloat(c.X)
 	y := ctx.NewFloat(c.Y)
 	z := c.Z
 	api.AssertIsBoolean(z)
 	api.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call([]reflect.Value{reflect.ValueOf(x), reflect.ValueOf(y)})[0].Interface(), z)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
 	return nil
 }
 
@@ -163,10 +271,651 @@ func (c *F64ConversionCircuit) Define(api frontend.API) error {
 	return nil
 }
 
+// `FormatCircuit` checks an operation in an arbitrary format with `E`-bit exponent and `M`-bit mantissa,
+// where `X` contains the operands.
+type FormatCircuit struct {
+	X     []frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable   `gnark:",public"`
+	E     uint
+	M     uint
+	op    string
+	flags string
+}
+
+func (c *FormatCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
+	x := make([]reflect.Value, len(c.X))
+	for i := range c.X {
+		x[i] = reflect.ValueOf(ctx.NewFloat(c.X[i]))
+	}
+	y := ctx.NewFloat(c.Y)
+	ctx.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call(x)[0].Interface().(FloatVar), y)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
+	return nil
+}
+
+// `FiniteOnlyCircuit` checks the operation `op` in the finite-only mode, where the expected result `Y` is
+// allocated in a separate context that allows abnormal numbers.
+type FiniteOnlyCircuit struct {
+	X     []frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable   `gnark:",public"`
+	E     uint
+	M     uint
+	op    string
+	mode  RoundingMode
+	flags string
+}
+
+func (c *FiniteOnlyCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	ctx.RoundingMode = c.mode
+	ctx.FiniteOnly = true
+	ctx.EnableFlags()
+	x := make([]reflect.Value, len(c.X))
+	for i := range c.X {
+		x[i] = reflect.ValueOf(ctx.NewFloat(c.X[i]))
+	}
+	result := reflect.ValueOf(&ctx).MethodByName(c.op).Call(x)[0].Interface().(FloatVar)
+	api.AssertIsEqual(result.IsAbnormal, 0)
+
+	expected := NewContext(api, 0, c.E, c.M)
+	expected.AssertIsEqual(result, expected.NewFloat(c.Y))
+	assertFlags(api, ctx.Flags, c.flags)
+	return nil
+}
+
+// `FlushSubnormalsCircuit` checks the operation `op` with subnormal numbers flushed to zero.
+type FlushSubnormalsCircuit struct {
+	X  []frontend.Variable `gnark:",secret"`
+	Y  frontend.Variable   `gnark:",public"`
+	E  uint
+	M  uint
+	op string
+}
+
+func (c *FlushSubnormalsCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	ctx.FlushSubnormals = true
+	x := make([]reflect.Value, len(c.X))
+	for i := range c.X {
+		x[i] = reflect.ValueOf(ctx.NewFloat(c.X[i]))
+	}
+	ctx.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call(x)[0].Interface().(FloatVar), ctx.NewFloat(c.Y))
+	return nil
+}
+
+// `ConstantOperandCircuit` checks the operation `op` on `X` and the constant `c`, where `c` is the left
+// operand if `left` is true and the right operand otherwise.
+type ConstantOperandCircuit struct {
+	X     frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable `gnark:",public"`
+	E     uint
+	M     uint
+	c     *big.Int
+	left  bool
+	op    string
+	mode  RoundingMode
+	flags string
+	flush bool // Whether subnormal numbers are flushed to zero
+}
+
+func (c *ConstantOperandCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	ctx.RoundingMode = c.mode
+	ctx.FlushSubnormals = c.flush
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
+	args := []reflect.Value{reflect.ValueOf(ctx.NewFloat(c.X)), reflect.ValueOf(ctx.NewBigConstant(c.c))}
+	if c.left {
+		args[0], args[1] = args[1], args[0]
+	}
+	ctx.AssertIsEqual(reflect.ValueOf(&ctx).MethodByName(c.op).Call(args)[0].Interface().(FloatVar), ctx.NewFloat(c.Y))
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
+	return nil
+}
+
+// `ConstantFoldingCircuit` checks that the method `op` is folded on the constant operands `c` without any
+// constraints, and that the folded results and flags are equal to those computed by the circuit on the same
+// operands `X` allocated as variables. Each row of `X` and `c` holds the operands of one call.
+// The test engine is skipped, as it treats constants as variables and passes negative constants to hints
+// without reducing them modulo the field order.
+type ConstantFoldingCircuit struct {
+	X     [][]frontend.Variable `gnark:",secret"`
+	E     uint
+	M     uint
+	c     [][]*big.Int
+	op    string
+	mode  RoundingMode
+	flush bool // Whether subnormal numbers are flushed to zero
+}
+
+// Call `op` on `xs`, or on the encoded values `vs` for the operations that take integers.
+// Return the results, together with the flags of all contexts involved.
+func (c *ConstantFoldingCircuit) apply(ctx *Context, xs []FloatVar, vs []frontend.Variable) ([]interface{}, []*Flags) {
+	flags := []*Flags{ctx.Flags}
+	switch c.op {
+	case "NewFloat":
+		return []interface{}{ctx.NewFloat(vs[0])}, flags
+	case "FromInt":
+		return []interface{}{
+			ctx.FromInt(vs[0], c.E+c.M+1, false),
+			ctx.FromInt(ctx.Api.Sub(vs[0], new(big.Int).Lsh(big.NewInt(1), c.E+c.M)), c.E+c.M+1, true),
+		}, flags
+	case "ToIntChecked":
+		var results []interface{}
+		for _, signed := range []bool{false, true} {
+			v, is_invalid := ctx.ToIntChecked(xs[0], 16, signed, c.mode)
+			results = append(results, v, is_invalid)
+		}
+		return results, flags
+	case "ScaleB":
+		var results []interface{}
+		for _, n := range []int{-int(c.M) - 3, -1, 7, 1 << c.E} {
+			results = append(results, ctx.ScaleB(xs[0], n, c.E+2), ctx.Ldexp(xs[0], n))
+		}
+		return results, flags
+	case "UlpDistanceLe":
+		return []interface{}{ctx.UlpDistanceLe(xs[0], xs[1], 1)}, flags
+	case "Dot":
+		return []interface{}{ctx.Dot(xs, []FloatVar{xs[1], xs[2], xs[0]})}, flags
+	case "Sum":
+		return []interface{}{ctx.Sum(xs)}, flags
+	case "Convert":
+		var results []interface{}
+		for _, param := range params {
+			to := NewContext(ctx.Api, 0, param.E, param.M)
+			to.RoundingMode = ctx.RoundingMode
+			to.FlushSubnormals = ctx.FlushSubnormals
+			to.EnableFlags()
+			results = append(results, Convert(ctx, &to, xs[0]))
+			flags = append(flags, to.Flags)
+		}
+		return results, flags
+	}
+	method := reflect.ValueOf(ctx).MethodByName(c.op)
+	args := make([]reflect.Value, method.Type().NumIn())
+	for i := range args {
+		args[i] = reflect.ValueOf(xs[i])
+	}
+	var results []interface{}
+	for _, result := range method.Call(args) {
+		results = append(results, result.Interface())
+	}
+	return results, flags
+}
+
+func (c *ConstantFoldingCircuit) Define(api frontend.API) error {
+	folded := NewContext(api, 0, c.E, c.M)
+	folded.RoundingMode = c.mode
+	folded.FlushSubnormals = c.flush
+	circuit := folded
+
+	for i := range c.X {
+		// Reset the flags, so that each call is checked separately.
+		folded.EnableFlags()
+		circuit.EnableFlags()
+		constants := make([]FloatVar, len(c.c[i]))
+		variables := make([]FloatVar, len(c.X[i]))
+		encoded := make([]frontend.Variable, len(c.c[i]))
+		for j := range c.X[i] {
+			constants[j] = folded.NewBigConstant(c.c[i][j])
+			variables[j] = circuit.NewFloat(c.X[i][j])
+			encoded[j] = c.c[i][j]
+		}
+		_, is_constant := folded.constantsOf(constants...)
+
+		var results []interface{}
+		var flags []*Flags
+		cost := count_constraints(folded, func() {
+			results, flags = c.apply(&folded, constants, encoded)
+		})
+		expected_results, expected_flags := c.apply(&circuit, variables, c.X[i])
+		if is_constant && (cost.native != 0 || cost.lookup_query != 0) {
+			return fmt.Errorf("%s is not folded: %d constraints and %d lookup queries", c.op, cost.native, cost.lookup_query)
+		}
+
+		for j, result := range results {
+			switch result := result.(type) {
+			case FloatVar:
+				if _, ok := folded.constantComponents(result); is_constant && !ok {
+					return fmt.Errorf("%s returns a non-constant number", c.op)
+				}
+				folded.AssertIsEqual(result, expected_results[j].(FloatVar))
+			default:
+				if _, ok := api.Compiler().ConstantValue(result); is_constant && !ok {
+					return fmt.Errorf("%s returns a non-constant value", c.op)
+				}
+				api.AssertIsEqual(result, expected_results[j])
+			}
+		}
+		for j := range flags {
+			api.AssertIsEqual(flags[j].Invalid, expected_flags[j].Invalid)
+			api.AssertIsEqual(flags[j].DivisionByZero, expected_flags[j].DivisionByZero)
+			api.AssertIsEqual(flags[j].Overflow, expected_flags[j].Overflow)
+			api.AssertIsEqual(flags[j].Underflow, expected_flags[j].Underflow)
+			api.AssertIsEqual(flags[j].Inexact, expected_flags[j].Inexact)
+		}
+	}
+	return nil
+}
+
+// `FormatConstantCircuit` checks that the constant created by the method `op` from `v` is equal to the
+// encoded value `X`.
+type FormatConstantCircuit struct {
+	X  frontend.Variable `gnark:",secret"`
+	E  uint
+	M  uint
+	op string
+	v  interface{}
+}
+
+func (c *FormatConstantCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	x := ctx.NewFloat(c.X)
+	ctx.AssertIsEqual(x, reflect.ValueOf(&ctx).MethodByName(c.op).Call([]reflect.Value{reflect.ValueOf(c.v)})[0].Interface().(FloatVar))
+	return nil
+}
+
+// `FormatConversionCircuit` checks the conversion of `X` from one format to another.
+type FormatConversionCircuit struct {
+	X      frontend.Variable `gnark:",secret"`
+	Y      frontend.Variable `gnark:",public"`
+	from   Param
+	to     Param
+	mode   RoundingMode
+	flags  string
+	flush  bool // Whether subnormal numbers are flushed to zero in both formats
+	shared bool // Whether both contexts share a gadget sized to the larger format
+}
+
+func (c *FormatConversionCircuit) Define(api frontend.API) error {
+	var from, to Context
+	if c.shared {
+		g := NewGadget(api, 0, max(c.from.E, c.to.E), max(c.from.M, c.to.M))
+		from = NewContextWithGadget(api, g, c.from.E, c.from.M)
+		to = NewContextWithGadget(api, g, c.to.E, c.to.M)
+	} else {
+		from = NewContext(api, 0, c.from.E, c.from.M)
+		to = NewContext(api, 0, c.to.E, c.to.M)
+	}
+	from.FlushSubnormals = c.flush
+	to.FlushSubnormals = c.flush
+	to.RoundingMode = c.mode
+	if c.flags != "" {
+		to.EnableFlags()
+	}
+	x := from.NewFloat(c.X)
+	y := to.NewFloat(c.Y)
+	to.AssertIsEqual(Convert(&from, &to, x), y)
+	if c.flags != "" {
+		assertFlags(api, to.Flags, c.flags)
+	}
+	return nil
+}
+
+// `FromIntCircuit` checks the conversion of the integer `X` with `bits` bits to a float.
+type FromIntCircuit struct {
+	X      frontend.Variable `gnark:",secret"`
+	Y      frontend.Variable `gnark:",public"`
+	bits   uint
+	signed bool
+	to     Param
+	mode   RoundingMode
+	flags  string
+}
+
+func (c *FromIntCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.to.E, c.to.M)
+	ctx.RoundingMode = c.mode
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
+	y := ctx.NewFloat(c.Y)
+	ctx.AssertIsEqual(ctx.FromInt(c.X, c.bits, c.signed), y)
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
+	return nil
+}
+
+// `ToIntCheckedCircuit` checks the conversion of `X` to an integer with `bits` bits, where `flags` are the
+// expected exception flags.
+type ToIntCheckedCircuit struct {
+	X      frontend.Variable `gnark:",secret"`
+	Y      frontend.Variable `gnark:",public"`
+	from   Param
+	bits   uint
+	signed bool
+	mode   RoundingMode
+	flags  string
+}
+
+func (c *ToIntCheckedCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.from.E, c.from.M)
+	ctx.EnableFlags()
+	x := ctx.NewFloat(c.X)
+	y, is_invalid := ctx.ToIntChecked(x, c.bits, c.signed, c.mode)
+	api.AssertIsEqual(y, c.Y)
+	api.AssertIsEqual(is_invalid, ctx.Flags.Invalid)
+	assertFlags(api, ctx.Flags, c.flags)
+	return nil
+}
+
+// `ClassifyCircuit` checks the classification of `X`, where `class` is the expected class.
+type ClassifyCircuit struct {
+	X     frontend.Variable `gnark:",secret"`
+	E     uint
+	M     uint
+	class Class
+}
+
+func (c *ClassifyCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	x := ctx.NewFloat(c.X)
+	api.AssertIsEqual(ctx.Classify(x), uint64(c.class))
+	is := func(classes ...Class) uint64 {
+		for _, class := range classes {
+			if class == c.class {
+				return 1
+			}
+		}
+		return 0
+	}
+	api.AssertIsEqual(ctx.IsNaN(x), is(ClassNaN))
+	api.AssertIsEqual(ctx.IsInf(x), is(ClassNegativeInfinity, ClassPositiveInfinity))
+	api.AssertIsEqual(ctx.IsZero(x), is(ClassNegativeZero, ClassPositiveZero))
+	api.AssertIsEqual(ctx.IsSubnormal(x), is(ClassNegativeSubnormal, ClassPositiveSubnormal))
+	api.AssertIsEqual(ctx.IsNormal(x), is(ClassNegativeNormal, ClassPositiveNormal))
+	return nil
+}
+
+// `UlpDistanceCircuit` checks whether the distance between `X` and `Y` is at most `n` ULPs, where `expected`
+// is the expected result.
+type UlpDistanceCircuit struct {
+	X        frontend.Variable `gnark:",secret"`
+	Y        frontend.Variable `gnark:",secret"`
+	E        uint
+	M        uint
+	n        uint
+	expected uint64
+}
+
+func (c *UlpDistanceCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	x := ctx.NewFloat(c.X)
+	y := ctx.NewFloat(c.Y)
+	api.AssertIsEqual(ctx.UlpDistanceLe(x, y, c.n), c.expected)
+	return nil
+}
+
+// `EncodeCircuit` checks that the result of the method `op` on the operands `X` is encoded to `Y`, where `X[0]`
+// itself is encoded if `op` is empty, and `X` holds the raw components of a number if `op` is "FloatVar".
+type EncodeCircuit struct {
+	X     []frontend.Variable `gnark:",secret"`
+	Y     frontend.Variable   `gnark:",public"`
+	E     uint
+	M     uint
+	op    string
+	flush bool // Whether subnormal numbers are flushed to zero
+}
+
+func (c *EncodeCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	ctx.FlushSubnormals = c.flush
+	var result FloatVar
+	switch c.op {
+	case "":
+		result = ctx.NewFloat(c.X[0])
+	case "FloatVar":
+		result = FloatVar{Sign: c.X[0], Exponent: c.X[1], Mantissa: c.X[2], IsAbnormal: c.X[3]}
+	default:
+		x := make([]reflect.Value, len(c.X))
+		for i := range c.X {
+			x[i] = reflect.ValueOf(ctx.NewFloat(c.X[i]))
+		}
+		result = reflect.ValueOf(&ctx).MethodByName(c.op).Call(x)[0].Interface().(FloatVar)
+	}
+	api.AssertIsEqual(ctx.Encode(result), c.Y)
+	return nil
+}
+
+// `DotCircuit` checks `Self::sum` of `X[0]` if `X` has one row, or `Self::dot` of `X[0]` and `X[1]` otherwise,
+// against `Z`.
+type DotCircuit struct {
+	X     [][]frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable     `gnark:",public"`
+	E     uint
+	M     uint
+	flags string
+}
+
+func (c *DotCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	ctx.EnableFlags()
+	xs := make([][]FloatVar, len(c.X))
+	for i := range c.X {
+		for j := range c.X[i] {
+			xs[i] = append(xs[i], ctx.NewFloat(c.X[i][j]))
+		}
+	}
+	var result FloatVar
+	if len(xs) == 1 {
+		result = ctx.Sum(xs[0])
+	} else {
+		result = ctx.Dot(xs[0], xs[1])
+	}
+	ctx.AssertIsEqual(result, ctx.NewFloat(c.Z))
+	assertFlags(api, ctx.Flags, c.flags)
+	return nil
+}
+
+// `WideCircuit` checks `op` computed on wide numbers and normalized once against `Z`, where `op` is either
+// `Widen` of `X[0]`, one of `Add`, `Sub` and `Mul` of `X[0]` and `X[1]`, or `Chain`, which alternately adds
+// and multiplies by the remaining operands, i.e., `((X[0] + X[1]) * X[2] + X[3]) * ...`.
+type WideCircuit struct {
+	X     []frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable   `gnark:",public"`
+	E     uint
+	M     uint
+	op    string
+	flags string
+}
+
+func (c *WideCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	ctx.EnableFlags()
+	xs := make([]WideFloat, len(c.X))
+	for i := range c.X {
+		xs[i] = ctx.Widen(ctx.NewFloat(c.X[i]))
+	}
+	result := xs[0]
+	switch c.op {
+	case "Add":
+		result = ctx.WideAdd(xs[0], xs[1])
+	case "Sub":
+		result = ctx.WideSub(xs[0], xs[1])
+	case "Mul":
+		result = ctx.WideMul(xs[0], xs[1])
+	case "Chain":
+		for i := 1; i < len(xs); i++ {
+			if i%2 == 1 {
+				result = ctx.WideAdd(result, xs[i])
+			} else {
+				result = ctx.WideMul(result, xs[i])
+			}
+		}
+	}
+	ctx.AssertIsEqual(ctx.Normalize(result), ctx.NewFloat(c.Z))
+	assertFlags(api, ctx.Flags, c.flags)
+	return nil
+}
+
+// `DoubleCircuit` checks `op` against the pair of words `Z`, where `op` is either `TwoSum` or `TwoProduct` of
+// `X[0]` and `X[1]`, or one of `Add`, `Sub`, `Mul` and `Div` of the double-words `(X[0], X[1])` and
+// `(X[2], X[3])`.
+type DoubleCircuit struct {
+	X  []frontend.Variable  `gnark:",secret"`
+	Z  [2]frontend.Variable `gnark:",public"`
+	E  uint
+	M  uint
+	op string
+}
+
+func (c *DoubleCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	xs := make([]FloatVar, len(c.X))
+	for i := range c.X {
+		xs[i] = ctx.NewFloat(c.X[i])
+	}
+	var hi, lo FloatVar
+	switch c.op {
+	case "TwoSum":
+		hi, lo = ctx.TwoSum(xs[0], xs[1])
+	case "TwoProduct":
+		hi, lo = ctx.TwoProduct(xs[0], xs[1])
+	default:
+		x := DoubleFloat{xs[0], xs[1]}
+		y := DoubleFloat{xs[2], xs[3]}
+		var z DoubleFloat
+		switch c.op {
+		case "Add":
+			z = ctx.DoubleAdd(x, y)
+		case "Sub":
+			z = ctx.DoubleSub(x, y)
+		case "Mul":
+			z = ctx.DoubleMul(x, y)
+		case "Div":
+			z = ctx.DoubleDiv(x, y)
+		}
+		hi, lo = z.Hi, z.Lo
+	}
+	ctx.AssertIsEqual(hi, ctx.NewFloat(c.Z[0]))
+	ctx.AssertIsEqual(lo, ctx.NewFloat(c.Z[1]))
+	return nil
+}
+
+// `RootCircuit` checks `op` of `X` against `Z` and the exception flags, where `op` is one of `Rsqrt`, `Cbrt`
+// and `Hypot`.
+type RootCircuit struct {
+	X     []frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable   `gnark:",public"`
+	E     uint
+	M     uint
+	op    string
+	flags string
+}
+
+func (c *RootCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	ctx.EnableFlags()
+	x := ctx.NewFloat(c.X[0])
+	var result FloatVar
+	switch c.op {
+	case "Rsqrt":
+		result = ctx.Rsqrt(x)
+	case "Cbrt":
+		result = ctx.Cbrt(x)
+	case "Hypot":
+		result = ctx.Hypot(x, ctx.NewFloat(c.X[1]))
+	}
+	ctx.AssertIsEqual(result, ctx.NewFloat(c.Z))
+	assertFlags(api, ctx.Flags, c.flags)
+	return nil
+}
+
+// `ExponentCircuit` checks the operation `op` on the exponent or sign of `X`, i.e., that `ScaleB` and `Ldexp`
+// scale `X` by `2^N` to `Z`, that `Frexp` decomposes `X` into `Z * 2^N`, that `Logb` of `X` is `Z`, and that
+// `Copysign` of `X` and `N` is `Z`, where `flags` are the expected exception flags.
+type ExponentCircuit struct {
+	X     frontend.Variable `gnark:",secret"`
+	N     frontend.Variable `gnark:",secret"`
+	Z     frontend.Variable `gnark:",public"`
+	E     uint
+	M     uint
+	op    string
+	n     int  // The scale of `Ldexp`, which is known at compile time
+	bits  uint // The number of bits of `N` in `ScaleB`
+	mode  RoundingMode
+	flush bool // Whether subnormal numbers are flushed to zero
+	flags string
+}
+
+func (c *ExponentCircuit) Define(api frontend.API) error {
+	ctx := NewContext(api, 0, c.E, c.M)
+	ctx.RoundingMode = c.mode
+	ctx.FlushSubnormals = c.flush
+	if c.flags != "" {
+		ctx.EnableFlags()
+	}
+	x := ctx.NewFloat(c.X)
+	z := ctx.NewFloat(c.Z)
+	switch c.op {
+	case "ScaleB":
+		ctx.AssertIsEqual(ctx.ScaleB(x, c.N, c.bits), z)
+	case "Ldexp":
+		ctx.AssertIsEqual(ctx.Ldexp(x, c.n), z)
+	case "Frexp":
+		frac, exp := ctx.Frexp(x)
+		ctx.AssertIsEqual(frac, z)
+		api.AssertIsEqual(exp, c.N)
+		// Scaling the fraction back is exact.
+		ctx.AssertIsEqual(ctx.ScaleB(frac, exp, c.E+1), x)
+	case "Logb":
+		ctx.AssertIsEqual(ctx.Logb(x), z)
+	case "Copysign":
+		ctx.AssertIsEqual(ctx.Copysign(x, ctx.NewFloat(c.N)), z)
+	}
+	if c.flags != "" {
+		assertFlags(api, ctx.Flags, c.flags)
+	}
+	return nil
+}
+
+// Return the canonical encoding of the encoded `v`, where NaN is replaced with the positive quiet NaN, and
+// subnormal numbers are flushed to zero with the same sign if `flush` is set.
+func canonicalEncoding(v *big.Int, E, M uint, flush bool) *big.Int {
+	components := util.ComponentsOfBig(v, uint64(E), uint64(M))
+	if components[3].Sign() != 0 && components[2].Sign() == 0 {
+		return util.F64ToBits(math.NaN(), uint64(E), uint64(M))
+	}
+	if flush && new(big.Int).Rsh(new(big.Int).SetBit(v, int(E+M), 0), M).Sign() == 0 {
+		return new(big.Int).And(v, new(big.Int).Lsh(big.NewInt(1), E+M))
+	}
+	return v
+}
+
+// Enforce that the exception flags are equal to `expected`, which is in TestFloat's format.
+func assertFlags(api frontend.API, flags *Flags, expected string) {
+	v, _ := strconv.ParseUint(expected, 16, 8)
+	api.AssertIsEqual(flags.Invalid, (v>>4)&1)
+	api.AssertIsEqual(flags.DivisionByZero, (v>>3)&1)
+	api.AssertIsEqual(flags.Overflow, (v>>2)&1)
+	api.AssertIsEqual(flags.Underflow, (v>>1)&1)
+	api.AssertIsEqual(flags.Inexact, v&1)
+}
+
+// Signaling NaNs are not distinguished from quiet NaNs in the circuit, so we cannot check the flags
+// raised by an operation on them.
+func isSignalingNaN(v *big.Int, E, M uint) bool {
+	exponent := new(big.Int).Rsh(v, M)
+	exponent.SetBit(exponent, int(E), 0)
+	mantissa := new(big.Int).And(v, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), M), big.NewInt(1)))
+	return exponent.Cmp(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), E), big.NewInt(1))) == 0 &&
+		mantissa.Sign() != 0 && mantissa.Bit(int(M-1)) == 0
+}
+
 func TestF32UnaryCircuit(t *testing.T) {
 	assert := test.NewAssert(t)
 
-	ops := []string{"Sqrt", "Trunc", "Floor", "Ceil"}
+	ops := []string{"Sqrt", "Trunc", "Floor", "Ceil", "Round", "RoundEven"}
 
 	for _, op := range ops {
 		path, _ := filepath.Abs(fmt.Sprintf("../data/f32/%s", strings.ToLower(op)))
@@ -216,13 +965,140 @@ func TestF32BinaryCircuit(t *testing.T) {
 	}
 }
 
+func TestF32TernaryCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ops := []string{"FMA"}
+
+	for _, op := range ops {
+		path, _ := filepath.Abs(fmt.Sprintf("../data/f32/%s", strings.ToLower(op)))
+		file, err := os.Open(path)
+		assert.NoError(err)
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			data := strings.Fields(scanner.Text())
+			a, _ := new(big.Int).SetString(data[0], 16)
+			b, _ := new(big.Int).SetString(data[1], 16)
+			c, _ := new(big.Int).SetString(data[2], 16)
+			d, _ := new(big.Int).SetString(data[3], 16)
+
+			assert.ProverSucceeded(
+				&F32TernaryCircuit{X: 0, Y: 0, Z: 0, W: 0, op: op},
+				&F32TernaryCircuit{X: a, Y: b, Z: c, W: d, op: op},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16, backend.PLONK),
+			)
+		}
+	}
+}
+
+func TestF32RoundingModesAndFlags(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	modes := []struct {
+		mode   RoundingMode
+		suffix string
+	}{
+		{RoundNearestEven, "rne"},
+		{RoundTowardZero, "rtz"},
+		{RoundTowardPositive, "rup"},
+		{RoundTowardNegative, "rdn"},
+		{RoundNearestAway, "rna"},
+	}
+	ops := []string{"Add", "Sub", "Mul", "Div", "Sqrt", "FMA"}
+
+	for _, m := range modes {
+		for _, op := range ops {
+			path, _ := filepath.Abs(fmt.Sprintf("../data/f32/%s_%s", strings.ToLower(op), m.suffix))
+			file, err := os.Open(path)
+			assert.NoError(err)
+			defer file.Close()
+
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				data := strings.Fields(scanner.Text())
+				// The last field is the exception flags, and the remaining fields are the operands and the result.
+				flags := data[len(data)-1]
+				v := make([]*big.Int, len(data)-1)
+				for i := range v {
+					v[i], _ = new(big.Int).SetString(data[i], 16)
+					if i < len(v)-1 && isSignalingNaN(v[i], 8, 23) {
+						flags = ""
+					}
+				}
+
+				var circuit, assignment frontend.Circuit
+				switch len(v) {
+				case 2:
+					circuit = &F32UnaryCircuit{X: 0, Y: 0, op: op, mode: m.mode, flags: flags}
+					assignment = &F32UnaryCircuit{X: v[0], Y: v[1], op: op, mode: m.mode, flags: flags}
+				case 3:
+					circuit = &F32BinaryCircuit{X: 0, Y: 0, Z: 0, op: op, mode: m.mode, flags: flags}
+					assignment = &F32BinaryCircuit{X: v[0], Y: v[1], Z: v[2], op: op, mode: m.mode, flags: flags}
+				default:
+					circuit = &F32TernaryCircuit{X: 0, Y: 0, Z: 0, W: 0, op: op, mode: m.mode, flags: flags}
+					assignment = &F32TernaryCircuit{X: v[0], Y: v[1], Z: v[2], W: v[3], op: op, mode: m.mode, flags: flags}
+				}
+
+				assert.ProverSucceeded(
+					circuit,
+					assignment,
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16, backend.PLONK),
+				)
+			}
+		}
+	}
+}
+
+func TestF32ExactZeroDoesNotOverflow(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// The exact zero results of `x - x` and `x + (-x)` for `x` with exponent `E_MAX - 1` should be zero rather
+	// than infinity, and `-0` when rounding toward negative infinity.
+	cases := []struct {
+		op   string
+		v    []*big.Int
+		mode RoundingMode
+	}{
+		{"Sub", []*big.Int{big.NewInt(0x7F000000), big.NewInt(0x7F000000), big.NewInt(0x00000000)}, RoundNearestEven},
+		{"Sub", []*big.Int{big.NewInt(0xFF7FFFFF), big.NewInt(0xFF7FFFFF), big.NewInt(0x00000000)}, RoundNearestEven},
+		{"Add", []*big.Int{big.NewInt(0x7F7FFFFF), big.NewInt(0xFF7FFFFF), big.NewInt(0x00000000)}, RoundNearestEven},
+		{"Sub", []*big.Int{big.NewInt(0x7F000000), big.NewInt(0x7F000000), big.NewInt(0x00000000)}, RoundTowardZero},
+		{"Sub", []*big.Int{big.NewInt(0x7F000000), big.NewInt(0x7F000000), big.NewInt(0x00000000)}, RoundTowardPositive},
+		{"Sub", []*big.Int{big.NewInt(0x7F000000), big.NewInt(0x7F000000), big.NewInt(0x80000000)}, RoundTowardNegative},
+		{"Add", []*big.Int{big.NewInt(0x7F7FFFFF), big.NewInt(0xFF7FFFFF), big.NewInt(0x00000000)}, RoundNearestAway},
+		{"FMA", []*big.Int{big.NewInt(0x7F000000), big.NewInt(0x3F800000), big.NewInt(0xFF000000), big.NewInt(0x00000000)}, RoundNearestEven},
+		{"FMA", []*big.Int{big.NewInt(0x7F000000), big.NewInt(0x3F800000), big.NewInt(0xFF000000), big.NewInt(0x80000000)}, RoundTowardNegative},
+	}
+
+	for _, c := range cases {
+		var circuit, assignment frontend.Circuit
+		if len(c.v) == 3 {
+			circuit = &F32BinaryCircuit{X: 0, Y: 0, Z: 0, op: c.op, mode: c.mode}
+			assignment = &F32BinaryCircuit{X: c.v[0], Y: c.v[1], Z: c.v[2], op: c.op, mode: c.mode}
+		} else {
+			circuit = &F32TernaryCircuit{X: 0, Y: 0, Z: 0, W: 0, op: c.op, mode: c.mode}
+			assignment = &F32TernaryCircuit{X: c.v[0], Y: c.v[1], Z: c.v[2], W: c.v[3], op: c.op, mode: c.mode}
+		}
+		assert.ProverSucceeded(
+			circuit,
+			assignment,
+			test.WithCurves(ecc.BN254),
+			test.WithBackends(backend.GROTH16, backend.PLONK),
+		)
+	}
+}
+
 func TestF32ComparisonCircuit(t *testing.T) {
 	assert := test.NewAssert(t)
 
-	ops := []string{"IsLt", "IsLe", "IsGt", "IsGe"}
+	ops := []string{"IsLt", "IsLe", "IsGt", "IsGe", "IsEq", "TotalOrder"}
 
 	for _, op := range ops {
-		path, _ := filepath.Abs(fmt.Sprintf("../data/f32/%s", strings.ToLower(op[2:])))
+		path, _ := filepath.Abs(fmt.Sprintf("../data/f32/%s", strings.ToLower(strings.TrimPrefix(op, "Is"))))
 		file, _ := os.Open(path)
 		defer file.Close()
 
@@ -232,10 +1108,14 @@ func TestF32ComparisonCircuit(t *testing.T) {
 			a, _ := new(big.Int).SetString(data[0], 16)
 			b, _ := new(big.Int).SetString(data[1], 16)
 			c, _ := new(big.Int).SetString(data[2], 2)
+			flags := data[3]
+			if isSignalingNaN(a, 8, 23) || isSignalingNaN(b, 8, 23) {
+				flags = ""
+			}
 
 			assert.ProverSucceeded(
-				&F32ComparisonCircuit{X: 0, Y: 0, Z: 0, op: op},
-				&F32ComparisonCircuit{X: a, Y: b, Z: c, op: op},
+				&F32ComparisonCircuit{X: 0, Y: 0, Z: 0, op: op, flags: flags},
+				&F32ComparisonCircuit{X: a, Y: b, Z: c, op: op, flags: flags},
 				test.WithCurves(ecc.BN254),
 				test.WithBackends(backend.GROTH16, backend.PLONK),
 			)
@@ -298,7 +1178,7 @@ func TestF32ConversionCircuit(t *testing.T) {
 func TestF64UnaryCircuit(t *testing.T) {
 	assert := test.NewAssert(t)
 
-	ops := []string{"Sqrt", "Trunc", "Floor", "Ceil"}
+	ops := []string{"Sqrt", "Trunc", "Floor", "Ceil", "Round", "RoundEven"}
 
 	for _, op := range ops {
 		path, _ := filepath.Abs(fmt.Sprintf("../data/f64/%s", strings.ToLower(op)))
@@ -348,14 +1228,15 @@ func TestF64BinaryCircuit(t *testing.T) {
 	}
 }
 
-func TestF64ComparisonCircuit(t *testing.T) {
+func TestF64TernaryCircuit(t *testing.T) {
 	assert := test.NewAssert(t)
 
-	ops := []string{"IsLt", "IsLe", "IsGt", "IsGe"}
+	ops := []string{"FMA"}
 
 	for _, op := range ops {
-		path, _ := filepath.Abs(fmt.Sprintf("../data/f64/%s", strings.ToLower(op[2:])))
-		file, _ := os.Open(path)
+		path, _ := filepath.Abs(fmt.Sprintf("../data/f64/%s", strings.ToLower(op)))
+		file, err := os.Open(path)
+		assert.NoError(err)
 		defer file.Close()
 
 		scanner := bufio.NewScanner(file)
@@ -363,11 +1244,12 @@ func TestF64ComparisonCircuit(t *testing.T) {
 			data := strings.Fields(scanner.Text())
 			a, _ := new(big.Int).SetString(data[0], 16)
 			b, _ := new(big.Int).SetString(data[1], 16)
-			c, _ := new(big.Int).SetString(data[2], 2)
+			c, _ := new(big.Int).SetString(data[2], 16)
+			d, _ := new(big.Int).SetString(data[3], 16)
 
 			assert.ProverSucceeded(
-				&F64ComparisonCircuit{X: 0, Y: 0, Z: 0, op: op},
-				&F64ComparisonCircuit{X: a, Y: b, Z: c, op: op},
+				&F64TernaryCircuit{X: 0, Y: 0, Z: 0, W: 0, op: op},
+				&F64TernaryCircuit{X: a, Y: b, Z: c, W: d, op: op},
 				test.WithCurves(ecc.BN254),
 				test.WithBackends(backend.GROTH16, backend.PLONK),
 			)
@@ -375,13 +1257,103 @@ func TestF64ComparisonCircuit(t *testing.T) {
 	}
 }
 
-func TestF64ConstantAllocation(t *testing.T) {
+func TestF64RoundingModesAndFlags(t *testing.T) {
 	assert := test.NewAssert(t)
 
-	path, _ := filepath.Abs("../data/f64/add")
-	file, _ := os.Open(path)
-	defer file.Close()
-
+	modes := []struct {
+		mode   RoundingMode
+		suffix string
+	}{
+		{RoundNearestEven, "rne"},
+		{RoundTowardZero, "rtz"},
+		{RoundTowardPositive, "rup"},
+		{RoundTowardNegative, "rdn"},
+		{RoundNearestAway, "rna"},
+	}
+	ops := []string{"Add", "Sub", "Mul", "Div", "Sqrt", "FMA"}
+
+	for _, m := range modes {
+		for _, op := range ops {
+			path, _ := filepath.Abs(fmt.Sprintf("../data/f64/%s_%s", strings.ToLower(op), m.suffix))
+			file, err := os.Open(path)
+			assert.NoError(err)
+			defer file.Close()
+
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				data := strings.Fields(scanner.Text())
+				// The last field is the exception flags, and the remaining fields are the operands and the result.
+				flags := data[len(data)-1]
+				v := make([]*big.Int, len(data)-1)
+				for i := range v {
+					v[i], _ = new(big.Int).SetString(data[i], 16)
+					if i < len(v)-1 && isSignalingNaN(v[i], 11, 52) {
+						flags = ""
+					}
+				}
+
+				var circuit, assignment frontend.Circuit
+				switch len(v) {
+				case 2:
+					circuit = &F64UnaryCircuit{X: 0, Y: 0, op: op, mode: m.mode, flags: flags}
+					assignment = &F64UnaryCircuit{X: v[0], Y: v[1], op: op, mode: m.mode, flags: flags}
+				case 3:
+					circuit = &F64BinaryCircuit{X: 0, Y: 0, Z: 0, op: op, mode: m.mode, flags: flags}
+					assignment = &F64BinaryCircuit{X: v[0], Y: v[1], Z: v[2], op: op, mode: m.mode, flags: flags}
+				default:
+					circuit = &F64TernaryCircuit{X: 0, Y: 0, Z: 0, W: 0, op: op, mode: m.mode, flags: flags}
+					assignment = &F64TernaryCircuit{X: v[0], Y: v[1], Z: v[2], W: v[3], op: op, mode: m.mode, flags: flags}
+				}
+
+				assert.ProverSucceeded(
+					circuit,
+					assignment,
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16, backend.PLONK),
+				)
+			}
+		}
+	}
+}
+
+func TestF64ComparisonCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	ops := []string{"IsLt", "IsLe", "IsGt", "IsGe", "IsEq", "TotalOrder"}
+
+	for _, op := range ops {
+		path, _ := filepath.Abs(fmt.Sprintf("../data/f64/%s", strings.ToLower(strings.TrimPrefix(op, "Is"))))
+		file, _ := os.Open(path)
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			data := strings.Fields(scanner.Text())
+			a, _ := new(big.Int).SetString(data[0], 16)
+			b, _ := new(big.Int).SetString(data[1], 16)
+			c, _ := new(big.Int).SetString(data[2], 2)
+			flags := data[3]
+			if isSignalingNaN(a, 11, 52) || isSignalingNaN(b, 11, 52) {
+				flags = ""
+			}
+
+			assert.ProverSucceeded(
+				&F64ComparisonCircuit{X: 0, Y: 0, Z: 0, op: op, flags: flags},
+				&F64ComparisonCircuit{X: a, Y: b, Z: c, op: op, flags: flags},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16, backend.PLONK),
+			)
+		}
+	}
+}
+
+func TestF64ConstantAllocation(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	path, _ := filepath.Abs("../data/f64/add")
+	file, _ := os.Open(path)
+	defer file.Close()
+
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		data := strings.Fields(scanner.Text())
@@ -426,3 +1398,1759 @@ func TestF64ConversionCircuit(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	formats := []struct {
+		name string
+		E    uint
+		M    uint
+		ops  []string
+	}{
+		{"f16", 5, 10, []string{"Add", "Sub", "Mul", "Div", "Sqrt", "FMA", "Rem", "Fmod"}},
+		{"bf16", 8, 7, []string{"Add", "Sub", "Mul", "Div", "Sqrt", "FMA", "Rem", "Fmod"}},
+		{"f32", 8, 23, []string{"Rem", "Fmod"}},
+		{"f64", 11, 52, []string{"Rem", "Fmod"}},
+		{"f128", 15, 112, []string{"Add", "Sub", "Mul", "Div", "Sqrt", "FMA", "Rem", "Fmod"}},
+	}
+	for i := range formats {
+		formats[i].ops = append(formats[i].ops, "Min", "Max", "MinNum", "MaxNum", "MinMagnitude", "MaxMagnitude")
+	}
+
+	for _, format := range formats {
+		for _, op := range format.ops {
+			path, _ := filepath.Abs(fmt.Sprintf("../data/%s/%s", format.name, strings.ToLower(op)))
+			file, err := os.Open(path)
+			assert.NoError(err)
+			defer file.Close()
+
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				data := strings.Fields(scanner.Text())
+				// The last field is the exception flags, and the remaining fields are the operands and the result.
+				flags := data[len(data)-1]
+				v := make([]frontend.Variable, len(data)-1)
+				for i := range v {
+					x, _ := new(big.Int).SetString(data[i], 16)
+					if i < len(v)-1 && isSignalingNaN(x, format.E, format.M) {
+						flags = ""
+					}
+					v[i] = x
+				}
+				n := len(v) - 1
+
+				assert.ProverSucceeded(
+					&FormatCircuit{X: make([]frontend.Variable, n), Y: 0, E: format.E, M: format.M, op: op, flags: flags},
+					&FormatCircuit{X: v[:n], Y: v[n], E: format.E, M: format.M, op: op, flags: flags},
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16, backend.PLONK),
+				)
+			}
+		}
+	}
+}
+
+func TestConstantOperandCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	files := []struct {
+		name string
+		E    uint
+		M    uint
+		mode RoundingMode
+	}{
+		{"f16/%s", 5, 10, RoundNearestEven},
+		{"bf16/%s", 8, 7, RoundNearestEven},
+		{"f32/%s_rne", 8, 23, RoundNearestEven},
+		{"f32/%s_rtz", 8, 23, RoundTowardZero},
+		{"f32/%s_rup", 8, 23, RoundTowardPositive},
+		{"f32/%s_rdn", 8, 23, RoundTowardNegative},
+		{"f32/%s_rna", 8, 23, RoundNearestAway},
+		{"f64/%s", 11, 52, RoundNearestEven},
+		{"f128/%s", 15, 112, RoundNearestEven},
+	}
+	ops := []string{"Add", "Sub", "Mul", "Div"}
+
+	for _, file := range files {
+		for _, op := range ops {
+			path, _ := filepath.Abs(fmt.Sprintf("../data/"+file.name, strings.ToLower(op)))
+			f, err := os.Open(path)
+			assert.NoError(err)
+			defer f.Close()
+
+			scanner := bufio.NewScanner(f)
+			for i := 0; scanner.Scan() && i < 32; i++ {
+				data := strings.Fields(scanner.Text())
+				flags := data[len(data)-1]
+				v := make([]*big.Int, len(data)-1)
+				for j := range v {
+					v[j], _ = new(big.Int).SetString(data[j], 16)
+					if j < len(v)-1 && isSignalingNaN(v[j], file.E, file.M) {
+						flags = ""
+					}
+				}
+
+				// Make the right operand constant, and also the left one for the commutative operations,
+				// whose fast paths apply to both sides.
+				for _, left := range []bool{false, true} {
+					if left && op != "Add" && op != "Mul" {
+						continue
+					}
+					x, c := v[0], v[1]
+					if left {
+						x, c = c, x
+					}
+					assert.ProverSucceeded(
+						&ConstantOperandCircuit{X: 0, Y: 0, E: file.E, M: file.M, c: c, left: left, op: op, mode: file.mode, flags: flags},
+						&ConstantOperandCircuit{X: x, Y: v[2], E: file.E, M: file.M, c: c, left: left, op: op, mode: file.mode, flags: flags},
+						test.WithCurves(ecc.BN254),
+						test.WithBackends(backend.GROTH16),
+					)
+				}
+			}
+		}
+	}
+}
+
+func TestConstantPowerOfTwoAndZeroCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	formats := []struct {
+		name string
+		E    uint
+		M    uint
+	}{
+		{"f16", 5, 10},
+		{"f32", 8, 23},
+		{"f64", 11, 52},
+	}
+
+	for _, format := range formats {
+		e_max := int(uint(1) << (format.E - 1))
+		e_normal_min := 2 - e_max
+		// Decode the encoded `v`, or return nil if `v` is abnormal.
+		decode := func(v *big.Int) *big.Float {
+			components := util.ComponentsOfBig(v, uint64(format.E), uint64(format.M))
+			if components[3].Sign() != 0 {
+				return nil
+			}
+			r := new(big.Float).SetMantExp(new(big.Float).SetInt(components[2]), int(components[1].Int64())-int(format.M))
+			if components[0].Sign() != 0 {
+				r.Neg(r)
+			}
+			return r
+		}
+		encode := func(v *big.Float) *big.Int {
+			return util.BitsOf(v, uint64(format.E), uint64(format.M))
+		}
+
+		// Powers of two that keep the result normal, make it overflow, or make it subnormal or zero, and their
+		// negations, as well as both zeros.
+		var constants []*big.Float
+		for _, k := range []int{0, 1, 5, -1, -7, e_max - 1, 2 - e_max, e_normal_min - 3} {
+			constants = append(constants, new(big.Float).SetMantExp(big.NewFloat(1), k), new(big.Float).SetMantExp(big.NewFloat(-1), k))
+		}
+		constants = append(constants, new(big.Float), new(big.Float).Neg(new(big.Float)))
+
+		path, _ := filepath.Abs(fmt.Sprintf("../data/%s/mul", format.name))
+		file, err := os.Open(path)
+		assert.NoError(err)
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for i := 0; scanner.Scan() && i < 16; i++ {
+			data := strings.Fields(scanner.Text())
+			bits, _ := new(big.Int).SetString(data[0], 16)
+			x := decode(bits)
+			if x == nil {
+				continue
+			}
+			for _, c := range constants {
+				results := map[string]*big.Float{"Mul": new(big.Float).Mul(x, c)}
+				if c.Sign() == 0 {
+					results["Add"] = new(big.Float).Add(x, c)
+				} else {
+					results["Div"] = new(big.Float).Quo(x, c)
+				}
+				for op, y := range results {
+					assert.ProverSucceeded(
+						&ConstantOperandCircuit{X: 0, Y: 0, E: format.E, M: format.M, c: encode(c), op: op},
+						&ConstantOperandCircuit{X: bits, Y: encode(y), E: format.E, M: format.M, c: encode(c), op: op},
+						test.WithCurves(ecc.BN254),
+						test.WithBackends(backend.GROTH16),
+					)
+				}
+			}
+		}
+	}
+}
+
+func TestConstantFoldingCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// The operations with 1, 2 and 3 operands respectively.
+	ops := [][]string{
+		{
+			"NewFloat", "FromInt", "Abs", "Neg", "Sqrt", "Trunc", "Floor", "Ceil", "Round", "RoundEven",
+			"ToInt", "ToIntChecked", "Convert", "IsNaN", "IsInf", "IsZero", "IsSubnormal", "IsNormal", "Classify",
+			"Encode", "ScaleB", "Frexp", "Logb", "Rsqrt", "Cbrt",
+		},
+		{
+			"Add", "Sub", "Mul", "Div", "Rem", "Fmod", "Min", "Max", "MinNum", "MaxNum", "MinMagnitude",
+			"MaxMagnitude", "IsLt", "IsLe", "IsGt", "IsGe", "IsEq", "TotalOrder", "UlpDistanceLe", "Copysign",
+			"Hypot",
+		},
+		{"FMA", "Dot", "Sum"},
+	}
+	variants := []struct {
+		param Param
+		mode  RoundingMode
+		flush bool
+	}{
+		{params[0], RoundNearestEven, false},
+		{params[1], RoundNearestEven, false},
+		{params[2], RoundNearestEven, false},
+		{params[2], RoundTowardZero, false},
+		{params[2], RoundTowardPositive, false},
+		{params[2], RoundTowardNegative, false},
+		{params[2], RoundNearestAway, false},
+		{params[3], RoundNearestEven, false},
+		{params[4], RoundNearestEven, false},
+		{params[0], RoundNearestEven, true},
+		{params[2], RoundNearestEven, true},
+		{params[3], RoundTowardNegative, true},
+	}
+
+	for _, variant := range variants {
+		E, M := variant.param.E, variant.param.M
+		for arity, names := range ops {
+			for _, op := range names {
+				// The roots, the dot product and the conversion from integers with `E + M + 1` bits overflow the
+				// native field for binary128.
+				if E+M+1 > 64 && (op == "Rsqrt" || op == "Cbrt" || op == "Hypot" || op == "Dot" || op == "FromInt") {
+					continue
+				}
+				// Read the operands from the test vectors of `op` if available, which cover its special
+				// cases, and from those of multiplication or FMA otherwise.
+				dir := "../data/" + strings.ToLower(variant.param.name) + "/"
+				path, _ := filepath.Abs(dir + strings.ToLower(op))
+				if _, err := os.Stat(path); err != nil {
+					path, _ = filepath.Abs(dir + []string{"mul", "mul", "fma"}[arity])
+				}
+				f, err := os.Open(path)
+				assert.NoError(err)
+				defer f.Close()
+
+				var c [][]*big.Int
+				scanner := bufio.NewScanner(f)
+				for scanner.Scan() && len(c) < 32 {
+					data := strings.Fields(scanner.Text())
+					v := make([]*big.Int, arity+1)
+					for j := range v {
+						v[j], _ = new(big.Int).SetString(data[j], 16)
+					}
+					// `ToInt` requires a finite operand whose exponent is in the range of the powers-of-two table
+					// unless it is zero.
+					if op == "ToInt" {
+						components := util.ComponentsOfBig(v[0], uint64(E), uint64(M))
+						if components[3].Sign() != 0 || components[2].Sign() != 0 && (components[1].Sign() < 0 || components[1].Cmp(big.NewInt(int64(E+M))) > 0) {
+							continue
+						}
+					}
+					c = append(c, v)
+				}
+				assert.NotEmpty(c, "no test vectors for %s in %s", op, path)
+
+				X := make([][]frontend.Variable, len(c))
+				placeholder := make([][]frontend.Variable, len(c))
+				for i := range c {
+					X[i] = make([]frontend.Variable, len(c[i]))
+					placeholder[i] = make([]frontend.Variable, len(c[i]))
+					for j := range c[i] {
+						X[i][j] = c[i][j]
+						placeholder[i][j] = 0
+					}
+				}
+				assert.ProverSucceeded(
+					&ConstantFoldingCircuit{X: placeholder, E: E, M: M, c: c, op: op, mode: variant.mode, flush: variant.flush},
+					&ConstantFoldingCircuit{X: X, E: E, M: M, c: c, op: op, mode: variant.mode, flush: variant.flush},
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16),
+					test.NoTestEngine(),
+				)
+			}
+		}
+	}
+}
+
+func TestFormatConstantAllocation(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	pi, _ := new(big.Float).SetPrec(200).SetString("3.14159265358979323846264338327950288419716939937510582097494")
+	f128_pi, _ := new(big.Int).SetString("4000921FB54442D18469898CC51701B8", 16)
+	// The binary64 number nearest to 0.1, which is exactly representable in binary128
+	f128_tenth, _ := new(big.Int).SetString("3FFB999999999999A000000000000000", 16)
+
+	constants := []struct {
+		E  uint
+		M  uint
+		x  *big.Int
+		op string
+		v  interface{}
+	}{
+		{5, 10, big.NewInt(0x3555), "NewF16Constant", float32(1.0 / 3)},
+		{5, 10, big.NewInt(0x0001), "NewF16Constant", float32(6e-8)},
+		{5, 10, big.NewInt(0x7C00), "NewF16Constant", float32(65520)},
+		{8, 7, big.NewInt(0x4049), "NewBF16Constant", float32(3.14159265)},
+		{8, 7, big.NewInt(0xC2F7), "NewBF16Constant", float32(-123.5)},
+		{15, 112, f128_pi, "NewF128Constant", pi},
+		{15, 112, big.NewInt(0), "NewF128Constant", new(big.Float)},
+		{5, 10, big.NewInt(0x3555), "Const", 1.0 / 3},
+		{5, 10, big.NewInt(0x0001), "Const", 6e-8},
+		{5, 10, big.NewInt(0x7C00), "Const", 65520.0},
+		{8, 7, big.NewInt(0x4049), "Const", math.Pi},
+		{8, 23, big.NewInt(0x3EAAAAAB), "Const", 1.0 / 3},
+		{8, 23, big.NewInt(0x7F800000), "Const", 1e39},
+		{11, 52, big.NewInt(0x3FD5555555555555), "Const", 1.0 / 3},
+		{15, 112, f128_tenth, "Const", 0.1},
+		// A custom format with a 6-bit exponent and a 9-bit mantissa
+		{6, 9, big.NewInt(0x3AAB), "Const", 1.0 / 3},
+		{6, 9, big.NewInt(0xFE00), "Const", math.Inf(-1)},
+	}
+
+	for _, c := range constants {
+		assert.ProverSucceeded(
+			&FormatConstantCircuit{X: 0, E: c.E, M: c.M, op: c.op, v: c.v},
+			&FormatConstantCircuit{X: c.x, E: c.E, M: c.M, op: c.op, v: c.v},
+			test.WithCurves(ecc.BN254),
+			test.WithBackends(backend.GROTH16, backend.PLONK),
+		)
+	}
+}
+
+func TestFormatConversionCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	f16 := Param{E: 5, M: 10, name: "f16"}
+	bf16 := Param{E: 8, M: 7, name: "bf16"}
+	f32 := Param{E: 8, M: 23, name: "f32"}
+	f64 := Param{E: 11, M: 52, name: "f64"}
+	f128 := Param{E: 15, M: 112, name: "f128"}
+
+	conversions := []struct {
+		from   Param
+		to     Param
+		mode   RoundingMode
+		suffix string
+	}{
+		{f16, f32, RoundNearestEven, ""},
+		{f32, f16, RoundNearestEven, ""},
+		{bf16, f32, RoundNearestEven, ""},
+		{f32, bf16, RoundNearestEven, ""},
+		{f16, bf16, RoundNearestEven, ""},
+		{bf16, f16, RoundNearestEven, ""},
+		{f32, f64, RoundNearestEven, ""},
+		{f64, f32, RoundNearestEven, ""},
+		{f64, f32, RoundTowardZero, "_rtz"},
+		{f64, f32, RoundTowardPositive, "_rup"},
+		{f64, f32, RoundTowardNegative, "_rdn"},
+		{f64, f32, RoundNearestAway, "_rna"},
+		{f64, f16, RoundNearestEven, ""},
+		{f64, f128, RoundNearestEven, ""},
+		{f128, f64, RoundNearestEven, ""},
+	}
+
+	for _, c := range conversions {
+		path, _ := filepath.Abs(fmt.Sprintf("../data/%s/to_%s%s", c.from.name, c.to.name, c.suffix))
+		file, err := os.Open(path)
+		assert.NoError(err)
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			data := strings.Fields(scanner.Text())
+			a, _ := new(big.Int).SetString(data[0], 16)
+			b, _ := new(big.Int).SetString(data[1], 16)
+			flags := data[2]
+			if isSignalingNaN(a, c.from.E, c.from.M) {
+				flags = ""
+			}
+
+			assert.ProverSucceeded(
+				&FormatConversionCircuit{X: 0, Y: 0, from: c.from, to: c.to, mode: c.mode, flags: flags},
+				&FormatConversionCircuit{X: a, Y: b, from: c.from, to: c.to, mode: c.mode, flags: flags},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16, backend.PLONK),
+			)
+		}
+	}
+}
+
+func TestSharedGadgetCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	conversions := []struct {
+		from Param
+		to   Param
+	}{
+		{params[0], params[2]},
+		{params[2], params[0]},
+		{params[1], params[0]},
+		{params[2], params[3]},
+		{params[3], params[2]},
+		{params[3], params[4]},
+		{params[4], params[3]},
+	}
+
+	for _, c := range conversions {
+		path, _ := filepath.Abs(fmt.Sprintf("../data/%s/to_%s", strings.ToLower(c.from.name), strings.ToLower(c.to.name)))
+		file, err := os.Open(path)
+		assert.NoError(err)
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for i := 0; scanner.Scan() && i < 32; i++ {
+			data := strings.Fields(scanner.Text())
+			a, _ := new(big.Int).SetString(data[0], 16)
+			b, _ := new(big.Int).SetString(data[1], 16)
+			flags := data[2]
+			if isSignalingNaN(a, c.from.E, c.from.M) {
+				flags = ""
+			}
+
+			assert.ProverSucceeded(
+				&FormatConversionCircuit{X: 0, Y: 0, from: c.from, to: c.to, flags: flags, shared: true},
+				&FormatConversionCircuit{X: a, Y: b, from: c.from, to: c.to, flags: flags, shared: true},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16, backend.PLONK),
+			)
+		}
+	}
+}
+
+func TestFromIntCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	bf16 := Param{E: 8, M: 7, name: "bf16"}
+	f16 := Param{E: 5, M: 10, name: "f16"}
+	f32 := Param{E: 8, M: 23, name: "f32"}
+	f64 := Param{E: 11, M: 52, name: "f64"}
+	f128 := Param{E: 15, M: 112, name: "f128"}
+
+	conversions := []struct {
+		from   string
+		bits   uint
+		signed bool
+		to     Param
+		mode   RoundingMode
+		suffix string
+	}{
+		{"i32", 32, true, f16, RoundNearestEven, ""},
+		{"i32", 32, true, f32, RoundNearestEven, ""},
+		{"i32", 32, true, f64, RoundNearestEven, ""},
+		{"u32", 32, false, f32, RoundNearestEven, ""},
+		{"i64", 64, true, f32, RoundNearestEven, ""},
+		{"i64", 64, true, f32, RoundTowardZero, "_rtz"},
+		{"i64", 64, true, f32, RoundTowardPositive, "_rup"},
+		{"i64", 64, true, f32, RoundTowardNegative, "_rdn"},
+		{"i64", 64, true, f32, RoundNearestAway, "_rna"},
+		{"i64", 64, true, f64, RoundNearestEven, ""},
+		{"u64", 64, false, f64, RoundNearestEven, ""},
+		{"u64", 64, false, bf16, RoundNearestEven, ""},
+		{"i64", 64, true, f128, RoundNearestEven, ""},
+	}
+
+	for _, c := range conversions {
+		path, _ := filepath.Abs(fmt.Sprintf("../data/%s/to_%s%s", c.from, c.to.name, c.suffix))
+		file, err := os.Open(path)
+		assert.NoError(err)
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			data := strings.Fields(scanner.Text())
+			a, _ := new(big.Int).SetString(data[0], 16)
+			if c.signed && a.Bit(int(c.bits-1)) == 1 {
+				a.Sub(a, new(big.Int).Lsh(big.NewInt(1), c.bits))
+				a.Mod(a, ecc.BN254.ScalarField())
+			}
+			b, _ := new(big.Int).SetString(data[1], 16)
+
+			assert.ProverSucceeded(
+				&FromIntCircuit{X: 0, Y: 0, bits: c.bits, signed: c.signed, to: c.to, mode: c.mode, flags: data[2]},
+				&FromIntCircuit{X: a, Y: b, bits: c.bits, signed: c.signed, to: c.to, mode: c.mode, flags: data[2]},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16, backend.PLONK),
+			)
+		}
+	}
+
+	// Integers out of the range of `bits` bits are rejected.
+	for _, c := range []struct {
+		x      *big.Int
+		signed bool
+	}{
+		{big.NewInt(1 << 32), false},
+		{big.NewInt(-1), false},
+		{big.NewInt(1 << 31), true},
+		{big.NewInt(-(1 << 31) - 1), true},
+	} {
+		c.x.Mod(c.x, ecc.BN254.ScalarField())
+		assert.ProverFailed(
+			&FromIntCircuit{X: 0, Y: 0, bits: 32, signed: c.signed, to: f64},
+			&FromIntCircuit{X: c.x, Y: 0, bits: 32, signed: c.signed, to: f64},
+			test.WithCurves(ecc.BN254),
+			test.WithBackends(backend.GROTH16, backend.PLONK),
+		)
+	}
+}
+
+func TestToIntCheckedCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	modes := map[string]RoundingMode{
+		"trunc":     RoundTowardZero,
+		"floor":     RoundTowardNegative,
+		"ceil":      RoundTowardPositive,
+		"round":     RoundNearestAway,
+		"roundeven": RoundNearestEven,
+	}
+
+	for _, c := range []struct {
+		from Param
+		bits uint
+	}{
+		{Param{E: 8, M: 23, name: "f32"}, 32},
+		{Param{E: 11, M: 52, name: "f64"}, 64},
+	} {
+		for _, signed := range []bool{true, false} {
+			for name, mode := range modes {
+				to := fmt.Sprintf("u%d", c.bits)
+				if signed {
+					to = fmt.Sprintf("i%d", c.bits)
+				}
+				path, _ := filepath.Abs(fmt.Sprintf("../data/%s/to_%s_%s", c.from.name, to, name))
+				file, err := os.Open(path)
+				assert.NoError(err)
+				defer file.Close()
+
+				scanner := bufio.NewScanner(file)
+				for scanner.Scan() {
+					data := strings.Fields(scanner.Text())
+					a, _ := new(big.Int).SetString(data[0], 16)
+					b, _ := new(big.Int).SetString(data[1], 16)
+					if signed && b.Bit(int(c.bits-1)) == 1 {
+						b.Sub(b, new(big.Int).Lsh(big.NewInt(1), c.bits))
+						b.Mod(b, ecc.BN254.ScalarField())
+					}
+
+					assert.ProverSucceeded(
+						&ToIntCheckedCircuit{X: 0, Y: 0, from: c.from, bits: c.bits, signed: signed, mode: mode, flags: data[2]},
+						&ToIntCheckedCircuit{X: a, Y: b, from: c.from, bits: c.bits, signed: signed, mode: mode, flags: data[2]},
+						test.WithCurves(ecc.BN254),
+						test.WithBackends(backend.GROTH16, backend.PLONK),
+					)
+				}
+			}
+		}
+	}
+}
+
+func TestClassifyCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, format := range []struct {
+		name string
+		E    uint
+		M    uint
+	}{
+		{"f16", 5, 10},
+		{"bf16", 8, 7},
+		{"f32", 8, 23},
+		{"f64", 11, 52},
+		{"f128", 15, 112},
+	} {
+		path, _ := filepath.Abs(fmt.Sprintf("../data/%s/add", format.name))
+		file, err := os.Open(path)
+		assert.NoError(err)
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for i := 0; scanner.Scan() && i < 128; i++ {
+			data := strings.Fields(scanner.Text())
+			x, _ := new(big.Int).SetString(data[0], 16)
+
+			// Classify `x` according to its encoded exponent and mantissa.
+			exponent := new(big.Int).Rsh(x, format.M)
+			exponent.SetBit(exponent, int(format.E), 0)
+			mantissa := new(big.Int).And(x, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), format.M), big.NewInt(1)))
+			var distance Class
+			switch {
+			case exponent.Cmp(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), format.E), big.NewInt(1))) == 0:
+				distance = 3
+			case exponent.Sign() != 0:
+				distance = 2
+			case mantissa.Sign() != 0:
+				distance = 1
+			}
+			class := ClassPositiveZero + distance
+			if x.Bit(int(format.E+format.M)) == 1 {
+				class = ClassNegativeZero - distance
+			}
+			if distance == 3 && mantissa.Sign() != 0 {
+				class = ClassNaN
+			}
+
+			assert.ProverSucceeded(
+				&ClassifyCircuit{X: 0, E: format.E, M: format.M, class: class},
+				&ClassifyCircuit{X: x, E: format.E, M: format.M, class: class},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16, backend.PLONK),
+			)
+		}
+	}
+}
+
+func TestUlpDistanceCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	for _, format := range []struct {
+		name string
+		E    uint
+		M    uint
+	}{
+		{"f16", 5, 10},
+		{"bf16", 8, 7},
+		{"f32", 8, 23},
+		{"f64", 11, 52},
+	} {
+		one := big.NewInt(1)
+		sign := new(big.Int).Lsh(one, format.E+format.M)
+		inf := new(big.Int).Lsh(new(big.Int).Sub(new(big.Int).Lsh(one, format.E), one), format.M)
+		// Map the encoded `v` to the ordered-integer encoding, or return nil if `v` is NaN.
+		order := func(v *big.Int) *big.Int {
+			magnitude := new(big.Int).AndNot(v, sign)
+			if magnitude.Cmp(inf) > 0 {
+				return nil
+			}
+			if v.Cmp(sign) >= 0 {
+				magnitude.Neg(magnitude)
+			}
+			return magnitude
+		}
+		// Map the ordered-integer encoding back to the encoded form.
+		unorder := func(v *big.Int) *big.Int {
+			if v.Sign() < 0 {
+				return new(big.Int).Or(new(big.Int).Neg(v), sign)
+			}
+			return new(big.Int).Set(v)
+		}
+
+		path, _ := filepath.Abs(fmt.Sprintf("../data/%s/add", format.name))
+		file, err := os.Open(path)
+		assert.NoError(err)
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for i := 0; scanner.Scan() && i < 64; i++ {
+			data := strings.Fields(scanner.Text())
+			x, _ := new(big.Int).SetString(data[0], 16)
+			z, _ := new(big.Int).SetString(data[1], 16)
+
+			pairs := [][2]*big.Int{{x, z}, {x, x}}
+			// Step `x` by a few ULPs in the ordered-integer encoding, which may cross exponent boundaries
+			// and zero.
+			if ordered := order(x); ordered != nil {
+				y := new(big.Int).Add(ordered, big.NewInt(int64(i%7-3)))
+				if new(big.Int).Abs(y).Cmp(inf) <= 0 {
+					pairs = append(pairs, [2]*big.Int{x, unorder(y)})
+				}
+			}
+
+			for _, pair := range pairs {
+				for _, n := range []uint{0, 1, uint(i % 5), 1 << 40} {
+					var expected uint64
+					a, b := order(pair[0]), order(pair[1])
+					if a == nil || b == nil {
+						if a == nil && b == nil {
+							expected = 1
+						}
+					} else if new(big.Int).Abs(new(big.Int).Sub(a, b)).Cmp(new(big.Int).SetUint64(uint64(n))) <= 0 {
+						expected = 1
+					}
+
+					assert.ProverSucceeded(
+						&UlpDistanceCircuit{X: 0, Y: 0, E: format.E, M: format.M, n: n, expected: expected},
+						&UlpDistanceCircuit{X: pair[0], Y: pair[1], E: format.E, M: format.M, n: n, expected: expected},
+						test.WithCurves(ecc.BN254),
+						test.WithBackends(backend.GROTH16),
+					)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	variants := []struct {
+		param Param
+		flush bool
+	}{
+		{params[0], false},
+		{params[1], false},
+		{params[2], false},
+		{params[3], false},
+		{params[4], false},
+		{params[0], true},
+		{params[2], true},
+	}
+
+	for _, variant := range variants {
+		E, M := variant.param.E, variant.param.M
+		// Multiplication and division cover NaN with payloads, infinity, zero, and subnormal results.
+		for _, op := range []string{"Mul", "Div"} {
+			path, _ := filepath.Abs(fmt.Sprintf("../data/%s/%s", strings.ToLower(variant.param.name), strings.ToLower(op)))
+			file, err := os.Open(path)
+			assert.NoError(err)
+			defer file.Close()
+
+			scanner := bufio.NewScanner(file)
+			for i := 0; scanner.Scan() && i < 32; i++ {
+				data := strings.Fields(scanner.Text())
+				v := make([]*big.Int, 3)
+				for j := range v {
+					v[j], _ = new(big.Int).SetString(data[j], 16)
+				}
+
+				cases := []struct {
+					X  []frontend.Variable
+					Y  *big.Int
+					op string
+				}{
+					{[]frontend.Variable{v[0]}, canonicalEncoding(v[0], E, M, variant.flush), ""},
+					{[]frontend.Variable{v[1]}, canonicalEncoding(v[1], E, M, variant.flush), ""},
+				}
+				// The expected results are computed with subnormal numbers.
+				if !variant.flush {
+					cases = append(cases, struct {
+						X  []frontend.Variable
+						Y  *big.Int
+						op string
+					}{[]frontend.Variable{v[0], v[1]}, canonicalEncoding(v[2], E, M, false), op})
+				}
+				for _, c := range cases {
+					assert.ProverSucceeded(
+						&EncodeCircuit{X: make([]frontend.Variable, len(c.X)), Y: 0, E: E, M: M, op: c.op, flush: variant.flush},
+						&EncodeCircuit{X: c.X, Y: c.Y, E: E, M: M, op: c.op, flush: variant.flush},
+						test.WithCurves(ecc.BN254),
+						test.WithBackends(backend.GROTH16, backend.PLONK),
+					)
+				}
+			}
+		}
+	}
+
+	// A subnormal binary32 number with exponent `-127` is shifted to the right by 1 bit, which is rejected if
+	// the mantissa is odd, even if `Y` is the quotient in the field.
+	one := big.NewInt(1)
+	mantissa := new(big.Int).Lsh(one, 23)
+	r := ecc.BN254.ScalarField()
+	quotient := new(big.Int).Mod(new(big.Int).Mul(new(big.Int).Add(mantissa, one), new(big.Int).ModInverse(big.NewInt(2), r)), r)
+	assert.ProverSucceeded(
+		&EncodeCircuit{X: make([]frontend.Variable, 4), Y: 0, E: 8, M: 23, op: "FloatVar"},
+		&EncodeCircuit{X: []frontend.Variable{0, -127, new(big.Int).Add(mantissa, big.NewInt(2)), 0}, Y: 0x00400001, E: 8, M: 23, op: "FloatVar"},
+		test.WithCurves(ecc.BN254),
+		test.WithBackends(backend.GROTH16, backend.PLONK),
+	)
+	assert.ProverFailed(
+		&EncodeCircuit{X: make([]frontend.Variable, 4), Y: 0, E: 8, M: 23, op: "FloatVar"},
+		&EncodeCircuit{X: []frontend.Variable{0, -127, new(big.Int).Add(mantissa, one), 0}, Y: quotient, E: 8, M: 23, op: "FloatVar"},
+		test.WithCurves(ecc.BN254),
+		test.WithBackends(backend.GROTH16, backend.PLONK),
+	)
+}
+
+// Return the exact value of the encoded finite number `v`.
+func exactValueOf(v *big.Int, E, M uint) *big.Float {
+	components := util.ComponentsOfBig(v, uint64(E), uint64(M))
+	x := new(big.Float).SetInt(components[2])
+	x.SetMantExp(x, int(components[1].Int64())-int(M))
+	if components[0].Sign() != 0 {
+		x.Neg(x)
+	}
+	return x
+}
+
+// Return the encoded dot product of the encoded finite numbers `xs` and `ys` (or the sum of `xs` if `ys` is
+// nil) rounded once to the nearest, together with its exception flags in TestFloat's format.
+func exactDotProduct(xs, ys []*big.Int, E, M uint) (*big.Int, string) {
+	// The precision is large enough for the exact sum of any binary64 products.
+	s := new(big.Float).SetPrec(1 << 14)
+	for i := range xs {
+		p := exactValueOf(xs[i], E, M)
+		if ys != nil {
+			p = new(big.Float).SetPrec(1<<14).Mul(p, exactValueOf(ys[i], E, M))
+		}
+		s.Add(s, p)
+	}
+	return roundExact(s, E, M)
+}
+
+// Return the encoded exact value `s` rounded to the nearest, together with its exception flags in TestFloat's
+// format.
+func roundExact(s *big.Float, E, M uint) (*big.Int, string) {
+	result := util.BitsOf(s, uint64(E), uint64(M))
+
+	var flags uint
+	components := util.ComponentsOfBig(result, uint64(E), uint64(M))
+	if components[3].Sign() != 0 {
+		// Overflow is always inexact.
+		flags = 0b101
+	} else if exactValueOf(result, E, M).Cmp(s) != 0 {
+		flags = 0b1
+		// The exact result is tiny if it is less than the smallest normal number `2^(2 - 2^(E - 1))`.
+		if s.Sign() != 0 && s.MantExp(nil)-1 < 2-(1<<(E-1)) {
+			flags |= 0b10
+		}
+	}
+	return result, fmt.Sprintf("%02x", flags)
+}
+
+// Return a random finite number whose unbiased exponent is in the range `[-spread, spread]`, or any random
+// finite number if `spread` is 0.
+func randomFinite(rng *rand.Rand, E, M uint, spread int) *big.Int {
+	v := new(big.Int).Rand(rng, new(big.Int).Lsh(big.NewInt(1), E+M+1))
+	bias := 1<<(E-1) - 1
+	exponent := rng.Intn(1<<E-1) - bias
+	if spread != 0 {
+		exponent = rng.Intn(2*spread+1) - spread
+	}
+	// Replace the exponent field with the biased `exponent`.
+	v.And(v, new(big.Int).Not(new(big.Int).Lsh(big.NewInt(1<<E-1), M)))
+	return v.Or(v, new(big.Int).Lsh(big.NewInt(int64(exponent+bias)), M))
+}
+
+func TestDotCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	rng := rand.New(rand.NewSource(0))
+	random := func(E, M uint, spread int) *big.Int {
+		return randomFinite(rng, E, M, spread)
+	}
+
+	for _, param := range params {
+		E, M := param.E, param.M
+		// The exponents of the random numbers are bounded, so that the products are never ignored, which is
+		// always the case for binary16.
+		spread := map[string]int{"F16": 0, "BF16": 50, "F32": 50, "F64": 30, "F128": 30}[param.name]
+		for _, n := range []int{2, 3, 5, 8} {
+			for i := 0; i < 4; i++ {
+				xs := make([]*big.Int, n)
+				ys := make([]*big.Int, n)
+				for j := range xs {
+					xs[j] = random(E, M, spread)
+					ys[j] = random(E, M, spread)
+				}
+				// Let the first and the last products cancel out, which loses all the bits of the products in
+				// between if the chained operations were used.
+				xs[n-1] = new(big.Int).SetBit(xs[0], int(E+M), xs[0].Bit(int(E+M))^1)
+				ys[n-1] = ys[0]
+
+				cases := [][][]*big.Int{{xs}}
+				// `Self::dot` is not supported for binary128.
+				if param.name != "F128" {
+					cases = append(cases, [][]*big.Int{xs, ys})
+				}
+				for _, c := range cases {
+					var z *big.Int
+					var flags string
+					if len(c) == 1 {
+						z, flags = exactDotProduct(c[0], nil, E, M)
+					} else {
+						z, flags = exactDotProduct(c[0], c[1], E, M)
+					}
+					X := make([][]frontend.Variable, len(c))
+					placeholder := make([][]frontend.Variable, len(c))
+					for j := range c {
+						X[j] = make([]frontend.Variable, n)
+						placeholder[j] = make([]frontend.Variable, n)
+						for k := range c[j] {
+							X[j][k] = c[j][k]
+						}
+					}
+					assert.ProverSucceeded(
+						&DotCircuit{X: placeholder, Z: 0, E: E, M: M, flags: flags},
+						&DotCircuit{X: X, Z: z, E: E, M: M, flags: flags},
+						test.WithCurves(ecc.BN254),
+						test.WithBackends(backend.GROTH16, backend.PLONK),
+					)
+				}
+			}
+		}
+	}
+
+	// The special cases in binary32, where the products below the window of about 200 bits are ignored.
+	for _, c := range []struct {
+		X     []uint64
+		Y     []uint64
+		Z     uint64
+		flags string
+	}{
+		{[]uint64{0x7F800000, 0x3F800000}, []uint64{0x3F800000, 0xFF7FFFFF}, 0x7F800000, "00"}, // inf * 1 + 1 * -max
+		{[]uint64{0x7F800000, 0xFF800000}, []uint64{0x3F800000, 0x3F800000}, 0x7FC00000, "10"}, // inf - inf
+		{[]uint64{0x7F800000, 0x3F800000}, []uint64{0x00000000, 0x3F800000}, 0x7FC00000, "10"}, // inf * 0 + 1
+		{[]uint64{0x7FC00000, 0x3F800000}, []uint64{0x3F800000, 0x3F800000}, 0x7FC00000, "00"}, // NaN + 1
+		{[]uint64{0x80000000, 0x00000000}, []uint64{0x3F800000, 0x80000000}, 0x80000000, "00"}, // -0 * 1 + 0 * -0
+		{[]uint64{0x80000000, 0x00000000}, []uint64{0x3F800000, 0x3F800000}, 0x00000000, "00"}, // -0 + 0
+		{[]uint64{0x3F800000, 0xBF800000}, []uint64{0x3F800000, 0x3F800000}, 0x00000000, "00"}, // 1 - 1
+		{[]uint64{0x7F7FFFFF, 0x7F7FFFFF}, []uint64{0x3F800000, 0x3F800000}, 0x7F800000, "05"}, // max + max
+		{[]uint64{0x00800000, 0x80000001}, []uint64{0x3F000000, 0x3F000000}, 0x00400000, "03"}, // 2^-127 - 2^-150 is a tie
+		{[]uint64{0x71800000, 0x0C800000}, []uint64{0x49800000, 0x0C800000}, 0x7B800000, "01"}, // 2^120 + 2^-200
+		{[]uint64{0x71800000, 0x0C800000, 0xF1800000}, []uint64{0x49800000, 0x0C800000, 0x49800000}, 0x00000000, "01"},
+	} {
+		X := [][]frontend.Variable{make([]frontend.Variable, len(c.X)), make([]frontend.Variable, len(c.Y))}
+		placeholder := [][]frontend.Variable{make([]frontend.Variable, len(c.X)), make([]frontend.Variable, len(c.Y))}
+		for i := range c.X {
+			X[0][i] = c.X[i]
+			X[1][i] = c.Y[i]
+		}
+		assert.ProverSucceeded(
+			&DotCircuit{X: placeholder, Z: 0, E: 8, M: 23, flags: c.flags},
+			&DotCircuit{X: X, Z: c.Z, E: 8, M: 23, flags: c.flags},
+			test.WithCurves(ecc.BN254),
+			test.WithBackends(backend.GROTH16, backend.PLONK),
+		)
+	}
+}
+
+func TestWideCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// A single operation on widened numbers is correctly rounded, so we check it against the test vectors of
+	// the corresponding operation, except that the sum of two infinities is always NaN.
+	for _, param := range params {
+		for _, op := range []string{"Widen", "Add", "Sub", "Mul"} {
+			file := op
+			if op == "Widen" {
+				file = "Add"
+			}
+			path, _ := filepath.Abs(fmt.Sprintf("../data/%s/%s", strings.ToLower(param.name), strings.ToLower(file)))
+			f, err := os.Open(path)
+			assert.NoError(err)
+			defer f.Close()
+
+			scanner := bufio.NewScanner(f)
+			for i := 0; scanner.Scan() && i < 64; i++ {
+				data := strings.Fields(scanner.Text())
+				flags := data[len(data)-1]
+				v := make([]*big.Int, len(data)-1)
+				for j := range v {
+					v[j], _ = new(big.Int).SetString(data[j], 16)
+					if j < len(v)-1 && isSignalingNaN(v[j], param.E, param.M) {
+						flags = ""
+					}
+				}
+				if flags == "" {
+					continue
+				}
+				X := []frontend.Variable{v[0], v[1]}
+				Z := v[2]
+				switch op {
+				case "Widen":
+					X, Z, flags = X[:1], v[0], "00"
+				case "Add", "Sub":
+					x := util.ComponentsOfBig(v[0], uint64(param.E), uint64(param.M))
+					y := util.ComponentsOfBig(v[1], uint64(param.E), uint64(param.M))
+					if x[3].Sign() != 0 && x[2].Sign() != 0 && y[3].Sign() != 0 && y[2].Sign() != 0 {
+						Z, flags = util.F64ToBits(math.NaN(), uint64(param.E), uint64(param.M)), "10"
+					}
+				}
+				// The sign of an exact zero result is not tracked.
+				if z := util.ComponentsOfBig(Z, uint64(param.E), uint64(param.M)); z[2].Sign() == 0 && z[3].Sign() == 0 && flags == "00" {
+					Z = big.NewInt(0)
+				}
+				assert.ProverSucceeded(
+					&WideCircuit{X: make([]frontend.Variable, len(X)), Z: 0, E: param.E, M: param.M, op: op, flags: flags},
+					&WideCircuit{X: X, Z: Z, E: param.E, M: param.M, op: op, flags: flags},
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16, backend.PLONK),
+				)
+			}
+		}
+	}
+
+	// Chains in binary32.
+	for _, c := range []struct {
+		X     []uint64
+		Z     uint64
+		flags string
+	}{
+		// ((1 + 2^-24) * 3 + 2^-23) * 1 is exact in the wide numbers and rounded once.
+		{[]uint64{0x3F800000, 0x33800000, 0x40400000, 0x34000000, 0x3F800000}, 0x40400001, "01"},
+		// (1 - 1) * 2 + 0 is +0.
+		{[]uint64{0x3F800000, 0xBF800000, 0x40000000, 0x00000000}, 0x00000000, "00"},
+		// (inf + 1) * -2 + 1 is -inf.
+		{[]uint64{0x7F800000, 0x3F800000, 0xC0000000, 0x3F800000}, 0xFF800000, "00"},
+		// (inf + 1) * 0 is NaN.
+		{[]uint64{0x7F800000, 0x3F800000, 0x00000000}, 0x7FC00000, "10"},
+		// (max + max) * 2^-2 does not overflow, as the intermediate results are not rounded.
+		{[]uint64{0x7F7FFFFF, 0x7F7FFFFF, 0x3E800000}, 0x7EFFFFFF, "00"},
+		// ((2^-149 + 0) * 2^-149 + 0) * 2^-149 underflows far below the range of binary32.
+		{[]uint64{0x00000001, 0x00000000, 0x00000001, 0x00000000, 0x00000001}, 0x00000000, "03"},
+		// ((2^100 + 2^100) * 2^100 + 0) * 2^100 overflows far above the range of binary32.
+		{[]uint64{0x71800000, 0x71800000, 0x71800000, 0x00000000, 0x71800000}, 0x7F800000, "05"},
+	} {
+		X := make([]frontend.Variable, len(c.X))
+		for i := range c.X {
+			X[i] = c.X[i]
+		}
+		assert.ProverSucceeded(
+			&WideCircuit{X: make([]frontend.Variable, len(X)), Z: 0, E: 8, M: 23, op: "Chain", flags: c.flags},
+			&WideCircuit{X: X, Z: c.Z, E: 8, M: 23, op: "Chain", flags: c.flags},
+			test.WithCurves(ecc.BN254),
+			test.WithBackends(backend.GROTH16, backend.PLONK),
+		)
+	}
+}
+
+// `doubleReference` computes the operations of `DoubleCircuit` out of circuit in the same order as the
+// circuit, with every intermediate result rounded to nearest, so that the results should match bit by bit.
+type doubleReference struct {
+	E uint
+	M uint
+}
+
+func (r doubleReference) value(x *big.Int) *big.Float {
+	return exactValueOf(x, r.E, r.M)
+}
+
+func (r doubleReference) round(v *big.Float) *big.Int {
+	return util.BitsOf(v, uint64(r.E), uint64(r.M))
+}
+
+func exactFloat() *big.Float {
+	return new(big.Float).SetPrec(1 << 14)
+}
+
+func (r doubleReference) add(x, y *big.Int) *big.Int {
+	return r.round(exactFloat().Add(r.value(x), r.value(y)))
+}
+
+func (r doubleReference) sub(x, y *big.Int) *big.Int {
+	return r.round(exactFloat().Sub(r.value(x), r.value(y)))
+}
+
+func (r doubleReference) mul(x, y *big.Int) *big.Int {
+	return r.round(exactFloat().Mul(r.value(x), r.value(y)))
+}
+
+func (r doubleReference) div(x, y *big.Int) *big.Int {
+	return r.round(exactFloat().Quo(r.value(x), r.value(y)))
+}
+
+func (r doubleReference) fma(x, y, z *big.Int) *big.Int {
+	return r.round(exactFloat().Add(exactFloat().Mul(r.value(x), r.value(y)), r.value(z)))
+}
+
+func (r doubleReference) neg(x *big.Int) *big.Int {
+	return new(big.Int).Xor(x, new(big.Int).Lsh(big.NewInt(1), r.E+r.M))
+}
+
+func (r doubleReference) twoSum(x, y *big.Int) (*big.Int, *big.Int) {
+	s := r.add(x, y)
+	x_ := r.sub(s, y)
+	y_ := r.sub(s, x_)
+	return s, r.add(r.sub(x, x_), r.sub(y, y_))
+}
+
+func (r doubleReference) fastTwoSum(x, y *big.Int) (*big.Int, *big.Int) {
+	s := r.add(x, y)
+	return s, r.sub(y, r.sub(s, x))
+}
+
+func (r doubleReference) twoProduct(x, y *big.Int) (*big.Int, *big.Int) {
+	p := r.mul(x, y)
+	return p, r.fma(x, y, r.neg(p))
+}
+
+func (r doubleReference) eval(op string, xs []*big.Int) (*big.Int, *big.Int) {
+	switch op {
+	case "TwoSum":
+		return r.twoSum(xs[0], xs[1])
+	case "TwoProduct":
+		return r.twoProduct(xs[0], xs[1])
+	case "Add":
+		sh, sl := r.twoSum(xs[0], xs[2])
+		th, tl := r.twoSum(xs[1], xs[3])
+		vh, vl := r.fastTwoSum(sh, r.add(sl, th))
+		return r.fastTwoSum(vh, r.add(tl, vl))
+	case "Sub":
+		return r.eval("Add", []*big.Int{xs[0], xs[1], r.neg(xs[2]), r.neg(xs[3])})
+	case "Mul":
+		ch, cl1 := r.twoProduct(xs[0], xs[2])
+		tl := r.fma(xs[0], xs[3], r.mul(xs[1], xs[3]))
+		cl2 := r.fma(xs[1], xs[2], tl)
+		return r.fastTwoSum(ch, r.add(cl1, cl2))
+	case "Div":
+		th := r.div(xs[0], xs[2])
+		ch, cl1 := r.twoProduct(xs[2], th)
+		rh, rl := r.fastTwoSum(ch, r.fma(xs[3], th, cl1))
+		d := r.add(r.sub(xs[0], rh), r.sub(xs[1], rl))
+		return r.fastTwoSum(th, r.div(d, xs[2]))
+	}
+	panic("unknown operation")
+}
+
+func TestDoubleCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	rng := rand.New(rand.NewSource(0))
+
+	for _, param := range params {
+		E, M := param.E, param.M
+		r := doubleReference{E, M}
+		// The exponents of the random numbers are bounded, so that no intermediate result overflows and the
+		// errors of the products are representable.
+		spread := map[string]int{"F16": 2, "BF16": 20, "F32": 20, "F64": 20, "F128": 20}[param.name]
+
+		// Return a random double-word, whose low word is about `2^(-M - 1)` times its high word.
+		randomDouble := func() (*big.Int, *big.Int) {
+			hi := r.value(randomFinite(rng, E, M, spread))
+			lo := r.value(randomFinite(rng, E, M, 1))
+			lo.SetMantExp(lo, hi.MantExp(nil)-int(M)-1)
+			v := exactFloat().Add(hi, lo)
+			h := r.round(v)
+			return h, r.round(v.Sub(v, r.value(h)))
+		}
+
+		// The relative error bounds of [JMP17] in units of `u^2 = 2^(-2M - 2)`, with `u^3` bounded by `u^2 / 8`.
+		bounds := map[string]float64{"Add": 3 + 13.0/8, "Sub": 3 + 13.0/8, "Mul": 5, "Div": 15 + 56.0/8}
+
+		for _, op := range []string{"TwoSum", "TwoProduct", "Add", "Sub", "Mul", "Div"} {
+			for i := 0; i < 4; i++ {
+				var xs []*big.Int
+				var x, y *big.Float
+				if op == "TwoSum" || op == "TwoProduct" {
+					xs = []*big.Int{randomFinite(rng, E, M, spread), randomFinite(rng, E, M, spread)}
+					x, y = r.value(xs[0]), r.value(xs[1])
+				} else {
+					xh, xl := randomDouble()
+					yh, yl := randomDouble()
+					xs = []*big.Int{xh, xl, yh, yl}
+					x = exactFloat().Add(r.value(xh), r.value(xl))
+					y = exactFloat().Add(r.value(yh), r.value(yl))
+				}
+				zh, zl := r.eval(op, xs)
+				z := exactFloat().Add(r.value(zh), r.value(zl))
+
+				switch op {
+				case "TwoSum", "TwoProduct":
+					exact := exactFloat().Add(x, y)
+					if op == "TwoProduct" {
+						exact = exactFloat().Mul(x, y)
+					}
+					if z.Cmp(exact) != 0 {
+						t.Errorf("%s: %s of %x and %x is not exact", param.name, op, xs[0], xs[1])
+					}
+				default:
+					exact := map[string]func(*big.Float, *big.Float) *big.Float{
+						"Add": exactFloat().Add,
+						"Sub": exactFloat().Sub,
+						"Mul": exactFloat().Mul,
+						"Div": exactFloat().Quo,
+					}[op](x, y)
+					err := exactFloat().Sub(z, exact)
+					err.Quo(err.Abs(err), exactFloat().Abs(exact))
+					err.SetMantExp(err, 2*int(M)+2)
+					if e, _ := err.Float64(); e >= bounds[op] {
+						t.Errorf("%s: %s of %x has a relative error of %f u^2", param.name, op, xs, e)
+					}
+				}
+
+				X := make([]frontend.Variable, len(xs))
+				placeholder := make([]frontend.Variable, len(xs))
+				for j := range xs {
+					X[j] = xs[j]
+				}
+				assert.ProverSucceeded(
+					&DoubleCircuit{X: placeholder, E: E, M: M, op: op},
+					&DoubleCircuit{X: X, Z: [2]frontend.Variable{zh, zl}, E: E, M: M, op: op},
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16),
+				)
+			}
+		}
+	}
+}
+
+// Return the encoded `op` of the encoded finite nonzero numbers `xs` rounded to the nearest, together with its
+// exception flags in TestFloat's format, where `op` is one of `Rsqrt`, `Cbrt` and `Hypot`.
+// The result is approximated with far more bits than the format has, which never lies on a tie between two
+// representable numbers unless it is exact, so rounding the approximation is the same as rounding the exact
+// result, while the exactness is checked by raising the rounded result to the power.
+func correctlyRoundedRoot(op string, xs []*big.Int, E, M uint) (*big.Int, string) {
+	const prec = 1 << 11
+	x := exactValueOf(xs[0], E, M)
+	v := new(big.Float).SetPrec(prec)
+	var is_exact func(r *big.Float) bool
+	switch op {
+	case "Rsqrt":
+		v.Quo(new(big.Float).SetPrec(prec).SetInt64(1), v.Sqrt(x))
+		is_exact = func(r *big.Float) bool {
+			return exactFloat().Mul(exactFloat().Mul(r, r), x).Cmp(big.NewFloat(1)) == 0
+		}
+	case "Cbrt":
+		// Refine the cube root of binary64 by Newton's method, i.e., `v = v - (v^3 - x) / (3v^2)`, which doubles
+		// the number of correct bits in every iteration.
+		seed, _ := x.Float64()
+		v.SetFloat64(math.Cbrt(seed))
+		for i := 0; i < 8; i++ {
+			d := new(big.Float).SetPrec(prec).Mul(v, v)
+			t := new(big.Float).SetPrec(prec).Sub(new(big.Float).SetPrec(prec).Mul(d, v), x)
+			v.Sub(v, t.Quo(t, d.Mul(d, big.NewFloat(3))))
+		}
+		is_exact = func(r *big.Float) bool {
+			return exactFloat().Mul(exactFloat().Mul(r, r), r).Cmp(x) == 0
+		}
+	case "Hypot":
+		y := exactValueOf(xs[1], E, M)
+		s := exactFloat().Add(exactFloat().Mul(x, x), exactFloat().Mul(y, y))
+		v.Sqrt(s)
+		is_exact = func(r *big.Float) bool {
+			return exactFloat().Mul(r, r).Cmp(s) == 0
+		}
+	}
+	result := util.BitsOf(v, uint64(E), uint64(M))
+
+	var flags uint
+	components := util.ComponentsOfBig(result, uint64(E), uint64(M))
+	if components[3].Sign() != 0 {
+		// Overflow is always inexact.
+		flags = 0b101
+	} else if !is_exact(exactValueOf(result, E, M)) {
+		flags = 0b1
+		// The exact result is tiny if it is less than the smallest normal number `2^(2 - 2^(E - 1))`.
+		if v.MantExp(nil)-1 < 2-(1<<(E-1)) {
+			flags |= 0b10
+		}
+	}
+	return result, fmt.Sprintf("%02x", flags)
+}
+
+func TestRootCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	rng := rand.New(rand.NewSource(0))
+
+	for _, param := range params {
+		E, M := param.E, param.M
+		// The native field is too small for the roots of binary128.
+		if param.name == "F128" {
+			continue
+		}
+		// Return a random finite nonzero number, which is positive unless `signed` is set.
+		random := func(signed bool) *big.Int {
+			for {
+				v := randomFinite(rng, E, M, 0)
+				if !signed {
+					v.SetBit(v, int(E+M), 0)
+				}
+				if exactValueOf(v, E, M).Sign() != 0 {
+					return v
+				}
+			}
+		}
+		for _, op := range []string{"Rsqrt", "Cbrt", "Hypot"} {
+			for i := 0; i < 8; i++ {
+				xs := []*big.Int{random(op != "Rsqrt")}
+				if op == "Hypot" {
+					xs = append(xs, random(true))
+					if i%2 == 1 {
+						// Let the operands have close exponents, so that both contribute to the result.
+						xs[1] = new(big.Int).Xor(xs[0], big.NewInt(int64(rng.Intn(1<<M))))
+					}
+				}
+				z, flags := correctlyRoundedRoot(op, xs, E, M)
+				X := make([]frontend.Variable, len(xs))
+				placeholder := make([]frontend.Variable, len(xs))
+				for j := range xs {
+					X[j] = xs[j]
+				}
+				assert.ProverSucceeded(
+					&RootCircuit{X: placeholder, Z: 0, E: E, M: M, op: op, flags: flags},
+					&RootCircuit{X: X, Z: z, E: E, M: M, op: op, flags: flags},
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16),
+				)
+			}
+		}
+	}
+
+	// The special cases in binary32.
+	for _, c := range []struct {
+		op    string
+		X     []uint64
+		Z     uint64
+		flags string
+	}{
+		{"Rsqrt", []uint64{0x00000000}, 0x7F800000, "08"},             // rsqrt(+0) = +inf
+		{"Rsqrt", []uint64{0x80000000}, 0xFF800000, "08"},             // rsqrt(-0) = -inf
+		{"Rsqrt", []uint64{0x7F800000}, 0x00000000, "00"},             // rsqrt(+inf) = +0
+		{"Rsqrt", []uint64{0xFF800000}, 0x7FC00000, "10"},             // rsqrt(-inf) = NaN
+		{"Rsqrt", []uint64{0xBF800000}, 0x7FC00000, "10"},             // rsqrt(-1) = NaN
+		{"Rsqrt", []uint64{0x7FC00000}, 0x7FC00000, "00"},             // rsqrt(NaN) = NaN
+		{"Rsqrt", []uint64{0x40800000}, 0x3F000000, "00"},             // rsqrt(4) = 0.5
+		{"Rsqrt", []uint64{0x00000001}, 0x64B504F3, "01"},             // rsqrt(2^-149) = 2^74.5
+		{"Cbrt", []uint64{0x80000000}, 0x80000000, "00"},              // cbrt(-0) = -0
+		{"Cbrt", []uint64{0xFF800000}, 0xFF800000, "00"},              // cbrt(-inf) = -inf
+		{"Cbrt", []uint64{0x7FC00000}, 0x7FC00000, "00"},              // cbrt(NaN) = NaN
+		{"Cbrt", []uint64{0xC1000000}, 0xC0000000, "00"},              // cbrt(-8) = -2
+		{"Cbrt", []uint64{0x41D80000}, 0x40400000, "00"},              // cbrt(27) = 3
+		{"Hypot", []uint64{0x7F800000, 0x7FC00000}, 0x7F800000, "00"}, // hypot(inf, NaN) = inf
+		{"Hypot", []uint64{0x7FC00000, 0xFF800000}, 0x7F800000, "00"}, // hypot(NaN, -inf) = inf
+		{"Hypot", []uint64{0x7FC00000, 0x3F800000}, 0x7FC00000, "00"}, // hypot(NaN, 1) = NaN
+		{"Hypot", []uint64{0x00000000, 0x80000000}, 0x00000000, "00"}, // hypot(0, -0) = +0
+		{"Hypot", []uint64{0xC0400000, 0x40800000}, 0x40A00000, "00"}, // hypot(-3, 4) = 5
+		{"Hypot", []uint64{0x3F800000, 0x00000001}, 0x3F800000, "01"}, // hypot(1, 2^-149) = 1
+		{"Hypot", []uint64{0x7F7FFFFF, 0x7F7FFFFF}, 0x7F800000, "05"}, // hypot(max, max) = inf
+		{"Hypot", []uint64{0x00000001, 0x00000001}, 0x00000001, "03"}, // hypot(2^-149, 2^-149) = 2^-149
+		{"Hypot", []uint64{0x00000003, 0x80000004}, 0x00000005, "00"}, // hypot(3 * 2^-149, -4 * 2^-149) = 5 * 2^-149
+	} {
+		X := make([]frontend.Variable, len(c.X))
+		placeholder := make([]frontend.Variable, len(c.X))
+		for j := range c.X {
+			X[j] = c.X[j]
+		}
+		assert.ProverSucceeded(
+			&RootCircuit{X: placeholder, Z: 0, E: 8, M: 23, op: c.op, flags: c.flags},
+			&RootCircuit{X: X, Z: c.Z, E: 8, M: 23, op: c.op, flags: c.flags},
+			test.WithCurves(ecc.BN254),
+			test.WithBackends(backend.GROTH16, backend.PLONK),
+		)
+	}
+}
+
+func TestExponentCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+	rng := rand.New(rand.NewSource(0))
+	modulus := ecc.BN254.ScalarField()
+
+	for _, param := range params {
+		E, M := param.E, param.M
+		for i := 0; i < 8; i++ {
+			x := randomFinite(rng, E, M, 0)
+			v := exactValueOf(x, E, M)
+			// Let `n` cover the whole range of exponents, so that the results may overflow or underflow.
+			n := rng.Intn(2*(1<<E+int(M))+1) - (1<<E + int(M))
+			if i%2 == 1 {
+				n = rng.Intn(2*int(M)+1) - int(M)
+			}
+			z, flags := roundExact(exactFloat().SetMantExp(v, n), E, M)
+			for _, op := range []string{"ScaleB", "Ldexp"} {
+				assert.ProverSucceeded(
+					&ExponentCircuit{X: 0, N: 0, Z: 0, E: E, M: M, op: op, n: n, bits: E + 2, flags: flags},
+					&ExponentCircuit{X: x, N: new(big.Int).Mod(big.NewInt(int64(n)), modulus), Z: z, E: E, M: M, op: op, n: n, bits: E + 2, flags: flags},
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16),
+				)
+			}
+
+			if v.Sign() == 0 {
+				continue
+			}
+			frac := new(big.Float)
+			exp := v.MantExp(frac)
+			assert.ProverSucceeded(
+				&ExponentCircuit{X: 0, N: 0, Z: 0, E: E, M: M, op: "Frexp", flags: "00"},
+				&ExponentCircuit{X: x, N: new(big.Int).Mod(big.NewInt(int64(exp)), modulus), Z: util.BitsOf(frac, uint64(E), uint64(M)), E: E, M: M, op: "Frexp", flags: "00"},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16),
+			)
+			assert.ProverSucceeded(
+				&ExponentCircuit{X: 0, Z: 0, E: E, M: M, op: "Logb", flags: "00"},
+				&ExponentCircuit{X: x, N: 0, Z: util.BitsOf(big.NewFloat(float64(exp-1)), uint64(E), uint64(M)), E: E, M: M, op: "Logb", flags: "00"},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16),
+			)
+			// `Copysign` takes the sign of the random `y`.
+			y := randomFinite(rng, E, M, 0)
+			z = new(big.Int).SetBit(x, int(E+M), y.Bit(int(E+M)))
+			assert.ProverSucceeded(
+				&ExponentCircuit{X: 0, N: 0, Z: 0, E: E, M: M, op: "Copysign", flags: "00"},
+				&ExponentCircuit{X: x, N: y, Z: z, E: E, M: M, op: "Copysign", flags: "00"},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16),
+			)
+		}
+	}
+
+	// The special cases in binary32, where `n` is the scale of `ScaleB` and `Ldexp`, and the exponent returned by
+	// `Frexp`.
+	for _, c := range []struct {
+		op    string
+		X     uint64
+		n     int
+		Z     uint64
+		mode  RoundingMode
+		flags string
+	}{
+		{"ScaleB", 0x3F800000, 127, 0x7F000000, RoundNearestEven, "00"},     // 1 * 2^127 = 2^127
+		{"ScaleB", 0x3F800000, 128, 0x7F800000, RoundNearestEven, "05"},     // 1 * 2^128 = inf
+		{"ScaleB", 0x3F800000, 128, 0x7F7FFFFF, RoundTowardZero, "05"},      // 1 * 2^128 = max when rounding toward zero
+		{"ScaleB", 0x7F7FFFFF, -1, 0x7EFFFFFF, RoundNearestEven, "00"},      // max * 2^-1
+		{"ScaleB", 0x3F800000, -149, 0x00000001, RoundNearestEven, "00"},    // 1 * 2^-149 = 2^-149 exactly
+		{"ScaleB", 0x3F800000, -150, 0x00000000, RoundNearestEven, "03"},    // 1 * 2^-150 is a tie rounded to 0
+		{"ScaleB", 0x3F800000, -150, 0x00000001, RoundTowardPositive, "03"}, // 1 * 2^-150 rounded up to 2^-149
+		{"ScaleB", 0x3FC00000, -150, 0x00000001, RoundNearestEven, "03"},    // 1.5 * 2^-150 rounded to 2^-149
+		{"ScaleB", 0x00000003, -1, 0x00000002, RoundNearestEven, "03"},      // 3 * 2^-150 is a tie rounded to 2 * 2^-149
+		{"ScaleB", 0x00800000, -1, 0x00400000, RoundNearestEven, "00"},      // min normal * 2^-1 is subnormal but exact
+		{"ScaleB", 0xBF800000, -1000, 0x80000000, RoundNearestEven, "03"},   // -1 * 2^-1000 = -0
+		{"ScaleB", 0xBF800000, -1000, 0x80000001, RoundTowardNegative, "03"},
+		{"ScaleB", 0x00000001, 149, 0x3F800000, RoundNearestEven, "00"},  // 2^-149 * 2^149 = 1
+		{"ScaleB", 0x00000001, 276, 0x7F000000, RoundNearestEven, "00"},  // 2^-149 * 2^276 = 2^127
+		{"ScaleB", 0x00000001, 277, 0x7F800000, RoundNearestEven, "05"},  // 2^-149 * 2^277 = inf
+		{"ScaleB", 0x00000001, 1000, 0x7F800000, RoundNearestEven, "05"}, // 2^-149 * 2^1000 = inf
+		{"ScaleB", 0x7F7FFFFF, -1000, 0x00000000, RoundNearestEven, "03"},
+		{"ScaleB", 0xFF800000, -1000, 0xFF800000, RoundNearestEven, "00"}, // -inf * 2^-1000 = -inf
+		{"ScaleB", 0x7FC00000, 5, 0x7FC00000, RoundNearestEven, "00"},     // NaN * 2^5 = NaN
+		{"ScaleB", 0x80000000, 1000, 0x80000000, RoundNearestEven, "00"},  // -0 * 2^1000 = -0
+		{"Frexp", 0x41000000, 4, 0x3F000000, RoundNearestEven, "00"},      // 8 = 0.5 * 2^4
+		{"Frexp", 0x00000001, -148, 0x3F000000, RoundNearestEven, "00"},   // 2^-149 = 0.5 * 2^-148
+		{"Frexp", 0xFF7FFFFF, 128, 0xBF7FFFFF, RoundNearestEven, "00"},    // -max = -(1 - 2^-24) * 2^128
+		{"Frexp", 0x80000000, 0, 0x80000000, RoundNearestEven, "00"},      // -0 = -0 * 2^0
+		{"Frexp", 0x7F800000, 0, 0x7F800000, RoundNearestEven, "00"},      // inf = inf * 2^0
+		{"Frexp", 0x7FC00000, 0, 0x7FC00000, RoundNearestEven, "00"},      // NaN = NaN * 2^0
+		{"Logb", 0x41000000, 0, 0x40400000, RoundNearestEven, "00"},       // logb(8) = 3
+		{"Logb", 0xBF400000, 0, 0xBF800000, RoundNearestEven, "00"},       // logb(-0.75) = -1
+		{"Logb", 0x3F800000, 0, 0x00000000, RoundNearestEven, "00"},       // logb(1) = 0
+		{"Logb", 0x00000001, 0, 0xC3150000, RoundNearestEven, "00"},       // logb(2^-149) = -149
+		{"Logb", 0x80000000, 0, 0xFF800000, RoundNearestEven, "08"},       // logb(-0) = -inf
+		{"Logb", 0xFF800000, 0, 0x7F800000, RoundNearestEven, "00"},       // logb(-inf) = inf
+		{"Logb", 0x7FC00000, 0, 0x7FC00000, RoundNearestEven, "00"},       // logb(NaN) = NaN
+	} {
+		ops := []string{c.op}
+		if c.op == "ScaleB" {
+			ops = append(ops, "Ldexp")
+		}
+		N := new(big.Int).Mod(big.NewInt(int64(c.n)), modulus)
+		for _, op := range ops {
+			assert.ProverSucceeded(
+				&ExponentCircuit{X: 0, N: 0, Z: 0, E: 8, M: 23, op: op, n: c.n, bits: 12, mode: c.mode, flags: c.flags},
+				&ExponentCircuit{X: c.X, N: N, Z: c.Z, E: 8, M: 23, op: op, n: c.n, bits: 12, mode: c.mode, flags: c.flags},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16, backend.PLONK),
+			)
+		}
+	}
+
+	// `Copysign` also applies to zero, infinity and NaN.
+	for _, c := range []struct {
+		X uint64
+		Y uint64
+		Z uint64
+	}{
+		{0x3F800000, 0x80000000, 0xBF800000}, // copysign(1, -0) = -1
+		{0xFF800000, 0x3F800000, 0x7F800000}, // copysign(-inf, 1) = inf
+		{0x00000001, 0xFF800000, 0x80000001}, // copysign(2^-149, -inf) = -2^-149
+		{0x80000000, 0x7FC00000, 0x00000000}, // copysign(-0, NaN) = +0
+	} {
+		assert.ProverSucceeded(
+			&ExponentCircuit{X: 0, N: 0, Z: 0, E: 8, M: 23, op: "Copysign", flags: "00"},
+			&ExponentCircuit{X: c.X, N: c.Y, Z: c.Z, E: 8, M: 23, op: "Copysign", flags: "00"},
+			test.WithCurves(ecc.BN254),
+			test.WithBackends(backend.GROTH16, backend.PLONK),
+		)
+	}
+
+	// The scale is rejected if it does not fit in the given number of bits, and large scales saturate.
+	for _, c := range []struct {
+		n     int64
+		bits  uint
+		Z     uint64
+		flags string
+		ok    bool
+	}{
+		{127, 8, 0x7F000000, "00", true},
+		{-128, 8, 0x00200000, "00", true},
+		{128, 8, 0x7F800000, "05", false},
+		{-129, 8, 0x00100000, "00", false},
+		{1<<31 - 1, 32, 0x7F800000, "05", true},
+		{-1 << 31, 32, 0x00000000, "03", true},
+	} {
+		N := new(big.Int).Mod(big.NewInt(c.n), modulus)
+		circuit := &ExponentCircuit{X: 0, N: 0, Z: 0, E: 8, M: 23, op: "ScaleB", bits: c.bits, flags: c.flags}
+		witness := &ExponentCircuit{X: 0x3F800000, N: N, Z: c.Z, E: 8, M: 23, op: "ScaleB", bits: c.bits, flags: c.flags}
+		if c.ok {
+			assert.ProverSucceeded(circuit, witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16, backend.PLONK))
+		} else {
+			assert.ProverFailed(circuit, witness, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16, backend.PLONK))
+		}
+	}
+}
+
+func TestFiniteOnlyCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	files := []struct {
+		name string
+		E    uint
+		M    uint
+		mode RoundingMode
+	}{
+		{"f16/%s", 5, 10, RoundNearestEven},
+		{"bf16/%s", 8, 7, RoundNearestEven},
+		{"f32/%s_rne", 8, 23, RoundNearestEven},
+		{"f32/%s_rtz", 8, 23, RoundTowardZero},
+		{"f32/%s_rup", 8, 23, RoundTowardPositive},
+		{"f32/%s_rdn", 8, 23, RoundTowardNegative},
+		{"f32/%s_rna", 8, 23, RoundNearestAway},
+	}
+	ops := []string{"Add", "Sub", "Mul", "Div", "Sqrt"}
+
+	for _, file := range files {
+		// The largest encoded magnitude of finite numbers is `inf - 1`.
+		inf := new(big.Int).Lsh(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), file.E), big.NewInt(1)), file.M)
+		is_finite := func(v *big.Int) bool {
+			return new(big.Int).SetBit(v, int(file.E+file.M), 0).Cmp(inf) < 0
+		}
+
+		for _, op := range ops {
+			path, _ := filepath.Abs(fmt.Sprintf("../data/"+file.name, strings.ToLower(op)))
+			f, err := os.Open(path)
+			assert.NoError(err)
+			defer f.Close()
+
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				data := strings.Fields(scanner.Text())
+				flags := data[len(data)-1]
+				v := make([]frontend.Variable, len(data)-1)
+				// The operation is satisfiable if and only if all operands and the result are finite.
+				finite := true
+				for i := range v {
+					x, _ := new(big.Int).SetString(data[i], 16)
+					finite = finite && is_finite(x)
+					v[i] = x
+				}
+				n := len(v) - 1
+
+				circuit := &FiniteOnlyCircuit{X: make([]frontend.Variable, n), Y: 0, E: file.E, M: file.M, op: op, mode: file.mode, flags: flags}
+				assignment := &FiniteOnlyCircuit{X: v[:n], Y: v[n], E: file.E, M: file.M, op: op, mode: file.mode, flags: flags}
+				if finite {
+					assert.ProverSucceeded(circuit, assignment, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+				} else {
+					assert.ProverFailed(circuit, assignment, test.WithCurves(ecc.BN254), test.WithBackends(backend.GROTH16))
+				}
+			}
+		}
+	}
+}
+
+func TestFlushSubnormalsCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	formats := []struct {
+		name string
+		E    uint
+		M    uint
+	}{
+		{"f16", 5, 10},
+		{"bf16", 8, 7},
+		{"f32", 8, 23},
+		{"f64", 11, 52},
+	}
+	ops := []string{"Add", "Sub", "Mul", "Div", "Sqrt", "FMA"}
+
+	for _, format := range formats {
+		prec := format.M + 1
+		e_normal_min := 2 - int(uint(1)<<(format.E-1))
+		max_finite := new(big.Float).SetMantExp(
+			new(big.Float).SetInt(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), prec), big.NewInt(1))),
+			int(uint(1)<<(format.E-1))-int(prec),
+		)
+		// Decode the encoded `v` with subnormal numbers treated as zero, or return nil if `v` is abnormal.
+		decode := func(v *big.Int) *big.Float {
+			components := util.ComponentsOfBig(v, uint64(format.E), uint64(format.M))
+			if components[3].Sign() != 0 {
+				return nil
+			}
+			r := new(big.Float)
+			if components[1].Cmp(big.NewInt(int64(e_normal_min))) >= 0 {
+				r.SetMantExp(new(big.Float).SetInt(components[2]), int(components[1].Int64())-int(format.M))
+			}
+			if components[0].Sign() != 0 {
+				r.Neg(r)
+			}
+			return r
+		}
+		// Round the exact `v` to `M + 1` bits with an unbounded exponent, and flush it to zero if it is tiny.
+		round := func(v *big.Float) *big.Int {
+			r := new(big.Float).SetMode(big.ToNearestEven).SetPrec(prec).Set(v)
+			if new(big.Float).Abs(r).Cmp(max_finite) > 0 {
+				r.SetInf(r.Signbit())
+			} else if r.Sign() != 0 && r.MantExp(nil)-1 < e_normal_min {
+				negative := r.Signbit()
+				r.SetInt64(0)
+				if negative {
+					r.Neg(r)
+				}
+			}
+			return util.BitsOf(r, uint64(format.E), uint64(format.M))
+		}
+
+		for _, op := range ops {
+			path, _ := filepath.Abs(fmt.Sprintf("../data/%s/%s", format.name, strings.ToLower(op)))
+			file, err := os.Open(path)
+			assert.NoError(err)
+			defer file.Close()
+
+			scanner := bufio.NewScanner(file)
+			for i := 0; scanner.Scan() && i < 128; i++ {
+				data := strings.Fields(scanner.Text())
+				// The last two fields are the result and the exception flags in IEEE 754 semantics.
+				n := len(data) - 2
+				v := make([]frontend.Variable, n)
+				x := make([]*big.Float, n)
+				is_finite := true
+				for j := range v {
+					bits, _ := new(big.Int).SetString(data[j], 16)
+					v[j] = bits
+					x[j] = decode(bits)
+					is_finite = is_finite && x[j] != nil
+				}
+				if !is_finite {
+					continue
+				}
+
+				var y *big.Int
+				switch op {
+				case "Add":
+					y = round(new(big.Float).SetPrec(4096).Add(x[0], x[1]))
+				case "Sub":
+					y = round(new(big.Float).SetPrec(4096).Sub(x[0], x[1]))
+				case "Mul":
+					y = round(new(big.Float).SetPrec(2*prec).Mul(x[0], x[1]))
+				case "FMA":
+					y = round(new(big.Float).SetPrec(4096).Add(new(big.Float).SetPrec(2*prec).Mul(x[0], x[1]), x[2]))
+				case "Div":
+					if x[1].Sign() == 0 {
+						continue
+					}
+					// `Quo` is correctly rounded to the precision of the receiver.
+					y = round(new(big.Float).SetMode(big.ToNearestEven).SetPrec(prec).Quo(x[0], x[1]))
+				case "Sqrt":
+					if x[0].Sign() < 0 {
+						continue
+					}
+					// The square root of a normal number is always normal, so the result only differs from
+					// IEEE 754 if the operand is subnormal and hence treated as zero.
+					if x[0].Sign() == 0 {
+						y = util.BitsOf(x[0], uint64(format.E), uint64(format.M))
+					} else {
+						y, _ = new(big.Int).SetString(data[n], 16)
+					}
+				}
+
+				assert.ProverSucceeded(
+					&FlushSubnormalsCircuit{X: make([]frontend.Variable, n), Y: 0, E: format.E, M: format.M, op: op},
+					&FlushSubnormalsCircuit{X: v, Y: y, E: format.E, M: format.M, op: op},
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16),
+				)
+			}
+		}
+	}
+}
+
+// Rounding a mantissa of exactly `M + 2` bits without a shift leaves no sticky bits, which happens in the
+// flush-to-zero mode when multiplying by a power of two less than 1 or narrowing the format.
+func TestFlushSubnormalsScalingAndConversionCircuit(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	f32 := Param{E: 8, M: 23, name: "f32"}
+	f64 := Param{E: 11, M: 52, name: "f64"}
+
+	// Decode the encoded `v` in `format` with subnormal numbers treated as zero, or return nil if `v` is
+	// abnormal.
+	decode := func(v *big.Int, format Param) *big.Float {
+		components := util.ComponentsOfBig(v, uint64(format.E), uint64(format.M))
+		if components[3].Sign() != 0 {
+			return nil
+		}
+		r := new(big.Float)
+		if components[1].Cmp(big.NewInt(int64(2-int(uint(1)<<(format.E-1))))) >= 0 {
+			r.SetMantExp(new(big.Float).SetInt(components[2]), int(components[1].Int64())-int(format.M))
+		}
+		if components[0].Sign() != 0 {
+			r.Neg(r)
+		}
+		return r
+	}
+	// Round the exact `v` to f32 with an unbounded exponent, and flush it to zero if it is tiny.
+	max_finite := new(big.Float).SetMantExp(big.NewFloat(float64(1<<24-1)), 128-24)
+	round := func(v *big.Float) *big.Int {
+		r := new(big.Float).SetMode(big.ToNearestEven).SetPrec(f32.M + 1).Set(v)
+		if new(big.Float).Abs(r).Cmp(max_finite) > 0 {
+			r.SetInf(r.Signbit())
+		} else if r.Sign() != 0 && r.MantExp(nil)-1 < 2-int(uint(1)<<(f32.E-1)) {
+			negative := r.Signbit()
+			r.SetInt64(0)
+			if negative {
+				r.Neg(r)
+			}
+		}
+		return util.BitsOf(r, uint64(f32.E), uint64(f32.M))
+	}
+
+	path, _ := filepath.Abs("../data/f32/mul")
+	file, err := os.Open(path)
+	assert.NoError(err)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for i := 0; scanner.Scan() && i < 64; i++ {
+		bits, _ := new(big.Int).SetString(strings.Fields(scanner.Text())[0], 16)
+		x := decode(bits, f32)
+		if x == nil {
+			continue
+		}
+		// Scale `x` by `2^k` for negative `k`, either by multiplying by `2^k` or by dividing by `2^(-k)`.
+		for _, k := range []int{-1, -7, -100} {
+			y := round(new(big.Float).SetMantExp(x, k))
+			for op, c := range map[string]int{"Mul": k, "Div": -k} {
+				encoded := util.BitsOf(new(big.Float).SetMantExp(big.NewFloat(1), c), uint64(f32.E), uint64(f32.M))
+				assert.ProverSucceeded(
+					&ConstantOperandCircuit{X: 0, Y: 0, E: f32.E, M: f32.M, c: encoded, op: op, flush: true},
+					&ConstantOperandCircuit{X: bits, Y: y, E: f32.E, M: f32.M, c: encoded, op: op, flush: true},
+					test.WithCurves(ecc.BN254),
+					test.WithBackends(backend.GROTH16),
+				)
+			}
+			// Scaling by `2^k` only changes the exponent.
+			assert.ProverSucceeded(
+				&ExponentCircuit{X: 0, N: 0, Z: 0, E: f32.E, M: f32.M, op: "ScaleB", bits: 8, flush: true},
+				&ExponentCircuit{X: bits, N: new(big.Int).Mod(big.NewInt(int64(k)), ecc.BN254.ScalarField()), Z: y, E: f32.E, M: f32.M, op: "ScaleB", bits: 8, flush: true},
+				test.WithCurves(ecc.BN254),
+				test.WithBackends(backend.GROTH16),
+			)
+		}
+	}
+
+	path, _ = filepath.Abs("../data/f64/to_f32")
+	file, err = os.Open(path)
+	assert.NoError(err)
+	defer file.Close()
+	scanner = bufio.NewScanner(file)
+	for i := 0; scanner.Scan() && i < 64; i++ {
+		bits, _ := new(big.Int).SetString(strings.Fields(scanner.Text())[0], 16)
+		x := decode(bits, f64)
+		if x == nil {
+			continue
+		}
+		assert.ProverSucceeded(
+			&FormatConversionCircuit{X: 0, Y: 0, from: f64, to: f32, flush: true},
+			&FormatConversionCircuit{X: bits, Y: round(x), from: f64, to: f32, flush: true},
+			test.WithCurves(ecc.BN254),
+			test.WithBackends(backend.GROTH16),
+		)
+	}
+}
@@ -0,0 +1,991 @@
+package float
+
+import (
+	"math/big"
+	"slices"
+
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/tumberger/zk-Location/util"
+)
+
+// `constFloat` is a number whose components are all known at compile time, which allows the operations of
+// `Context` to compute their results natively instead of generating constraints.
+// The components follow the same conventions as `FloatVar`, e.g., the mantissa has an explicit leading 1
+// even for subnormal numbers, and the mantissa of NaN is 0.
+type constFloat struct {
+	sign       bool
+	exponent   int
+	mantissa   *big.Int
+	isAbnormal bool
+}
+
+func (x constFloat) isNaN() bool {
+	return x.isAbnormal && x.mantissa.Sign() == 0
+}
+
+func (x constFloat) isInf() bool {
+	return x.isAbnormal && x.mantissa.Sign() != 0
+}
+
+func (x constFloat) isZero() bool {
+	return !x.isAbnormal && x.mantissa.Sign() == 0
+}
+
+// `constFlags` records the exception flags raised by a folded operation.
+type constFlags struct {
+	invalid        bool
+	divisionByZero bool
+	overflow       bool
+	underflow      bool
+	inexact        bool
+}
+
+// Return the components of `x` as a `constFloat` if all of them are known at compile time.
+func (f *Context) constantOf(x FloatVar) (constFloat, bool) {
+	c, ok := f.constantComponents(x)
+	if !ok {
+		return constFloat{}, false
+	}
+	return constFloat{
+		sign:       c[0].Sign() != 0,
+		exponent:   int(c[1].Int64()),
+		mantissa:   c[2],
+		isAbnormal: c[3].Sign() != 0,
+	}, true
+}
+
+// Return the components of all `xs` if all of them are known at compile time.
+func (f *Context) constantsOf(xs ...FloatVar) ([]constFloat, bool) {
+	cs := make([]constFloat, len(xs))
+	for i, x := range xs {
+		c, ok := f.constantOf(x)
+		if !ok {
+			return nil, false
+		}
+		cs[i] = c
+	}
+	return cs, true
+}
+
+func boolToBig(b bool) *big.Int {
+	if b {
+		return big.NewInt(1)
+	}
+	return big.NewInt(0)
+}
+
+// Convert `x` back to a `FloatVar` whose components are constants.
+func (f *Context) newFolded(x constFloat) FloatVar {
+	return FloatVar{
+		Sign:       boolToBig(x.sign),
+		Exponent:   big.NewInt(int64(x.exponent)),
+		Mantissa:   x.mantissa,
+		IsAbnormal: boolToBig(x.isAbnormal),
+	}
+}
+
+func (f *Context) constNaN() constFloat {
+	return constFloat{false, int(f.E_MAX.Int64()), big.NewInt(0), true}
+}
+
+func (f *Context) constInf(sign bool) constFloat {
+	return constFloat{sign, int(f.E_MAX.Int64()), new(big.Int).Lsh(big.NewInt(1), f.M), true}
+}
+
+func (f *Context) constZero(sign bool) constFloat {
+	return constFloat{sign, int(f.E_MIN.Int64()), big.NewInt(0), false}
+}
+
+// Finish a folded operation with operands `inputs`, result `result` and exception flags `flags`, where the
+// invalid flag is derived as in `Self::raise_flags`, i.e., the operation is invalid if the result is NaN but
+// none of the inputs is NaN.
+// In the finite-only mode, an abnormal result is not folded, so that the operation falls back to the
+// constraints, which are unsatisfiable in this case.
+// The flags are set to constants instead of being accumulated with `Or`, which would add a constraint even
+// for constant operands.
+func (f *Context) finishFolded(inputs []constFloat, result constFloat, flags constFlags) (FloatVar, bool) {
+	if f.FiniteOnly && result.isAbnormal {
+		return FloatVar{}, false
+	}
+	if result.isNaN() {
+		flags.invalid = true
+		for _, x := range inputs {
+			flags.invalid = flags.invalid && !x.isNaN()
+		}
+	}
+	f.raiseFoldedFlags(flags)
+	return f.newFolded(result), true
+}
+
+// Set the exception flags raised by a folded operation, if the flags are tracked.
+func (f *Context) raiseFoldedFlags(flags constFlags) {
+	if f.Flags == nil {
+		return
+	}
+	if flags.invalid {
+		f.Flags.Invalid = big.NewInt(1)
+	}
+	if flags.divisionByZero {
+		f.Flags.DivisionByZero = big.NewInt(1)
+	}
+	if flags.overflow {
+		f.Flags.Overflow = big.NewInt(1)
+	}
+	if flags.underflow {
+		f.Flags.Underflow = big.NewInt(1)
+	}
+	if flags.inexact {
+		f.Flags.Inexact = big.NewInt(1)
+	}
+}
+
+// Fold `Self::encode`, where NaN is encoded canonically.
+func (f *Context) foldEncode(x FloatVar) (frontend.Variable, bool) {
+	c, ok := f.constantOf(x)
+	if !ok {
+		return nil, false
+	}
+	if c.isNaN() {
+		return new(big.Int).Lsh(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), f.E+1), big.NewInt(1)), f.M-1), true
+	}
+	return util.ValueOfBig(
+		[]*big.Int{boolToBig(c.sign), big.NewInt(int64(c.exponent)), c.mantissa, boolToBig(c.isAbnormal)},
+		uint64(f.E),
+		uint64(f.M),
+	), true
+}
+
+// Right shift `m` by `shift` bits (or left shift if `shift` is negative) and round the quotient to an integer
+// in `mode`, where `sticky` indicates that the exact value is slightly larger than `m` in magnitude, i.e.,
+// there are nonzero bits below the lowest bit of `m`. `sticky` is only allowed if `shift` is positive.
+// Return the rounded quotient and whether it is inexact.
+func roundShifted(m *big.Int, shift int, sticky bool, sign bool, mode RoundingMode) (*big.Int, bool) {
+	if shift <= 0 {
+		return new(big.Int).Lsh(m, uint(-shift)), sticky
+	}
+	q := new(big.Int).Rsh(m, uint(shift))
+	r := new(big.Int).Sub(m, new(big.Int).Lsh(q, uint(shift)))
+	is_inexact := r.Sign() != 0 || sticky
+	// Compare the remainder with 1/2, where the sticky bits break the tie.
+	cmp := new(big.Int).Lsh(r, 1).Cmp(new(big.Int).Lsh(big.NewInt(1), uint(shift)))
+	if cmp == 0 && sticky {
+		cmp = 1
+	}
+
+	var carry bool
+	switch mode {
+	case RoundNearestEven:
+		carry = cmp > 0 || cmp == 0 && q.Bit(0) == 1
+	case RoundNearestAway:
+		carry = cmp >= 0
+	case RoundTowardZero:
+		carry = false
+	case RoundTowardPositive:
+		carry = is_inexact && !sign
+	case RoundTowardNegative:
+		carry = is_inexact && sign
+	default:
+		panic("unknown rounding mode")
+	}
+	if carry {
+		q.Add(q, big.NewInt(1))
+	}
+	return q, is_inexact
+}
+
+// Round the nonzero number `(-1)^sign * (m + ε) * 2^e` to the format of the context, where `ε` is in `(0, 1)`
+// if `sticky` is true and 0 otherwise. If `sticky` is true, `m` should have at least `M + 3` bits.
+// Tininess is detected before rounding, unless subnormal numbers are flushed to zero, in which case a result
+// that is tiny after rounding with an unbounded exponent is flushed, as in `Self::round_subnormal`.
+func (f *Context) roundConstant(sign bool, m *big.Int, e int, sticky bool) (constFloat, constFlags) {
+	var flags constFlags
+	M := int(f.M)
+	e_normal_min := int(f.E_NORMAL_MIN.Int64())
+	e_max := int(f.E_MAX.Int64())
+
+	// `top` is the exponent of the MSB of the exact result, and `lsb` is the exponent of the lowest bit kept
+	// after rounding, which is fixed for subnormal results.
+	top := e + m.BitLen() - 1
+	lsb := top - M
+	if !f.FlushSubnormals && lsb < e_normal_min-M {
+		lsb = e_normal_min - M
+	}
+	q, is_inexact := roundShifted(m, lsb-e, sticky, sign, f.RoundingMode)
+	flags.inexact = is_inexact
+	if !f.FlushSubnormals {
+		flags.underflow = top < e_normal_min && is_inexact
+	}
+	if q.Sign() == 0 {
+		return f.constZero(sign), flags
+	}
+
+	// The rounded result is `q * 2^lsb`, where `q` may have `M + 2` bits after a carry.
+	exponent := lsb + q.BitLen() - 1
+	if f.FlushSubnormals && exponent < e_normal_min {
+		flags.inexact = true
+		flags.underflow = true
+		return f.constZero(sign), flags
+	}
+	if exponent >= e_max {
+		flags.overflow = true
+		flags.inexact = true
+		// In the directed rounding modes, a result that overflows toward zero becomes the largest finite number.
+		if f.RoundingMode == RoundTowardZero ||
+			f.RoundingMode == RoundTowardPositive && sign ||
+			f.RoundingMode == RoundTowardNegative && !sign {
+			return constFloat{
+				sign,
+				e_max - 1,
+				new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), f.M+1), big.NewInt(1)),
+				false,
+			}, flags
+		}
+		return f.constInf(sign), flags
+	}
+	var mantissa *big.Int
+	if q.BitLen() > M+1 {
+		// `q` is `2^(M + 1)` after a carry, so the shift is exact.
+		mantissa = new(big.Int).Rsh(q, uint(q.BitLen()-M-1))
+	} else {
+		mantissa = new(big.Int).Lsh(q, uint(M+1-q.BitLen()))
+	}
+	return constFloat{sign, exponent, mantissa, false}, flags
+}
+
+// Return `x` as `(-1)^sign * m * 2^e` with the exponent `e` aligned to `e_min`, which should be at most
+// `x.exponent - M`.
+func (f *Context) alignConstant(x constFloat, e_min int) *big.Int {
+	m := new(big.Int).Lsh(x.mantissa, uint(x.exponent-int(f.M)-e_min))
+	if x.sign {
+		m.Neg(m)
+	}
+	return m
+}
+
+// Round the exact sum `s * 2^e` of two numbers with signs `x_sign` and `y_sign`, where the sign of an exact
+// zero sum follows `Self::add`.
+func (f *Context) roundSum(s *big.Int, e int, x_sign, y_sign bool) (constFloat, constFlags) {
+	if s.Sign() == 0 {
+		if x_sign == y_sign {
+			return f.constZero(x_sign), constFlags{}
+		}
+		return f.constZero(f.RoundingMode == RoundTowardNegative), constFlags{}
+	}
+	return f.roundConstant(s.Sign() < 0, new(big.Int).Abs(s), e, false)
+}
+
+// Fold `Self::add` if both operands are constants.
+func (f *Context) foldAdd(x, y FloatVar) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a, b := cs[0], cs[1]
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isNaN() || b.isNaN() || a.isInf() && b.isInf() && a.sign != b.sign:
+		result = f.constNaN()
+	case a.isInf():
+		result = a
+	case b.isInf():
+		result = b
+	default:
+		e := min(a.exponent, b.exponent) - int(f.M)
+		result, flags = f.roundSum(new(big.Int).Add(f.alignConstant(a, e), f.alignConstant(b, e)), e, a.sign, b.sign)
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::mul` if both operands are constants.
+func (f *Context) foldMul(x, y FloatVar) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a, b := cs[0], cs[1]
+	sign := a.sign != b.sign
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isNaN() || b.isNaN() || a.isInf() && b.isZero() || a.isZero() && b.isInf():
+		result = f.constNaN()
+	case a.isInf() || b.isInf():
+		result = f.constInf(sign)
+	case a.isZero() || b.isZero():
+		result = f.constZero(sign)
+	default:
+		result, flags = f.roundConstant(
+			sign,
+			new(big.Int).Mul(a.mantissa, b.mantissa),
+			a.exponent+b.exponent-2*int(f.M),
+			false,
+		)
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::div` if both operands are constants.
+func (f *Context) foldDiv(x, y FloatVar) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a, b := cs[0], cs[1]
+	sign := a.sign != b.sign
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isNaN() || b.isNaN() || a.isInf() && b.isInf() || a.isZero() && b.isZero():
+		result = f.constNaN()
+	case a.isInf():
+		result = f.constInf(sign)
+	case b.isZero():
+		result = f.constInf(sign)
+		flags.divisionByZero = true
+	case a.isZero() || b.isInf():
+		result = f.constZero(sign)
+	default:
+		// Both mantissas have `M + 1` bits, so the quotient of `a.mantissa << (M + 3)` and `b.mantissa` has at
+		// least `M + 3` bits, and the remainder is only needed as the sticky bit.
+		q, r := new(big.Int).QuoRem(new(big.Int).Lsh(a.mantissa, f.M+3), b.mantissa, new(big.Int))
+		result, flags = f.roundConstant(sign, q, a.exponent-b.exponent-int(f.M)-3, r.Sign() != 0)
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::sqrt` if the operand is a constant.
+func (f *Context) foldSqrt(x FloatVar) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a := cs[0]
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isNaN() || a.sign && !a.isZero():
+		result = f.constNaN()
+	case a.isInf() || a.isZero():
+		// `sqrt(+inf) = +inf` and `sqrt(-0) = -0`.
+		result = a
+	default:
+		// Write `a` as `m * 2^e` with an even `e`, and left shift `m` by `2(M + 3)` bits, so that the integer
+		// square root has at least `M + 3` bits.
+		m := new(big.Int).Set(a.mantissa)
+		e := a.exponent - int(f.M)
+		if e%2 != 0 {
+			m.Lsh(m, 1)
+			e--
+		}
+		m.Lsh(m, 2*(f.M+3))
+		e -= 2 * int(f.M+3)
+		n := new(big.Int).Sqrt(m)
+		result, flags = f.roundConstant(false, n, e/2, new(big.Int).Mul(n, n).Cmp(m) != 0)
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::rsqrt` if the operand is a constant.
+func (f *Context) foldRsqrt(x FloatVar) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a := cs[0]
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isNaN() || a.sign && !a.isZero():
+		result = f.constNaN()
+	case a.isZero():
+		result = f.constInf(a.sign)
+		flags.divisionByZero = true
+	case a.isInf():
+		result = f.constZero(false)
+	default:
+		// Write `a` as `m * 2^e` with an even `e`, so that `1 / sqrt(a) = 2^(-e / 2) / sqrt(m)`, and compute
+		// `n = floor(2^K / sqrt(m))` with `K = 2M + 5`, which has at least `M + 3` bits.
+		m := new(big.Int).Set(a.mantissa)
+		e := a.exponent - int(f.M)
+		if e%2 != 0 {
+			m.Lsh(m, 1)
+			e--
+		}
+		K := 2*f.M + 5
+		d := new(big.Int).Lsh(big.NewInt(1), 2*K)
+		n := new(big.Int).Sqrt(new(big.Int).Quo(d, m))
+		result, flags = f.roundConstant(false, n, -e/2-int(K), new(big.Int).Mul(new(big.Int).Mul(n, n), m).Cmp(d) != 0)
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::cbrt` if the operand is a constant.
+func (f *Context) foldCbrt(x FloatVar) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a := cs[0]
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isAbnormal || a.isZero():
+		result = a
+	default:
+		// Write `a` as `m * 2^e` with `e` divisible by 3, and left shift `m` by `3(M + 3)` bits, so that the
+		// integer cube root has at least `M + 3` bits.
+		e := a.exponent - int(f.M)
+		j := (e%3 + 3) % 3
+		m := new(big.Int).Lsh(a.mantissa, uint(j)+3*(f.M+3))
+		e -= j + 3*int(f.M+3)
+		n := util.CbrtFloor(m)
+		result, flags = f.roundConstant(a.sign, n, e/3, new(big.Int).Exp(n, big.NewInt(3), nil).Cmp(m) != 0)
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::hypot` if both operands are constants.
+func (f *Context) foldHypot(x, y FloatVar) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a, b := cs[0], cs[1]
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isInf() || b.isInf():
+		result = f.constInf(false)
+	case a.isNaN() || b.isNaN():
+		result = f.constNaN()
+	case a.isZero() && b.isZero():
+		result = f.constZero(false)
+	default:
+		// Align both operands to the smaller exponent minus `M + 3`, so that the integer square root of the sum
+		// of their squares has at least `M + 3` bits.
+		e_min := min(a.exponent, b.exponent) - 2*int(f.M) - 3
+		u := f.alignConstant(a, e_min)
+		v := f.alignConstant(b, e_min)
+		s := new(big.Int).Add(new(big.Int).Mul(u, u), new(big.Int).Mul(v, v))
+		n := new(big.Int).Sqrt(s)
+		result, flags = f.roundConstant(false, n, e_min, new(big.Int).Mul(n, n).Cmp(s) != 0)
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::fma` if all operands are constants.
+func (f *Context) foldFMA(x, y, z FloatVar) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x, y, z)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a, b, c := cs[0], cs[1], cs[2]
+	p_sign := a.sign != b.sign
+	p_is_inf := a.isInf() && !b.isZero() || b.isInf() && !a.isZero()
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isNaN() || b.isNaN() || c.isNaN() ||
+		a.isInf() && b.isZero() || a.isZero() && b.isInf() ||
+		p_is_inf && c.isInf() && p_sign != c.sign:
+		result = f.constNaN()
+	case p_is_inf:
+		result = f.constInf(p_sign)
+	case c.isInf():
+		result = c
+	default:
+		// The product `a.mantissa * b.mantissa * 2^(a.exponent + b.exponent - 2M)` is exact, and is added to
+		// `c` exactly before rounding.
+		p := new(big.Int).Mul(a.mantissa, b.mantissa)
+		p_exponent := a.exponent + b.exponent - 2*int(f.M)
+		e := min(p_exponent, c.exponent-int(f.M))
+		p.Lsh(p, uint(p_exponent-e))
+		if p_sign {
+			p.Neg(p)
+		}
+		result, flags = f.roundSum(p.Add(p, f.alignConstant(c, e)), e, p_sign, c.sign)
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::dot` if all operands are constants, where `exponent_min` is as in `Self::sum_exact`.
+func (f *Context) foldDot(xs, ys []FloatVar, exponent_min int) (FloatVar, bool) {
+	cs, ok := f.constantsOf(append(slices.Clone(xs), ys...)...)
+	if !ok {
+		return FloatVar{}, false
+	}
+	n := len(xs)
+	signs := make([]bool, n)
+	mantissas := make([]*big.Int, n)
+	exponents := make([]int, n)
+	is_abnormal := make([]bool, n)
+	for i := range xs {
+		a, b := cs[i], cs[n+i]
+		signs[i] = a.sign != b.sign
+		mantissas[i] = new(big.Int).Mul(a.mantissa, b.mantissa)
+		exponents[i] = a.exponent + b.exponent - 2*int(f.M)
+		is_abnormal[i] = a.isAbnormal || b.isAbnormal
+	}
+	return f.foldSumExact(cs, signs, mantissas, exponents, is_abnormal, 2*f.M+2, exponent_min)
+}
+
+// Fold `Self::sum` if all operands are constants, where `exponent_min` is as in `Self::sum_exact`.
+func (f *Context) foldSum(xs []FloatVar, exponent_min int) (FloatVar, bool) {
+	cs, ok := f.constantsOf(xs...)
+	if !ok {
+		return FloatVar{}, false
+	}
+	signs := make([]bool, len(cs))
+	mantissas := make([]*big.Int, len(cs))
+	exponents := make([]int, len(cs))
+	is_abnormal := make([]bool, len(cs))
+	for i, a := range cs {
+		signs[i] = a.sign
+		mantissas[i] = a.mantissa
+		exponents[i] = a.exponent - int(f.M)
+		is_abnormal[i] = a.isAbnormal
+	}
+	return f.foldSumExact(cs, signs, mantissas, exponents, is_abnormal, f.M+1, exponent_min)
+}
+
+// Fold `Self::sum_exact` on constant terms, where `inputs` are the operands of the caller.
+// The terms are accumulated in the same window as in the circuit, so that the folded result is the same even if
+// some terms are ignored.
+func (f *Context) foldSumExact(
+	inputs []constFloat,
+	signs []bool,
+	mantissas []*big.Int,
+	exponents []int,
+	is_abnormal []bool,
+	mantissa_bit_length uint,
+	exponent_min int,
+) (FloatVar, bool) {
+	window := f.sumWindow(mantissa_bit_length, len(mantissas))
+
+	var is_nan, is_pos_inf, is_neg_inf bool
+	all_pos_zero, all_neg_zero := true, true
+	top := exponent_min
+	for i, m := range mantissas {
+		switch {
+		case is_abnormal[i] && m.Sign() == 0:
+			is_nan = true
+		case is_abnormal[i]:
+			is_pos_inf = is_pos_inf || !signs[i]
+			is_neg_inf = is_neg_inf || signs[i]
+		case m.Sign() != 0:
+			top = max(top, exponents[i])
+		}
+		all_pos_zero = all_pos_zero && m.Sign() == 0 && !signs[i]
+		all_neg_zero = all_neg_zero && m.Sign() == 0 && signs[i]
+	}
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case is_nan || is_pos_inf && is_neg_inf:
+		result = f.constNaN()
+	case is_pos_inf || is_neg_inf:
+		result = f.constInf(is_neg_inf)
+	default:
+		// Accumulate the terms in units of `2^(top - window)`, where the terms below the window are ignored.
+		s := new(big.Int)
+		is_ignored := false
+		for i, m := range mantissas {
+			if m.Sign() == 0 {
+				continue
+			}
+			if top-exponents[i] > window {
+				is_ignored = true
+				continue
+			}
+			t := new(big.Int).Lsh(m, uint(window-top+exponents[i]))
+			if signs[i] {
+				s.Sub(s, t)
+			} else {
+				s.Add(s, t)
+			}
+		}
+		if s.Sign() == 0 {
+			if f.RoundingMode == RoundTowardNegative {
+				result = f.constZero(!all_pos_zero)
+			} else {
+				result = f.constZero(all_neg_zero)
+			}
+		} else {
+			result, flags = f.roundConstant(s.Sign() < 0, new(big.Int).Abs(s), top-window, false)
+			// As in the circuit, the ignored terms make the result inexact, and it is tiny if the exact sum of
+			// the kept terms is (or if the rounded one is when subnormal numbers are flushed, which has been
+			// handled by `Self::round_constant`).
+			if is_ignored && !f.FlushSubnormals && top-window+s.BitLen()-1 < int(f.E_NORMAL_MIN.Int64()) {
+				flags.underflow = true
+			}
+		}
+		flags.inexact = flags.inexact || is_ignored
+	}
+	return f.finishFolded(inputs, result, flags)
+}
+
+// Fold `Self::remainder` if both operands are constants.
+func (f *Context) foldRemainder(x, y FloatVar, is_nearest bool) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a, b := cs[0], cs[1]
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isAbnormal || b.isNaN() || b.isZero():
+		result = f.constNaN()
+	case b.isInf():
+		result = a
+	default:
+		// Compute `|a| = q * |b| + r` exactly, and round the quotient to the nearest integer if required.
+		e := min(a.exponent, b.exponent) - int(f.M)
+		aa := new(big.Int).Abs(f.alignConstant(a, e))
+		bb := new(big.Int).Abs(f.alignConstant(b, e))
+		q, r := new(big.Int).QuoRem(aa, bb, new(big.Int))
+		sign := a.sign
+		if is_nearest {
+			if cmp := new(big.Int).Lsh(r, 1).Cmp(bb); cmp > 0 || cmp == 0 && q.Bit(0) == 1 {
+				r.Sub(bb, r)
+				sign = !sign
+			}
+		}
+		if r.Sign() == 0 {
+			// A zero result has the same sign as `a`.
+			result = f.constZero(a.sign)
+		} else {
+			// The result is exact, but it may be flushed to zero.
+			result, flags = f.roundConstant(sign, r, e, false)
+		}
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Convert` if `x` is a constant, where the result is in the format of `to`.
+func foldConvert(from, to *Context, x FloatVar) (FloatVar, bool) {
+	cs, ok := from.constantsOf(x)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a := cs[0]
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isNaN():
+		result = to.constNaN()
+		result.sign = a.sign
+	case a.isInf():
+		result = to.constInf(a.sign)
+	case a.isZero():
+		result = to.constZero(a.sign)
+	case to.E >= from.E && to.M >= from.M:
+		// The conversion is exact and only pads the mantissa, even if the result would be subnormal in `to`'s
+		// format, which never happens.
+		result = constFloat{a.sign, a.exponent, new(big.Int).Lsh(a.mantissa, to.M-from.M), false}
+	default:
+		result, flags = to.roundConstant(a.sign, a.mantissa, a.exponent-int(from.M), false)
+	}
+	return to.finishFolded(cs, result, flags)
+}
+
+// Compare two non-NaN constants, where -0 is equal to +0.
+// Return -1, 0 or 1 if `x` is less than, equal to or greater than `y`, respectively.
+func compareConstants(x, y constFloat) int {
+	if x.isZero() && y.isZero() {
+		return 0
+	}
+	if x.sign != y.sign {
+		if x.sign {
+			return -1
+		}
+		return 1
+	}
+	cmp := compareMagnitudes(x, y)
+	if x.sign {
+		return -cmp
+	}
+	return cmp
+}
+
+// Compare the magnitudes of two constants lexicographically by `(exponent, mantissa)` as in
+// `Self::compare_for_min_max`.
+func compareMagnitudes(x, y constFloat) int {
+	if x.exponent != y.exponent {
+		if x.exponent < y.exponent {
+			return -1
+		}
+		return 1
+	}
+	return x.mantissa.Cmp(y.mantissa)
+}
+
+// The counterpart of `Self::compare_for_min_max` for constants.
+func compareConstantsForMinMax(x, y constFloat) (bool, bool, bool) {
+	cmp := compareMagnitudes(x, y)
+	x_lt_y := x.sign
+	if x.sign == y.sign {
+		x_lt_y = x.sign && cmp > 0 || !x.sign && cmp < 0
+	}
+	return x_lt_y, cmp < 0, cmp > 0
+}
+
+// Fold `Self::less` if both operands are constants.
+func (f *Context) foldLess(x, y FloatVar, allow_eq uint) (frontend.Variable, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return nil, false
+	}
+	if cs[0].isNaN() || cs[1].isNaN() {
+		f.raiseFoldedFlags(constFlags{invalid: true})
+		return big.NewInt(0), true
+	}
+	cmp := compareConstants(cs[0], cs[1])
+	return boolToBig(cmp < 0 || cmp == 0 && allow_eq == 1), true
+}
+
+// Fold `Self::is_eq` if both operands are constants.
+func (f *Context) foldIsEq(x, y FloatVar) (frontend.Variable, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return nil, false
+	}
+	return boolToBig(!cs[0].isNaN() && !cs[1].isNaN() && compareConstants(cs[0], cs[1]) == 0), true
+}
+
+// Fold `Self::total_order` if both operands are constants, where NaN is lifted above infinity as in the
+// circuit.
+func (f *Context) foldTotalOrder(x, y FloatVar) (frontend.Variable, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return nil, false
+	}
+	for i := range cs {
+		if cs[i].isNaN() {
+			cs[i].mantissa = new(big.Int).Lsh(big.NewInt(1), f.M+1)
+		}
+	}
+	y_lt_x, _, _ := compareConstantsForMinMax(cs[1], cs[0])
+	return boolToBig(!y_lt_x), true
+}
+
+// Fold the min/max family if both operands are constants, where `choose_x` decides whether `x` is the result
+// given the outputs of `compare_constants_for_min_max`.
+func (f *Context) foldMinMax(x, y FloatVar, choose_x func(x, y constFloat, x_lt_y, x_mag_lt_y, y_mag_lt_x bool) bool) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return FloatVar{}, false
+	}
+	x_lt_y, x_mag_lt_y, y_mag_lt_x := compareConstantsForMinMax(cs[0], cs[1])
+	if choose_x(cs[0], cs[1], x_lt_y, x_mag_lt_y, y_mag_lt_x) {
+		return x, true
+	}
+	return y, true
+}
+
+// Fold `Self::round_to_integral` if `x` is a constant.
+func (f *Context) foldRoundToIntegral(x FloatVar, mode RoundingMode) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a := cs[0]
+	if a.isAbnormal || a.isZero() || a.exponent >= int(f.M) {
+		// `x` is already integral.
+		return x, true
+	}
+	q, _ := roundShifted(a.mantissa, int(f.M)-a.exponent, false, a.sign, mode)
+	if q.Sign() == 0 {
+		return f.newFolded(f.constZero(a.sign)), true
+	}
+	return f.newFolded(constFloat{
+		a.sign,
+		q.BitLen() - 1,
+		new(big.Int).Lsh(q, uint(int(f.M)+1-q.BitLen())),
+		false,
+	}), true
+}
+
+// Fold `Self::to_int` if `x` is a constant whose exponent is in the range of the powers-of-two table, where
+// the result is computed in the native field as in the circuit.
+func (f *Context) foldToInt(x FloatVar) (frontend.Variable, bool) {
+	cs, ok := f.constantsOf(x)
+	if !ok {
+		return nil, false
+	}
+	a := cs[0]
+	e := a.exponent
+	if a.exponent == int(f.E_MIN.Int64()) {
+		e = 0
+	}
+	if e < 0 || e >= int(f.Gadget.PowersOfTwoSize()) {
+		return nil, false
+	}
+	modulus := f.Api.Compiler().Field()
+	v := new(big.Int).Lsh(a.mantissa, uint(e))
+	v.Mul(v, new(big.Int).ModInverse(new(big.Int).Lsh(big.NewInt(1), f.M), modulus))
+	if a.sign {
+		v.Neg(v)
+	}
+	return v.Mod(v, modulus), true
+}
+
+// Fold `Self::to_int_checked` if `x` is a constant.
+func (f *Context) foldToIntChecked(x FloatVar, bits uint, signed bool, mode RoundingMode) (frontend.Variable, frontend.Variable, bool) {
+	cs, ok := f.constantsOf(x)
+	if !ok {
+		return nil, nil, false
+	}
+	a := cs[0]
+
+	is_valid := !a.isAbnormal
+	var magnitude *big.Int
+	if is_valid {
+		magnitude, _ = roundShifted(a.mantissa, int(f.M)-a.exponent, false, a.sign, mode)
+		var bound *big.Int
+		if signed {
+			bound = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits-1), big.NewInt(1))
+			if a.sign {
+				bound.Add(bound, big.NewInt(1))
+			}
+		} else if a.sign {
+			bound = big.NewInt(0)
+		} else {
+			bound = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits), big.NewInt(1))
+		}
+		is_valid = magnitude.Cmp(bound) <= 0
+	}
+	if !is_valid {
+		f.raiseFoldedFlags(constFlags{invalid: true})
+		if signed {
+			return new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), bits-1)), big.NewInt(1), true
+		}
+		return new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), bits), big.NewInt(1)), big.NewInt(1), true
+	}
+	if a.sign {
+		magnitude.Neg(magnitude)
+	}
+	return magnitude, big.NewInt(0), true
+}
+
+// Return the integer `v` if it is a constant in the range of integers with `bitWidth` bits, where a negative
+// integer is represented as in `Self::from_int` if `signed` is true.
+func (f *Context) intConstant(v frontend.Variable, bitWidth uint, signed bool) (*big.Int, bool) {
+	c, ok := f.Api.Compiler().ConstantValue(v)
+	if !ok {
+		return nil, false
+	}
+	lo, hi := big.NewInt(0), new(big.Int).Lsh(big.NewInt(1), bitWidth)
+	if signed {
+		modulus := f.Api.Compiler().Field()
+		if c.Cmp(new(big.Int).Rsh(modulus, 1)) > 0 {
+			c = new(big.Int).Sub(c, modulus)
+		}
+		lo, hi = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), bitWidth-1)), new(big.Int).Lsh(big.NewInt(1), bitWidth-1)
+	}
+	if c.Cmp(lo) < 0 || c.Cmp(hi) >= 0 {
+		// The circuit is unsatisfiable, so we don't fold.
+		return nil, false
+	}
+	return c, true
+}
+
+// Fold `Self::from_int` if `v` is a constant in the range of integers with `bitWidth` bits.
+func (f *Context) foldFromInt(v frontend.Variable, bitWidth uint, signed bool) (FloatVar, bool) {
+	c, ok := f.intConstant(v, bitWidth, signed)
+	if !ok {
+		return FloatVar{}, false
+	}
+	if c.Sign() == 0 {
+		return f.finishFolded(nil, f.constZero(false), constFlags{})
+	}
+	result, flags := f.roundConstant(c.Sign() < 0, new(big.Int).Abs(c), 0, false)
+	return f.finishFolded(nil, result, flags)
+}
+
+// Fold `Self::scale_b` if `x` is a constant, where the constant `n` is at most `2^E + M` in magnitude.
+func (f *Context) foldScaleB(x FloatVar, n *big.Int) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a := cs[0]
+	if a.isAbnormal || a.isZero() {
+		return f.finishFolded(cs, a, constFlags{})
+	}
+	result, flags := f.roundConstant(a.sign, a.mantissa, a.exponent-int(f.M)+int(n.Int64()), false)
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::logb` if `x` is a constant.
+func (f *Context) foldLogb(x FloatVar) (FloatVar, bool) {
+	cs, ok := f.constantsOf(x)
+	if !ok {
+		return FloatVar{}, false
+	}
+	a := cs[0]
+
+	var result constFloat
+	var flags constFlags
+	switch {
+	case a.isNaN():
+		result = f.constNaN()
+	case a.isInf():
+		result = f.constInf(false)
+	case a.isZero():
+		result = f.constInf(true)
+		flags.divisionByZero = true
+	case a.exponent == 0:
+		result = f.constZero(false)
+	default:
+		result, flags = f.roundConstant(a.exponent < 0, big.NewInt(int64(max(a.exponent, -a.exponent))), 0, false)
+	}
+	return f.finishFolded(cs, result, flags)
+}
+
+// Fold `Self::is_subnormal` if `x` is a constant.
+func (f *Context) foldIsSubnormal(x FloatVar) (frontend.Variable, bool) {
+	cs, ok := f.constantsOf(x)
+	if !ok {
+		return nil, false
+	}
+	a := cs[0]
+	return boolToBig(!a.isAbnormal && a.mantissa.Sign() != 0 && a.exponent < int(f.E_NORMAL_MIN.Int64())), true
+}
+
+// Fold `Self::ulp_distance_le` if both operands are constants.
+func (f *Context) foldUlpDistanceLe(x, y FloatVar, n uint) (frontend.Variable, bool) {
+	cs, ok := f.constantsOf(x, y)
+	if !ok {
+		return nil, false
+	}
+	if cs[0].isNaN() || cs[1].isNaN() {
+		return boolToBig(cs[0].isNaN() && cs[1].isNaN()), true
+	}
+	// Compute the ordered-integer encoding as in `Self::ulp_distance_le`.
+	e_normal_min := int(f.E_NORMAL_MIN.Int64())
+	encode := func(v constFloat) *big.Int {
+		k := max(e_normal_min-v.exponent, 0)
+		magnitude := new(big.Int).Lsh(big.NewInt(int64(v.exponent+k-e_normal_min)), f.M)
+		magnitude.Add(magnitude, new(big.Int).Rsh(v.mantissa, uint(k)))
+		if v.sign {
+			magnitude.Neg(magnitude)
+		}
+		return magnitude
+	}
+	distance := new(big.Int).Sub(encode(cs[0]), encode(cs[1]))
+	return boolToBig(distance.Abs(distance).Cmp(new(big.Int).SetUint64(uint64(n))) <= 0), true
+}
@@ -0,0 +1,258 @@
+package float
+
+import (
+	"math/big"
+	"math/bits"
+
+	"github.com/consensys/gnark/frontend"
+
+	"github.com/tumberger/zk-Location/gadget"
+	"github.com/tumberger/zk-Location/hint"
+)
+
+// `WideFloat` is an unnormalized intermediate number `Mantissa * 2^Exponent` for chains of additions and
+// multiplications, which are computed by `Self::wide_add`, `Self::wide_sub` and `Self::wide_mul` without
+// normalizing and rounding every intermediate result to `M + 1` bits, and rounded to a `FloatVar` only once
+// by `Self::normalize`.
+//
+// Error semantics: `Self::widen` and `Self::normalize` of a widened number are exact. `Self::wide_mul` is exact
+// unless the product of the mantissas exceeds `W = min(2 * (M + 3), (F::MODULUS_BIT_SIZE - 8) / 2)` bits (e.g.,
+// 52 for binary32 and 110 for binary64), in which case it is rounded to odd with `W` bits, i.e., its relative
+// error is less than `2^(1 - W)` times the bound `2^bits` of the product's mantissa (instead of the product
+// itself). `Self::wide_add` rounds the exact sum to odd in units of `2^(e - 3)`, where `e` is the larger of the
+// operands' exponents, or in coarser units if its result would exceed `W` bits.
+// Hence, a single operation on widened numbers followed by `Self::normalize` is correctly rounded, as rounding
+// to odd with at least `M + 3` bits and then to nearest is the same as rounding to nearest directly. In a longer
+// chain, the errors are bounded by the magnitudes of the operands rather than the result, as in fixed-point
+// arithmetic, so catastrophic cancellation in a chain is less accurate than in the chained `FloatVar`
+// operations, and more accurate otherwise.
+//
+// Special values: NaN and infinities propagate as in the chained operations, except that the sum of two
+// infinities is NaN even if they have the same sign, since the sign of a wide number is unknown before it is
+// normalized. An exact zero result is `+0`, or `-0` when rounding toward negative infinity, regardless of the
+// signs of zero operands, e.g., `Self::normalize(Self::widen(-0))` is `+0`. In `FiniteOnly` mode, all wide
+// numbers are assumed to be finite as well.
+type WideFloat struct {
+	// The signed mantissa, whose absolute value is less than `2^bits`.
+	Mantissa frontend.Variable
+	// The exponent of the mantissa's LSB, unlike `FloatVar.Exponent`.
+	Exponent frontend.Variable
+	// Whether the number is NaN or infinity. As in `FloatVar`, an abnormal number is NaN if its mantissa is 0,
+	// and infinity with the mantissa's sign otherwise.
+	IsAbnormal frontend.Variable
+
+	bits uint
+	// The range of `Exponent`, which is known at compile time.
+	exponent_min, exponent_max int
+	// The numbers that are widened to compute this number, which determine the exception flags.
+	inputs []FloatVar
+}
+
+// Return the maximum bit length `W` of wide mantissas, so that the sum of two wide numbers, whose alignment
+// more than doubles the bit length, and the product of two wide mantissas fit in the native field.
+func (f *Context) wideBitLength() uint {
+	return min(2*(f.M+3), uint(f.Api.Compiler().Field().BitLen()-8)/2)
+}
+
+// Round the signed integer `m`, whose absolute value has at most `bit_length` bits, to odd in units of `2^(k - 1)`,
+// i.e., compute `sign(m) * (2 * floor(|m| / 2^k) + (|m| mod 2^k != 0))`.
+// Unlike truncation, rounding to odd keeps a nonzero `m` nonzero with the same sign, and the result still
+// distinguishes whether the dropped bits were all 0, which makes the final rounding in `Self::normalize`
+// correct.
+func (f *Context) roundToOdd(m frontend.Variable, bit_length, k uint) frontend.Variable {
+	if k == 0 {
+		return m
+	}
+	outputs, err := f.Api.Compiler().NewHint(hint.AbsHint, 2, m)
+	if err != nil {
+		panic(err)
+	}
+	m_ge_0 := outputs[0]
+	f.Api.AssertIsBoolean(m_ge_0)
+	outputs, err = f.Api.Compiler().NewHint(hint.TruncHint, 1, outputs[1], k)
+	if err != nil {
+		panic(err)
+	}
+	hi := outputs[0]
+	m_abs := f.Api.Select(
+		m_ge_0,
+		m,
+		f.Api.Neg(m),
+	)
+	lo := f.Api.Sub(m_abs, f.Api.Mul(hi, new(big.Int).Lsh(big.NewInt(1), k)))
+	// Enforce that `0 <= hi < 2^(bit_length - k)` and `0 <= lo < 2^k`, so that `|m| = hi || lo` holds over the
+	// integers. If `m_ge_0` is wrong, `m_abs` is the negation of a small number, which cannot be decomposed in
+	// this way unless `m` is 0.
+	f.Gadget.AssertBitLength(hi, bit_length-k, gadget.TightForUnknownRange)
+	f.Gadget.AssertBitLength(lo, k, gadget.TightForUnknownRange)
+	result := f.Api.Add(f.Api.Add(hi, hi), f.Api.Sub(big.NewInt(1), f.Api.IsZero(lo)))
+	return f.Api.Select(
+		m_ge_0,
+		result,
+		f.Api.Neg(result),
+	)
+}
+
+// Convert `x` to a wide number exactly.
+func (f *Context) Widen(x FloatVar) WideFloat {
+	var is_abnormal frontend.Variable = 0
+	if !f.FiniteOnly {
+		is_abnormal = x.IsAbnormal
+	}
+	// The exponent of the LSB of a finite number ranges from 0's `E_MIN - M` to `E_MAX - 1 - M`, and is
+	// `E_MAX - M` for abnormal numbers.
+	return WideFloat{
+		Mantissa: f.Api.Select(
+			x.Sign,
+			f.Api.Neg(x.Mantissa),
+			x.Mantissa,
+		),
+		Exponent:     f.Api.Sub(x.Exponent, big.NewInt(int64(f.M))),
+		IsAbnormal:   is_abnormal,
+		bits:         f.M + 1,
+		exponent_min: int(f.E_MIN.Int64()) - int(f.M),
+		exponent_max: int(f.E_MAX.Int64()) - int(f.M),
+		inputs:       []FloatVar{x},
+	}
+}
+
+// Compute `x + y` without normalization. See `WideFloat` for the error semantics.
+func (f *Context) WideAdd(x, y WideFloat) WideFloat {
+	bit_length := max(x.bits, y.bits)
+	// As in `Self::add`, we align the mantissas by left shifting the mantissa of the number with larger exponent
+	// by `K = shift_max` bits and the other one by `K - min(delta, K)` bits, where `delta` is the difference
+	// between the exponents. Since the bit length of both mantissas is `K - 2`, a mantissa shifted by `0` bits is
+	// below the `K - 2` bits dropped by the rounding below and only contributes to the sticky bit, just like a
+	// larger `delta` would.
+	shift_max := bit_length + 2
+	delta_max := max(x.exponent_max-y.exponent_min, y.exponent_max-x.exponent_min)
+	delta, ex_ge_ey := f.Gadget.Abs(f.Api.Sub(x.Exponent, y.Exponent), uint(max(bits.Len(uint(delta_max)), 1)))
+	if delta_max > int(shift_max) {
+		delta = f.Gadget.Min(delta, big.NewInt(int64(shift_max)), uint(bits.Len(uint(delta_max))))
+	}
+	two_to_delta := f.Gadget.QueryBoundedPowerOf2(f.Api.Sub(big.NewInt(int64(shift_max)), delta), shift_max)
+
+	// `zz` is the mantissa of the number with smaller exponent, and `ww` is the mantissa of another number.
+	ww := f.Api.Select(
+		ex_ge_ey,
+		x.Mantissa,
+		y.Mantissa,
+	)
+	zz := f.Api.Sub(f.Api.Add(x.Mantissa, y.Mantissa), ww)
+	exponent := f.Api.Select(
+		ex_ge_ey,
+		x.Exponent,
+		y.Exponent,
+	)
+	s := f.Api.Add(f.Api.Mul(ww, new(big.Int).Lsh(big.NewInt(1), shift_max)), f.Api.Mul(zz, two_to_delta))
+
+	// Round `s`, which has at most `bit_length + shift_max + 1` bits in units of `2^(exponent - shift_max)`, to
+	// odd in units of `2^(exponent - 3)`, and `extra` bits coarser if the result would exceed `W` bits.
+	extra := uint(max(int(bit_length)+4-int(f.wideBitLength()), 0))
+	mantissa := f.roundToOdd(s, bit_length+shift_max+1, shift_max-2+extra)
+	result := WideFloat{
+		Mantissa:     mantissa,
+		Exponent:     f.Api.Add(exponent, big.NewInt(int64(extra)-3)),
+		IsAbnormal:   0,
+		bits:         bit_length + 4 - extra,
+		exponent_min: max(x.exponent_min, y.exponent_min) + int(extra) - 3,
+		exponent_max: max(x.exponent_max, y.exponent_max) + int(extra) - 3,
+		inputs:       append(append([]FloatVar{}, x.inputs...), y.inputs...),
+	}
+	if !f.FiniteOnly {
+		// An abnormal operand determines the result, and the sum of two abnormal numbers is NaN.
+		result.IsAbnormal = f.Api.Or(x.IsAbnormal, y.IsAbnormal)
+		result.Mantissa = f.Api.Select(
+			x.IsAbnormal,
+			f.Api.Select(y.IsAbnormal, big.NewInt(0), x.Mantissa),
+			f.Api.Select(y.IsAbnormal, y.Mantissa, mantissa),
+		)
+	}
+	return result
+}
+
+// Compute `x - y` without normalization. See `WideFloat` for the error semantics.
+func (f *Context) WideSub(x, y WideFloat) WideFloat {
+	y.Mantissa = f.Api.Neg(y.Mantissa)
+	return f.WideAdd(x, y)
+}
+
+// Compute `x * y` without normalization. See `WideFloat` for the error semantics.
+func (f *Context) WideMul(x, y WideFloat) WideFloat {
+	// The product of the mantissas is also correct for abnormal operands: it is 0 (i.e., NaN) if either
+	// operand is NaN or in `inf * 0`, and otherwise has the sign of the infinite product.
+	result := WideFloat{
+		Mantissa:     f.Api.Mul(x.Mantissa, y.Mantissa),
+		Exponent:     f.Api.Add(x.Exponent, y.Exponent),
+		IsAbnormal:   0,
+		bits:         x.bits + y.bits,
+		exponent_min: x.exponent_min + y.exponent_min,
+		exponent_max: x.exponent_max + y.exponent_max,
+		inputs:       append(append([]FloatVar{}, x.inputs...), y.inputs...),
+	}
+	if !f.FiniteOnly {
+		result.IsAbnormal = f.Api.Or(x.IsAbnormal, y.IsAbnormal)
+	}
+	if w := f.wideBitLength(); result.bits > w {
+		k := result.bits - w + 1
+		result.Mantissa = f.roundToOdd(result.Mantissa, result.bits, k)
+		result.Exponent = f.Api.Add(result.Exponent, big.NewInt(int64(k)-1))
+		result.bits = w
+		result.exponent_min += int(k) - 1
+		result.exponent_max += int(k) - 1
+	}
+	return result
+}
+
+// Round the wide number `x` to a `FloatVar`.
+func (f *Context) Normalize(x WideFloat) FloatVar {
+	// `Self::normalize_sticky` requires at least `M + 3` bits, and we reserve one more bit for the sticky bit,
+	// so that the range checks on the dropped bits are not empty.
+	s_bit_length := max(x.bits, f.M+4)
+	mantissa, exponent, s_lt_0, s_is_zero := f.normalizeSticky(x.Mantissa, s_bit_length, x.Exponent)
+
+	// The exponent of the MSB is in the range `[exponent_min, exponent_max]`, which may exceed the range
+	// supported by `Self::round_subnormal` and `Self::fix_overflow` after many multiplications. Since a number
+	// less than `2^(E_NORMAL_MIN - M - 2)` is rounded in the same way as any smaller one, and a number not less
+	// than `2^E_MAX` always overflows, we clamp the exponent to `[E_NORMAL_MIN - M - 3, E_MAX]` if necessary.
+	exponent_min := x.exponent_min
+	exponent_max := x.exponent_max + int(s_bit_length) - 1
+	if lower := int(f.E_NORMAL_MIN.Int64()) - int(f.M) - 3; exponent_min <= int(f.E_MAX.Int64())-(1<<(f.E+1))+1 {
+		exponent = f.Gadget.Max(exponent, big.NewInt(int64(lower)), uint(bits.Len(uint(max(lower-exponent_min, exponent_max-lower)))))
+		exponent_min = lower
+	}
+	if upper := int(f.E_MAX.Int64()); exponent_max >= int(f.E_NORMAL_MIN.Int64())+(1<<(f.E+1))-int(f.M)-3 {
+		exponent = f.Gadget.Min(exponent, big.NewInt(int64(upper)), uint(bits.Len(uint(max(upper-exponent_min, exponent_max-upper)))))
+	}
+
+	var zero_sign frontend.Variable = big.NewInt(0)
+	if f.RoundingMode == RoundTowardNegative {
+		zero_sign = big.NewInt(1)
+	}
+	sign := f.Api.Select(
+		s_is_zero,
+		zero_sign,
+		s_lt_0,
+	)
+
+	mantissa, exponent, is_inexact, is_tiny := f.roundSubnormal(mantissa, f.M+4, exponent, f.M+2, 1, sign)
+	mantissa, exponent, is_abnormal, is_overflow := f.fixOverflow(
+		mantissa,
+		f.Api.IsZero(mantissa),
+		exponent,
+		x.IsAbnormal,
+		sign,
+	)
+
+	result := FloatVar{
+		Sign:       sign,
+		Exponent:   exponent,
+		Mantissa:   mantissa,
+		IsAbnormal: is_abnormal,
+	}
+	if !f.FiniteOnly {
+		result.Mantissa = f.Api.Select(f.Api.And(x.IsAbnormal, s_is_zero), big.NewInt(0), mantissa)
+	}
+	f.raiseFlags(x.inputs, result, nil, is_inexact, is_tiny, is_overflow)
+	return result
+}
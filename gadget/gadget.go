@@ -31,6 +31,10 @@ func NewPowersOfTwoTable(api frontend.API, size uint) *PowersOfTwo {
 }
 
 func (t *PowersOfTwo) commit(api frontend.API) error {
+	// The table may be unused, e.g., if all numbers are constants or subnormal numbers are flushed to zero.
+	if len(t.queries) == 0 {
+		return nil
+	}
 	return logderivarg.Build(api, t.entries, t.queries)
 }
 
@@ -51,6 +55,11 @@ func New(api frontend.API, range_size uint, pow2_size uint) *IntGadget {
 	return &IntGadget{api, rangechecker, pow2, range_size, pow2_size, 0, 0, 0}
 }
 
+// Return the size of the powers-of-two table, i.e., `QueryPowerOf2` accepts exponents in `[0, size - 1]`.
+func (f *IntGadget) PowersOfTwoSize() uint {
+	return f.pow2_size
+}
+
 func (f *IntGadget) LookupEntryConstraints() uint {
 	return (1 << f.range_size) + f.pow2_size
 }
@@ -135,3 +144,38 @@ func (f *IntGadget) QueryPowerOf2(exponent frontend.Variable) frontend.Variable
 	f.num_pow2_queries++
 	return result
 }
+
+// Same as `QueryPowerOf2`, but `exponent` is allowed to be as large as `2 * (size - 1)`, where `size` is the
+// size of the powers-of-two table.
+// This is done by splitting `exponent` into `lo + hi` with `lo = min(exponent, size - 1)` and querying both
+// `2^lo` and `2^hi`. Since both queries enforce that the exponent is in the range `[0, size - 1]`, the product
+// is guaranteed to be `2^exponent`.
+func (f *IntGadget) QueryLargePowerOf2(exponent frontend.Variable) frontend.Variable {
+	outputs, err := f.api.Compiler().NewHint(hint.MinHint, 1, exponent, len(f.pow2.entries)-1)
+	if err != nil {
+		panic(err)
+	}
+	lo := outputs[0]
+	return f.api.Mul(f.QueryPowerOf2(lo), f.QueryPowerOf2(f.api.Sub(exponent, lo)))
+}
+
+// Same as `QueryPowerOf2`, but `exponent` is allowed to be as large as `exponent_max`, which may even exceed
+// `2 * (size - 1)`.
+// Similar to `QueryLargePowerOf2`, this is done by splitting `exponent` into `ceil(exponent_max / (size - 1))`
+// chunks, where each chunk is `min(rest, size - 1)` except the last one, and querying the power of 2 of each chunk.
+// Since every query enforces that its chunk is in the range `[0, size - 1]`, the product is guaranteed to be
+// `2^exponent`.
+func (f *IntGadget) QueryBoundedPowerOf2(exponent frontend.Variable, exponent_max uint) frontend.Variable {
+	chunk_max := uint(len(f.pow2.entries) - 1)
+	var result frontend.Variable = 1
+	for ; exponent_max > chunk_max; exponent_max -= chunk_max {
+		outputs, err := f.api.Compiler().NewHint(hint.MinHint, 1, exponent, chunk_max)
+		if err != nil {
+			panic(err)
+		}
+		lo := outputs[0]
+		result = f.api.Mul(result, f.QueryPowerOf2(lo))
+		exponent = f.api.Sub(exponent, lo)
+	}
+	return f.api.Mul(result, f.QueryPowerOf2(exponent))
+}
@@ -17,12 +17,23 @@ func init() {
 	solver.RegisterHint(AbsHint)
 	solver.RegisterHint(DivHint)
 	solver.RegisterHint(SqrtHint)
+	solver.RegisterHint(CbrtHint)
 	solver.RegisterHint(TruncHint)
 	solver.RegisterHint(FloorHint)
+	solver.RegisterHint(MinHint)
 	solver.RegisterHint(PrecomputeHint64)
 	solver.RegisterHint(PrecomputeHint32)
 }
 
+func MinHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
+	if inputs[0].Cmp(inputs[1]) < 0 {
+		outputs[0].Set(inputs[0])
+	} else {
+		outputs[0].Set(inputs[1])
+	}
+	return nil
+}
+
 func PrecomputeHint64(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
 	v := inputs[0].Uint64()
 	E := inputs[1].Uint64()
@@ -106,14 +117,16 @@ func PrecomputeHint32(field *big.Int, inputs []*big.Int, outputs []*big.Int) err
 }
 
 func DecodeFloatHint(field *big.Int, inputs []*big.Int, outputs []*big.Int) error {
-	v := inputs[0].Uint64()
-	E := inputs[1].Uint64()
-	M := inputs[2].Uint64()
-	s := v >> (E + M)
-	e := (v >> M) - (s << E)
-
-	outputs[0].SetUint64(s)
-	outputs[1].SetUint64(e)
+	// The value may have more than 64 bits (e.g., binary128), so we work with `big.Int` directly.
+	v := inputs[0]
+	E := uint(inputs[1].Uint64())
+	M := uint(inputs[2].Uint64())
+	s := v.Bit(int(E + M))
+	e := new(big.Int).Rsh(v, M)
+	e.SetBit(e, int(E), 0)
+
+	outputs[0].SetUint64(uint64(s))
+	outputs[1].Set(e)
 	return nil
 }
 
@@ -221,6 +234,18 @@ func SqrtHint(
 	return nil
 }
 
+func CbrtHint(
+	field *big.Int,
+	inputs []*big.Int,
+	outputs []*big.Int,
+) error {
+	x := new(big.Int).Set(inputs[0])
+
+	outputs[0].Set(util.CbrtFloor(x))
+
+	return nil
+}
+
 func TruncHint(
 	field *big.Int,
 	inputs []*big.Int,
@@ -1,4 +1,4 @@
-package loc2index64
+package loc2index32
 
 import (
 	"bufio"
@@ -17,6 +17,8 @@ import (
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
 
 	"github.com/consensys/gnark/test"
 )
@@ -32,11 +34,16 @@ type loc2Index32Circuit struct {
 	I          frontend.Variable `gnark:",public"`
 	J          frontend.Variable `gnark:",public"`
 	K          frontend.Variable `gnark:",public"`
+
+	option func(*float.Context) // An optional function that configures the context before allocating numbers
 }
 
 func (c *loc2Index32Circuit) Define(api frontend.API) error {
 
 	ctx := float.NewContext(api, 0, util.IEEE32ExponentBitwidth, util.IEEE32Precision)
+	if c.option != nil {
+		c.option(&ctx)
+	}
 	lat := ctx.NewFloat(c.Lat)
 	lng := ctx.NewFloat(c.Lng)
 
@@ -229,7 +236,7 @@ func (c *loc2Index32CircuitWrapper) Define(api frontend.API) error {
 
 	// Adding half pi to latitude to apply cos() -- lat always in range [-pi/2, pi/2]
 	term := ctx.Add(lat, halfPi)
-	cosLat := maths.SinTaylor32(&ctx, term)
+	cosLat := maths.SinTaylor(&ctx, term)
 
 	// Adding half pi to longitude to apply cos() -- lng always in range [-pi, pi]
 	tmp := ctx.Add(lng, halfPi)
@@ -242,13 +249,13 @@ func (c *loc2Index32CircuitWrapper) Define(api frontend.API) error {
 	term.Mantissa = api.Select(isGreater, shifted.Mantissa, tmp.Mantissa)
 	term.IsAbnormal = 0
 
-	cosLng := maths.SinTaylor32(&ctx, term)
+	cosLng := maths.SinTaylor(&ctx, term)
 	x := ctx.Mul(cosLat, cosLng)
 
-	sinLng := maths.SinTaylor32(&ctx, lng)
+	sinLng := maths.SinTaylor(&ctx, lng)
 	y := ctx.Mul(cosLat, sinLng)
 
-	z := maths.SinTaylor32(&ctx, lat)
+	z := maths.SinTaylor(&ctx, lat)
 
 	calc := closestFaceCalculations(&ctx, x, y, z, lng)
 
@@ -405,3 +412,39 @@ func BenchmarkLoc2IndexProofMemory(b *testing.B) {
 		}
 	}
 }
+
+// The number of constraints of `loc2Index32Circuit` before constant operands got their own fast paths in `float`.
+var loc2Index32BaselineConstraints = [2]int{19714, 55810}
+
+func TestLoc2Index32Constraints(t *testing.T) {
+	compile := func(option func(*float.Context)) [2]int {
+		var constraints [2]int
+		for i, builder := range []frontend.NewBuilder{r1cs.NewBuilder, scs.NewBuilder} {
+			cs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, &loc2Index32Circuit{option: option})
+			if err != nil {
+				t.Fatal(err)
+			}
+			constraints[i] = cs.GetNbConstraints()
+		}
+		return constraints
+	}
+	constraints := compile(nil)
+	flushed := compile(func(ctx *float.Context) { ctx.FlushSubnormals = true })
+
+	var result strings.Builder
+	result.WriteString("Variant, R1CS, PLONK\n")
+	result.WriteString(fmt.Sprintf("Baseline, %d, %d\n", loc2Index32BaselineConstraints[0], loc2Index32BaselineConstraints[1]))
+	result.WriteString(fmt.Sprintf("Current, %d, %d\n", constraints[0], constraints[1]))
+	result.WriteString(fmt.Sprintf("FlushSubnormals, %d, %d\n", flushed[0], flushed[1]))
+
+	for i, backend := range []string{"R1CS", "PLONK"} {
+		if constraints[i] > loc2Index32BaselineConstraints[i] {
+			t.Errorf("%s: loc2index32 costs %d constraints, but %d before", backend, constraints[i], loc2Index32BaselineConstraints[i])
+		}
+	}
+
+	err := os.WriteFile("../benchmarks/tests/loc2index32_constraints.csv", []byte(result.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend"
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
 
 	"github.com/consensys/gnark/test"
 )
@@ -30,11 +32,16 @@ type loc2Index64Circuit struct {
 	I          frontend.Variable `gnark:",public"`
 	J          frontend.Variable `gnark:",public"`
 	K          frontend.Variable `gnark:",public"`
+
+	option func(*float.Context) // An optional function that configures the context before allocating numbers
 }
 
 func (c *loc2Index64Circuit) Define(api frontend.API) error {
 
 	ctx := float.NewContext(api, 0, util.IEEE64ExponentBitwidth, util.IEEE64Precision)
+	if c.option != nil {
+		c.option(&ctx)
+	}
 	lat := ctx.NewFloat(c.Lat)
 	lng := ctx.NewFloat(c.Lng)
 
@@ -319,3 +326,39 @@ func BenchmarkLoc2IndexProofMemory(b *testing.B) {
 		}
 	}
 }
+
+// The number of constraints of `loc2Index64Circuit` before constant operands got their own fast paths in `float`.
+var loc2Index64BaselineConstraints = [2]int{25717, 81606}
+
+func TestLoc2Index64Constraints(t *testing.T) {
+	compile := func(option func(*float.Context)) [2]int {
+		var constraints [2]int
+		for i, builder := range []frontend.NewBuilder{r1cs.NewBuilder, scs.NewBuilder} {
+			cs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, &loc2Index64Circuit{option: option})
+			if err != nil {
+				t.Fatal(err)
+			}
+			constraints[i] = cs.GetNbConstraints()
+		}
+		return constraints
+	}
+	constraints := compile(nil)
+	flushed := compile(func(ctx *float.Context) { ctx.FlushSubnormals = true })
+
+	var result strings.Builder
+	result.WriteString("Variant, R1CS, PLONK\n")
+	result.WriteString(fmt.Sprintf("Baseline, %d, %d\n", loc2Index64BaselineConstraints[0], loc2Index64BaselineConstraints[1]))
+	result.WriteString(fmt.Sprintf("Current, %d, %d\n", constraints[0], constraints[1]))
+	result.WriteString(fmt.Sprintf("FlushSubnormals, %d, %d\n", flushed[0], flushed[1]))
+
+	for i, backend := range []string{"R1CS", "PLONK"} {
+		if constraints[i] > loc2Index64BaselineConstraints[i] {
+			t.Errorf("%s: loc2index64 costs %d constraints, but %d before", backend, constraints[i], loc2Index64BaselineConstraints[i])
+		}
+	}
+
+	err := os.WriteFile("../benchmarks/tests/loc2index64_constraints.csv", []byte(result.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
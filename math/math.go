@@ -10,9 +10,9 @@ import (
 type Polynomial []float.FloatVar
 
 // Eval evaluates the polynomial at a given point with Horner's Method
-func (p Polynomial) Eval(ctx *float.Context, at float.FloatVar) float.FloatVar {
+func (p Polynomial) Eval(ctx float.FloatAPI, at float.FloatVar) float.FloatVar {
 
-	result := ctx.NewF64Constant(0)
+	result := ctx.Const(0)
 
 	// Iterate over the coefficients of the polynomial in reverse order.
 	for i := len(p) - 1; i >= 0; i-- {
@@ -25,14 +25,56 @@ func (p Polynomial) Eval(ctx *float.Context, at float.FloatVar) float.FloatVar {
 	return result
 }
 
+// EvalWide evaluates the polynomial at a given point with Horner's Method like Eval, but keeps the
+// intermediate results unnormalized and rounds only once at the end, see float.WideFloat for the error bound
+func (p Polynomial) EvalWide(ctx float.FloatAPI, at float.FloatVar) float.FloatVar {
+	if len(p) == 0 {
+		return ctx.Const(0)
+	}
+
+	x := ctx.Widen(at)
+	result := ctx.Widen(p[len(p)-1])
+
+	for i := len(p) - 2; i >= 0; i-- {
+		result = ctx.WideAdd(ctx.WideMul(result, x), ctx.Widen(p[i]))
+	}
+
+	return ctx.Normalize(result)
+}
+
+// EvalCompensated evaluates the polynomial at a given point with the compensated Horner's Method, which
+// computes the rounding errors of Horner's Method with the error-free transformations TwoProduct and TwoSum,
+// evaluates the polynomial of these errors alongside, and adds it to the result at the end
+// The result is as accurate as if Horner's Method was computed with twice the precision and then rounded, i.e.,
+// its relative error is at most u + O(u^2) times the condition number of the evaluation
+// [GLL09] S. Graillat, P. Langlois, N. Louvet. Algorithms for accurate, validated and fast polynomial evaluation
+func (p Polynomial) EvalCompensated(ctx float.FloatAPI, at float.FloatVar) float.FloatVar {
+	if len(p) == 0 {
+		return ctx.Const(0)
+	}
+
+	result := p[len(p)-1]
+	compensation := ctx.Const(0)
+
+	for i := len(p) - 2; i >= 0; i-- {
+		product, productError := ctx.TwoProduct(result, at)
+		var sumError float.FloatVar
+		result, sumError = ctx.TwoSum(product, p[i])
+
+		compensation = ctx.FMA(compensation, at, ctx.Add(productError, sumError))
+	}
+
+	return ctx.Add(result, compensation)
+}
+
 // EvalK evaluates the polynomial at a given point with a k-fold Horner's Method
 // Very accurate for k=1, looses accuracy for k > 1 - should include proper error handling
 // [Cam23] https://hal.science/hal-04030542/document
-func (p Polynomial) EvalK(ctx *float.Context, at float.FloatVar, k int) float.FloatVar {
+func (p Polynomial) EvalK(ctx float.FloatAPI, at float.FloatVar, k int) float.FloatVar {
 
 	parts := make([]float.FloatVar, k)
 	for i := range parts {
-		parts[i] = ctx.NewF64Constant(0)
+		parts[i] = ctx.Const(0)
 	}
 
 	// Iterate over the coefficients of the polynomial in reverse order
@@ -58,21 +100,22 @@ func (p Polynomial) EvalK(ctx *float.Context, at float.FloatVar, k int) float.Fl
 
 // ToDo REFACTOR - Fix Sign and IsAbnormal
 // ToDo REFACTOR - Constant values as structs in util
-func Atan2(f *float.Context, y, x float.FloatVar) float.FloatVar {
+func Atan2(f float.FloatAPI, y, x float.FloatVar) float.FloatVar {
+	api := f.API()
 
-	pi := f.NewF64Constant(math.Pi)
+	pi := f.Const(math.Pi)
 
 	// TODO: Check if zero, do not divide by 0
 	quotient := f.Div(y, x)
-	result := AtanRemez64(f, quotient)
+	result := AtanRemez(f, quotient)
 
 	addPi := f.Add(result, pi)
 	subPi := f.Sub(result, pi)
 
 	// The result of Atan2 depends on the signs of x and y
-	atan2S := f.Api.Select(x.Sign, f.Api.Select(y.Sign, subPi.Sign, addPi.Sign), result.Sign)
-	atan2E := f.Api.Select(x.Sign, f.Api.Select(y.Sign, subPi.Exponent, addPi.Exponent), result.Exponent)
-	atan2M := f.Api.Select(x.Sign, f.Api.Select(y.Sign, subPi.Mantissa, addPi.Mantissa), result.Mantissa)
+	atan2S := api.Select(x.Sign, api.Select(y.Sign, subPi.Sign, addPi.Sign), result.Sign)
+	atan2E := api.Select(x.Sign, api.Select(y.Sign, subPi.Exponent, addPi.Exponent), result.Exponent)
+	atan2M := api.Select(x.Sign, api.Select(y.Sign, subPi.Mantissa, addPi.Mantissa), result.Mantissa)
 
 	ret := float.FloatVar{
 		Sign:       atan2S,
@@ -89,96 +132,72 @@ func Atan2(f *float.Context, y, x float.FloatVar) float.FloatVar {
 	return ret
 }
 
-func AtanRemez64(f *float.Context, x float.FloatVar) float.FloatVar {
-
-	halfPi := f.NewF64Constant(math.Pi / 2.0)
-
-	// We approximate the arctan(x) in the range [0,1] with a polynomial of degree 24,
-	// the Remez algorithm has supplied us with the appropriate constants
-	// (The lower the degree, the lower the accuracy, but also the less constraints!)
-	var coefficient = []float.FloatVar{
-		f.NewF64Constant(-0.000942885517390737),
-		f.NewF64Constant(0.012831303689781028),
-		f.NewF64Constant(-0.08114401696242823),
-		f.NewF64Constant(0.31521931513648976),
-		f.NewF64Constant(-0.8366759947462465),
-		f.NewF64Constant(1.5941310579396186),
-		f.NewF64Constant(-2.225620203806413),
-		f.NewF64Constant(2.283041197386529),
-		f.NewF64Constant(-1.716279012920493),
-		f.NewF64Constant(0.9814600474792705),
-		f.NewF64Constant(-0.5135300638813421),
-		f.NewF64Constant(0.28006786416868995),
-		f.NewF64Constant(-0.0649531804791716),
-		f.NewF64Constant(-0.07417760886128402),
-		f.NewF64Constant(-0.0034470515096669467),
-		f.NewF64Constant(0.11167263969100766),
-		f.NewF64Constant(-7.11657573061619e-05),
-		f.NewF64Constant(-0.14285027929722588),
-		f.NewF64Constant(-4.888898128412832e-07),
-		f.NewF64Constant(0.2000000246846688),
-		f.NewF64Constant(-8.33552299173139e-10),
-		f.NewF64Constant(-0.3333333333160862),
-		f.NewF64Constant(-1.8894178462249048e-13),
-		f.NewF64Constant(1.0000000000000009),
-		f.NewF64Constant(-4.1904552294565837e-19),
-	}
-
-	oneConst := f.NewF64Constant(float64(1))
-
-	sign := x.Sign
-	x.Sign = 0
-	// TODO: Proper abnormal handling, check that x isn't 0 and act accordingly if x=0
-
-	// Approximate atan by atan(x) = pi/2 - atan(1/x) if x>1
-	greaterOne := f.IsGt(x, oneConst)
-	reciprocal := f.Div(oneConst, x)
-
-	x.Exponent = f.Api.Select(greaterOne, reciprocal.Exponent, x.Exponent)
-	x.Mantissa = f.Api.Select(greaterOne, reciprocal.Mantissa, x.Mantissa)
-
-	// Create a Polynomial from the coefficients
-	p := Polynomial(coefficient)
+// Coefficients of the polynomial of degree 24 that approximates arctan(x) in the range [0,1], which are
+// precise enough for binary64 and supplied by the Remez algorithm
+var atanRemezCoefficients64 = []float64{
+	-0.000942885517390737,
+	0.012831303689781028,
+	-0.08114401696242823,
+	0.31521931513648976,
+	-0.8366759947462465,
+	1.5941310579396186,
+	-2.225620203806413,
+	2.283041197386529,
+	-1.716279012920493,
+	0.9814600474792705,
+	-0.5135300638813421,
+	0.28006786416868995,
+	-0.0649531804791716,
+	-0.07417760886128402,
+	-0.0034470515096669467,
+	0.11167263969100766,
+	-7.11657573061619e-05,
+	-0.14285027929722588,
+	-4.888898128412832e-07,
+	0.2000000246846688,
+	-8.33552299173139e-10,
+	-0.3333333333160862,
+	-1.8894178462249048e-13,
+	1.0000000000000009,
+	-4.1904552294565837e-19,
+}
 
-	// Evaluate the polynomial at x
-	result := p.EvalK(f, x, 1)
+// Coefficients of the polynomial of degree 10 that approximates arctan(x) in the range [0,1], which are
+// precise enough for binary32 and supplied by the Remez algorithm
+var atanRemezCoefficients32 = []float64{
+	0.022023164,
+	-0.13374522,
+	0.32946652,
+	-0.37905943,
+	0.1053119,
+	0.16982068,
+	0.005476566,
+	-0.33393043,
+	0.000035324891,
+	0.99999905,
+	0.0000000073035884,
+}
 
-	sub := f.Sub(halfPi, result)
+// AtanRemez approximates arctan(x) with a polynomial supplied by the Remez algorithm, where the degree of the
+// polynomial is chosen by the precision of the format: 10 for formats up to binary32, and 24 otherwise
+// (The lower the degree, the lower the accuracy, but also the less constraints!)
+// ToDo REFACTOR - Fix Sign and IsAbnormal
+func AtanRemez(f float.FloatAPI, x float.FloatVar) float.FloatVar {
+	api := f.API()
 
-	resultE := f.Api.Select(greaterOne, sub.Exponent, result.Exponent)
-	resultM := f.Api.Select(greaterOne, sub.Mantissa, result.Mantissa)
-	res := float.FloatVar{
-		Sign:       sign,
-		Exponent:   resultE,
-		Mantissa:   resultM,
-		IsAbnormal: 0,
+	coefficients := atanRemezCoefficients64
+	if _, M := f.Format(); M <= 23 {
+		coefficients = atanRemezCoefficients32
 	}
-	return res
-}
 
-// ToDo REFACTOR - Fix Sign and IsAbnormal
-// ToDo REFACTOR - Constant values as structs in util
-func AtanRemez32(f *float.Context, x float.FloatVar) float.FloatVar {
-
-	halfPi := f.NewF32Constant(math.Pi / 2.0)
-
-	// We approximate the arctan(x) in the range [0,1] with a polynomial of degree 10,
-	// the Remez algorithm has supplied us with the appropriate constants
-	var coefficient = []float.FloatVar{
-		f.NewF32Constant(0.022023164),
-		f.NewF32Constant(-0.13374522),
-		f.NewF32Constant(0.32946652),
-		f.NewF32Constant(-0.37905943),
-		f.NewF32Constant(0.1053119),
-		f.NewF32Constant(0.16982068),
-		f.NewF32Constant(0.005476566),
-		f.NewF32Constant(-0.33393043),
-		f.NewF32Constant(0.000035324891),
-		f.NewF32Constant(0.99999905),
-		f.NewF32Constant(0.0000000073035884),
+	halfPi := f.Const(math.Pi / 2.0)
+
+	var coefficient = make([]float.FloatVar, len(coefficients))
+	for i, c := range coefficients {
+		coefficient[i] = f.Const(c)
 	}
 
-	oneConst := f.NewF32Constant(float32(1))
+	oneConst := f.Const(1)
 
 	sign := x.Sign
 	x.Sign = 0
@@ -188,8 +207,8 @@ func AtanRemez32(f *float.Context, x float.FloatVar) float.FloatVar {
 	greaterOne := f.IsGt(x, oneConst)
 	reciprocal := f.Div(oneConst, x)
 
-	x.Exponent = f.Api.Select(greaterOne, reciprocal.Exponent, x.Exponent)
-	x.Mantissa = f.Api.Select(greaterOne, reciprocal.Mantissa, x.Mantissa)
+	x.Exponent = api.Select(greaterOne, reciprocal.Exponent, x.Exponent)
+	x.Mantissa = api.Select(greaterOne, reciprocal.Mantissa, x.Mantissa)
 
 	// Create a Polynomial from the coefficients
 	p := Polynomial(coefficient)
@@ -199,8 +218,8 @@ func AtanRemez32(f *float.Context, x float.FloatVar) float.FloatVar {
 
 	sub := f.Sub(halfPi, result)
 
-	resultE := f.Api.Select(greaterOne, sub.Exponent, result.Exponent)
-	resultM := f.Api.Select(greaterOne, sub.Mantissa, result.Mantissa)
+	resultE := api.Select(greaterOne, sub.Exponent, result.Exponent)
+	resultM := api.Select(greaterOne, sub.Mantissa, result.Mantissa)
 	res := float.FloatVar{
 		Sign:       sign,
 		Exponent:   resultE,
@@ -210,52 +229,47 @@ func AtanRemez32(f *float.Context, x float.FloatVar) float.FloatVar {
 	return res
 }
 
-func SinTaylor64(f *float.Context, x float.FloatVar) float.FloatVar {
-	ret := f.NewF64Constant(float64(0))
-	pi := f.NewF64Constant(math.Pi)
-	halfPi := f.NewF64Constant(math.Pi / 2.0)
-
-	// TODO: Assert x <= pi
-
-	// TODO: Zero handling: Return 0 in case x = 0
-
-	// The Taylor Series approximation's inaccuracy increases when the input is close to pi
-	// we mitigate this with the symmetry of the function
-	// Since sin(x) is symmetric at pi/2, we fold across the symmetry axis in case term > pi/2
-	greaterHalfPi := f.IsGt(x, halfPi)
-	folding := f.Sub(pi, x)
+// SinTaylor approximates sin(x) for |x| <= pi with the first 15 terms of the Taylor series
+func SinTaylor(f float.FloatAPI, x float.FloatVar) float.FloatVar {
+	ret := f.Const(0)
 
-	var term = float.FloatVar{
-		Sign:       0,
-		Exponent:   f.Api.Select(greaterHalfPi, folding.Exponent, x.Exponent),
-		Mantissa:   f.Api.Select(greaterHalfPi, folding.Mantissa, x.Mantissa),
-		IsAbnormal: 0,
+	for i, term := range sinTaylorTerms(f, x) {
+		if (i % 2) == 0 {
+			ret = f.Add(ret, term)
+		} else {
+			ret = f.Sub(ret, term)
+		}
 	}
 
-	xSquare := f.Mul(term, term)
-	// Calculate term*x^2 / 2i*(2i+1) in each loop iteration
-	for i := 1; i <= 15; i++ {
+	ret.Sign = x.Sign
+	return ret
+}
 
+// SinTaylorWide approximates sin(x) like SinTaylor, but sums up the terms of the series without intermediate
+// rounding
+func SinTaylorWide(f float.FloatAPI, x float.FloatVar) float.FloatVar {
+	terms := sinTaylorTerms(f, x)
+
+	ret := f.Widen(terms[0])
+	for i := 1; i < len(terms); i++ {
 		if (i % 2) == 0 {
-			ret = f.Sub(ret, term)
+			ret = f.WideAdd(ret, f.Widen(terms[i]))
 		} else {
-			ret = f.Add(ret, term)
+			ret = f.WideSub(ret, f.Widen(terms[i]))
 		}
-
-		nominator := f.Mul(term, xSquare)
-		denominator := f.NewF64Constant(float64(2 * i * (2*i + 1)))
-
-		term = f.Div(nominator, denominator)
 	}
 
-	ret.Sign = x.Sign
-	return ret
+	result := f.Normalize(ret)
+	result.Sign = x.Sign
+	return result
 }
 
-func SinTaylor32(f *float.Context, x float.FloatVar) float.FloatVar {
-	ret := f.NewF32Constant(float32(0))
-	pi := f.NewF32Constant(math.Pi)
-	halfPi := f.NewF32Constant(math.Pi / 2.0)
+// sinTaylorTerms returns the absolute values of the first 15 terms of the Taylor series of sin(x)
+func sinTaylorTerms(f float.FloatAPI, x float.FloatVar) []float.FloatVar {
+	api := f.API()
+
+	pi := f.Const(math.Pi)
+	halfPi := f.Const(math.Pi / 2.0)
 
 	// TODO: Assert x <= pi
 
@@ -269,27 +283,25 @@ func SinTaylor32(f *float.Context, x float.FloatVar) float.FloatVar {
 
 	var term = float.FloatVar{
 		Sign:       0,
-		Exponent:   f.Api.Select(greaterHalfPi, folding.Exponent, x.Exponent),
-		Mantissa:   f.Api.Select(greaterHalfPi, folding.Mantissa, x.Mantissa),
+		Exponent:   api.Select(greaterHalfPi, folding.Exponent, x.Exponent),
+		Mantissa:   api.Select(greaterHalfPi, folding.Mantissa, x.Mantissa),
 		IsAbnormal: 0,
 	}
 
+	terms := make([]float.FloatVar, 0, 15)
 	xSquare := f.Mul(term, term)
 	// Calculate term*x^2 / 2i*(2i+1) in each loop iteration
 	for i := 1; i <= 15; i++ {
-
-		if (i % 2) == 0 {
-			ret = f.Sub(ret, term)
-		} else {
-			ret = f.Add(ret, term)
+		terms = append(terms, term)
+		if i == 15 {
+			break
 		}
 
 		nominator := f.Mul(term, xSquare)
-		denominator := f.NewF32Constant(float32(2 * i * (2*i + 1)))
+		denominator := f.Const(float64(2 * i * (2*i + 1)))
 
 		term = f.Div(nominator, denominator)
 	}
 
-	ret.Sign = x.Sign
-	return ret
+	return terms
 }
@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/tumberger/zk-Location/float"
+	"github.com/tumberger/zk-Location/util"
+	"math"
 	"math/big"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,12 +17,14 @@ import (
 	"github.com/consensys/gnark/backend"
 
 	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/consensys/gnark/test"
 )
 
 type PolynomialEvalCircuit struct {
-	X frontend.Variable `gnark:",public"`
-	P frontend.Variable `gnark:",public"`
+	X  frontend.Variable `gnark:",public"`
+	P  frontend.Variable `gnark:",public"`
+	op string
 }
 
 func (c *PolynomialEvalCircuit) Define(api frontend.API) error {
@@ -41,7 +46,15 @@ func (c *PolynomialEvalCircuit) Define(api frontend.API) error {
 	p := Polynomial(coeffs)
 
 	// Evaluate the polynomial at x
-	result := p.Eval(&ctx, x)
+	var result float.FloatVar
+	switch c.op {
+	case "Eval":
+		result = p.Eval(&ctx, x)
+	case "EvalWide":
+		result = p.EvalWide(&ctx, x)
+	case "EvalCompensated":
+		result = p.EvalCompensated(&ctx, x)
+	}
 
 	// Assert that the result is equal to the public input
 	api.AssertIsEqual(result.Exponent, expected.Exponent)
@@ -66,21 +79,82 @@ func TestPolynomialEval(t *testing.T) {
 		t.Fatalf("Failed to parse expected output hex string")
 	}
 
-	// Create a witness with the test input and expected output
-	witness := &PolynomialEvalCircuit{
-		X: 0,
-		P: 0,
+	for _, op := range []string{"Eval", "EvalWide", "EvalCompensated"} {
+		// Create a witness with the test input and expected output
+		witness := &PolynomialEvalCircuit{
+			X:  0,
+			P:  0,
+			op: op,
+		}
+
+		assignment := &PolynomialEvalCircuit{
+			X:  inputBigInt,
+			P:  outputBigInt,
+			op: op,
+		}
+
+		// Run the test
+		assert.SolvingSucceeded(
+			witness,
+			assignment,
+			test.WithBackends(backend.GROTH16),
+		)
 	}
+}
 
-	assignment := &PolynomialEvalCircuit{
-		X: inputBigInt,
-		P: outputBigInt,
+// IllConditionedPolynomialCircuit evaluates (x - 1)^3 in its expanded form x^3 - 3x^2 + 3x - 1, where the
+// terms cancel out close to x = 1
+type IllConditionedPolynomialCircuit struct {
+	X  frontend.Variable `gnark:",public"`
+	P  frontend.Variable `gnark:",public"`
+	op string
+}
+
+func (c *IllConditionedPolynomialCircuit) Define(api frontend.API) error {
+	ctx := float.NewContext(api, 0, 11, 52) // F64
+
+	x := ctx.NewFloat(c.X)
+	expected := ctx.NewFloat(c.P)
+
+	p := Polynomial([]float.FloatVar{
+		ctx.NewF64Constant(-1),
+		ctx.NewF64Constant(3),
+		ctx.NewF64Constant(-3),
+		ctx.NewF64Constant(1),
+	})
+
+	var result float.FloatVar
+	switch c.op {
+	case "Eval":
+		result = p.Eval(&ctx, x)
+	case "EvalCompensated":
+		result = p.EvalCompensated(&ctx, x)
 	}
 
-	// Run the test
+	ctx.AssertIsEqual(result, expected)
+	return nil
+}
+
+func TestPolynomialEvalCompensated(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	// At x = 1.001, the condition number is about 2^33, so Horner's Method loses most of the bits, while the
+	// compensated one still yields the correctly rounded result of (x - 1)^3, where x - 1 is exact
+	x := 1.001
+	d := new(big.Float).SetPrec(256).SetFloat64(x - 1)
+	p, _ := new(big.Float).Mul(d, new(big.Float).Mul(d, d)).Float64()
+
+	X := new(big.Int).SetUint64(math.Float64bits(x))
+	P := new(big.Int).SetUint64(math.Float64bits(p))
+
 	assert.SolvingSucceeded(
-		witness,
-		assignment,
+		&IllConditionedPolynomialCircuit{X: 0, P: 0, op: "EvalCompensated"},
+		&IllConditionedPolynomialCircuit{X: X, P: P, op: "EvalCompensated"},
+		test.WithBackends(backend.GROTH16),
+	)
+	assert.SolvingFailed(
+		&IllConditionedPolynomialCircuit{X: 0, P: 0, op: "Eval"},
+		&IllConditionedPolynomialCircuit{X: X, P: P, op: "Eval"},
 		test.WithBackends(backend.GROTH16),
 	)
 }
@@ -182,7 +256,7 @@ func (c *CircuitATanRemez64) Define(api frontend.API) error {
 	x := ctx.NewFloat(c.X)
 	z := ctx.NewFloat(c.Z)
 
-	result := AtanRemez64(&ctx, x)
+	result := AtanRemez(&ctx, x)
 
 	// Assertion of Mantissa fails, ULP test checks that ULP error <1
 	api.AssertIsEqual(result.Exponent, z.Exponent)
@@ -207,7 +281,7 @@ func (c *CircuitATanRemez32ULP) Define(api frontend.API) error {
 	z_lower := ctx.NewFloat(c.Z_lower)
 	z_upper := ctx.NewFloat(c.Z_upper)
 
-	result := AtanRemez32(&ctx, x)
+	result := AtanRemez(&ctx, x)
 
 	api.AssertIsEqual(result.Exponent, z.Exponent)
 	api.AssertIsLessOrEqual(z_lower.Mantissa, result.Mantissa)
@@ -233,7 +307,7 @@ func (c *CircuitATanRemez64ULP) Define(api frontend.API) error {
 	z_lower := ctx.NewFloat(c.Z_lower)
 	z_upper := ctx.NewFloat(c.Z_upper)
 
-	result := AtanRemez64(&ctx, x)
+	result := AtanRemez(&ctx, x)
 
 	api.AssertIsEqual(result.Exponent, z.Exponent)
 	api.AssertIsLessOrEqual(z_lower.Mantissa, result.Mantissa)
@@ -254,7 +328,13 @@ func (c *SinCircuit) Define(api frontend.API) error {
 	x := ctx.NewFloat(c.X)
 	z := ctx.NewFloat(c.Z)
 
-	result := SinTaylor64(&ctx, x)
+	var result float.FloatVar
+	switch c.op {
+	case "SinTaylor":
+		result = SinTaylor(&ctx, x)
+	case "SinTaylorWide":
+		result = SinTaylorWide(&ctx, x)
+	}
 
 	// Assertion of Mantissa fails, ULP test checks that ULP error <1
 	api.AssertIsEqual(result.Exponent, z.Exponent)
@@ -279,7 +359,13 @@ func (c *CircuitSin64ULP) Define(api frontend.API) error {
 	z_lower := ctx.NewFloat(c.Z_lower)
 	z_upper := ctx.NewFloat(c.Z_upper)
 
-	result := SinTaylor64(&ctx, x)
+	var result float.FloatVar
+	switch c.op {
+	case "SinTaylor":
+		result = SinTaylor(&ctx, x)
+	case "SinTaylorWide":
+		result = SinTaylorWide(&ctx, x)
+	}
 
 	api.AssertIsEqual(result.Exponent, z.Exponent)
 	api.AssertIsLessOrEqual(z_lower.Mantissa, result.Mantissa)
@@ -305,8 +391,8 @@ func TestATanCircuit(t *testing.T) {
 			b, _ := new(big.Int).SetString(data[1], 16)
 
 			assert.ProverSucceeded(
-				&CircuitATanRemez64{X: 0, Z: 0, op: "AtanRemez64"},
-				&CircuitATanRemez64{X: a, Z: b, op: "AtanRemez64"},
+				&CircuitATanRemez64{X: 0, Z: 0, op: "AtanRemez"},
+				&CircuitATanRemez64{X: a, Z: b, op: "AtanRemez"},
 				test.WithCurves(ecc.BN254),
 				test.WithBackends(backend.GROTH16),
 			)
@@ -333,8 +419,8 @@ func TestCircuitATanRemez32ULP(t *testing.T) {
 			d, _ := new(big.Int).SetString(data[3], 16)
 
 			assert.ProverSucceeded(
-				&CircuitATanRemez32ULP{X: 0, Z: 0, Z_lower: 0, Z_upper: 0, op: "AtanRemez32"},
-				&CircuitATanRemez32ULP{X: a, Z: b, Z_lower: c, Z_upper: d, op: "AtanRemez32"},
+				&CircuitATanRemez32ULP{X: 0, Z: 0, Z_lower: 0, Z_upper: 0, op: "AtanRemez"},
+				&CircuitATanRemez32ULP{X: a, Z: b, Z_lower: c, Z_upper: d, op: "AtanRemez"},
 				test.WithCurves(ecc.BN254),
 				test.WithBackends(backend.GROTH16),
 			)
@@ -361,8 +447,8 @@ func TestCircuitATanRemez64ULP(t *testing.T) {
 			d, _ := new(big.Int).SetString(data[3], 16)
 
 			assert.ProverSucceeded(
-				&CircuitATanRemez64ULP{X: 0, Z: 0, Z_lower: 0, Z_upper: 0, op: "AtanRemez64"},
-				&CircuitATanRemez64ULP{X: a, Z: b, Z_lower: c, Z_upper: d, op: "AtanRemez64"},
+				&CircuitATanRemez64ULP{X: 0, Z: 0, Z_lower: 0, Z_upper: 0, op: "AtanRemez"},
+				&CircuitATanRemez64ULP{X: a, Z: b, Z_lower: c, Z_upper: d, op: "AtanRemez"},
 				test.WithCurves(ecc.BN254),
 				test.WithBackends(backend.GROTH16),
 			)
@@ -387,8 +473,8 @@ func TestCircuitSin(t *testing.T) {
 			b, _ := new(big.Int).SetString(data[1], 16)
 
 			assert.ProverSucceeded(
-				&SinCircuit{X: 0, Z: 0, op: "SinTaylor64"},
-				&SinCircuit{X: a, Z: b, op: "SinTaylor64"},
+				&SinCircuit{X: 0, Z: 0, op: "SinTaylor"},
+				&SinCircuit{X: a, Z: b, op: "SinTaylor"},
 				test.WithCurves(ecc.BN254),
 				test.WithBackends(backend.GROTH16),
 			)
@@ -416,8 +502,8 @@ func TestCircuitSin64ULP(t *testing.T) {
 			d, _ := new(big.Int).SetString(data[3], 16)
 
 			assert.ProverSucceeded(
-				&CircuitSin64ULP{X: 0, Z: 0, Z_lower: 0, Z_upper: 0, op: "SinTaylor64"},
-				&CircuitSin64ULP{X: a, Z: b, Z_lower: c, Z_upper: d, op: "SinTaylor64"},
+				&CircuitSin64ULP{X: 0, Z: 0, Z_lower: 0, Z_upper: 0, op: "SinTaylor"},
+				&CircuitSin64ULP{X: a, Z: b, Z_lower: c, Z_upper: d, op: "SinTaylor"},
 				test.WithCurves(ecc.BN254),
 				test.WithBackends(backend.GROTH16),
 			)
@@ -425,6 +511,65 @@ func TestCircuitSin64ULP(t *testing.T) {
 	}
 }
 
+// The test vectors of TestCircuitSin64ULP are outside of the domain of SinTaylor, so we compare SinTaylorWide
+// against the standard library on [0, pi/2], where the sum of the series without intermediate rounding stays
+// within 1 ULP
+func TestCircuitSinTaylorWideULP(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	r := rand.New(rand.NewSource(42))
+	for i := 0; i < 64; i++ {
+		x := r.Float64() * math.Pi / 2
+		z := math.Sin(x)
+
+		a := new(big.Int).SetUint64(math.Float64bits(x))
+		b := new(big.Int).SetUint64(math.Float64bits(z))
+		c := new(big.Int).SetUint64(math.Float64bits(math.Nextafter(z, 0)))
+		d := new(big.Int).SetUint64(math.Float64bits(math.Nextafter(z, 1)))
+
+		assert.SolvingSucceeded(
+			&CircuitSin64ULP{X: 0, Z: 0, Z_lower: 0, Z_upper: 0, op: "SinTaylorWide"},
+			&CircuitSin64ULP{X: a, Z: b, Z_lower: c, Z_upper: d, op: "SinTaylorWide"},
+			test.WithCurves(ecc.BN254),
+			test.WithBackends(backend.GROTH16),
+		)
+	}
+}
+
+func TestWideConstraints(t *testing.T) {
+	var result_all strings.Builder
+	result_all.WriteString("Function, #Constraints (Rounded), #Constraints (Wide)\n")
+
+	circuits := []struct {
+		name          string
+		rounded, wide frontend.Circuit
+	}{
+		{"Polynomial.Eval", &PolynomialEvalCircuit{op: "Eval"}, &PolynomialEvalCircuit{op: "EvalWide"}},
+		{"SinTaylor", &SinCircuit{op: "SinTaylor"}, &SinCircuit{op: "SinTaylorWide"}},
+	}
+
+	for _, c := range circuits {
+		rounded, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, c.rounded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wide, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, c.wide)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result_all.WriteString(fmt.Sprintf("%s, %d, %d\n", c.name, rounded.GetNbConstraints(), wide.GetNbConstraints()))
+
+		if wide.GetNbConstraints() >= rounded.GetNbConstraints() {
+			t.Errorf("%s: wide evaluation costs %d constraints, but %d when rounded", c.name, wide.GetNbConstraints(), rounded.GetNbConstraints())
+		}
+	}
+
+	err := os.WriteFile("../benchmarks/math/constraints_wide.csv", []byte(result_all.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 /*
 func TestRealProofComputation(t *testing.T) {
 
@@ -479,3 +624,75 @@ func TestRealProofComputation(t *testing.T) {
 	}
 
 }*/
+
+// `CircuitFormat` checks that the function `op` evaluated at `X` in the format with an `E`-bit exponent and an
+// `M`-bit mantissa is at most `ulp` ULPs away from `Z`, which allows the precision-agnostic functions to be
+// tested in any format, including custom ones.
+type CircuitFormat struct {
+	X   frontend.Variable `gnark:",secret"`
+	Z   frontend.Variable `gnark:",public"`
+	E   uint
+	M   uint
+	op  string
+	ulp uint
+}
+
+func (c *CircuitFormat) Define(api frontend.API) error {
+	ctx := float.NewContext(api, 0, c.E, c.M)
+
+	x := ctx.NewFloat(c.X)
+	z := ctx.NewFloat(c.Z)
+
+	var result float.FloatVar
+	switch c.op {
+	case "SinTaylor":
+		result = SinTaylor(&ctx, x)
+	case "AtanRemez":
+		result = AtanRemez(&ctx, x)
+	}
+
+	api.AssertIsEqual(ctx.UlpDistanceLe(result, z, c.ulp), 1)
+	return nil
+}
+
+func TestCircuitFormat(t *testing.T) {
+	assert := test.NewAssert(t)
+
+	formats := []struct {
+		E, M uint
+		ulp  uint
+	}{
+		{5, 10, 1},  // binary16
+		{8, 23, 1},  // binary32
+		{8, 30, 1},  // A custom format with a 30-bit mantissa
+		{11, 52, 1}, // binary64
+	}
+	ops := []struct {
+		op string
+		fn func(float64) float64
+	}{
+		{"SinTaylor", math.Sin},
+		{"AtanRemez", math.Atan},
+	}
+
+	for _, format := range formats {
+		for _, op := range ops {
+			// The inputs `k / 16` are exactly representable in all formats above, and are at most pi/2, since
+			// `SinTaylor` loses accuracy close to pi due to the rounding of pi itself
+			for k := 1; k <= 25; k += 4 {
+				x := float64(k) / 16
+				a := util.F64ToBits(x, uint64(format.E), uint64(format.M))
+				b := util.F64ToBits(op.fn(x), uint64(format.E), uint64(format.M))
+
+				assert.Run(func(assert *test.Assert) {
+					assert.ProverSucceeded(
+						&CircuitFormat{X: 0, Z: 0, E: format.E, M: format.M, op: op.op, ulp: format.ulp},
+						&CircuitFormat{X: a, Z: b, E: format.E, M: format.M, op: op.op, ulp: format.ulp},
+						test.WithCurves(ecc.BN254),
+						test.WithBackends(backend.GROTH16),
+					)
+				}, fmt.Sprintf("%s/E=%d,M=%d/x=%v", op.op, format.E, format.M, x))
+			}
+		}
+	}
+}
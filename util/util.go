@@ -285,22 +285,28 @@ var Azimuth_32 = [20]float32{
 const ScaleFactor = 1e9
 
 func ComponentsOf(v uint64, E, M uint64) []*big.Int {
-	s := v >> (E + M)
-	e := (v >> M) - (s << E)
-	m := v - (s << (E + M)) - (e << M)
+	return ComponentsOfBig(new(big.Int).SetUint64(v), E, M)
+}
+
+// Same as `ComponentsOf`, but the encoded value `v` may have more than 64 bits, e.g., for binary128.
+func ComponentsOfBig(v *big.Int, E, M uint64) []*big.Int {
+	s := uint64(v.Bit(int(E + M)))
+	e := new(big.Int).Rsh(v, uint(M))
+	e.SetBit(e, int(E), 0)
+	m := new(big.Int).And(v, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(M)), big.NewInt(1)))
 
 	sign := big.NewInt(int64(s))
 
-	exponent_max := big.NewInt(1 << (E - 1))
+	exponent_max := new(big.Int).Lsh(big.NewInt(1), uint(E-1))
 	exponent_min := new(big.Int).Sub(big.NewInt(1), exponent_max)
 
-	exponent := new(big.Int).Add(big.NewInt(int64(e)), exponent_min)
+	exponent := new(big.Int).Add(e, exponent_min)
 
-	mantissa_is_not_zero := m != 0
+	mantissa_is_not_zero := m.Sign() != 0
 	exponent_is_min := exponent.Cmp(exponent_min) == 0
 	exponent_is_max := exponent.Cmp(exponent_max) == 0
 
-	mantissa := big.NewInt(int64(m))
+	mantissa := m
 	shift := uint(0)
 	for i := int(M - 1); i >= 0; i-- {
 		if mantissa.Bit(i) != 0 {
@@ -318,7 +324,7 @@ func ComponentsOf(v uint64, E, M uint64) []*big.Int {
 		if exponent_is_max && mantissa_is_not_zero {
 			mantissa.SetUint64(0)
 		} else {
-			mantissa = new(big.Int).Add(mantissa, big.NewInt(1<<M))
+			mantissa = new(big.Int).SetBit(mantissa, int(M), 1)
 		}
 	}
 
@@ -331,32 +337,119 @@ func ComponentsOf(v uint64, E, M uint64) []*big.Int {
 }
 
 func ValueOf(components []*big.Int, E, M uint64) uint64 {
+	return ValueOfBig(components, E, M).Uint64()
+}
+
+// Same as `ValueOf`, but the encoded value may have more than 64 bits, e.g., for binary128.
+func ValueOfBig(components []*big.Int, E, M uint64) *big.Int {
 	s := components[0].Uint64()
-	e := new(big.Int).Add(components[1], big.NewInt(int64((1<<(E-1))-1+M))).Uint64()
-	m := components[2].Uint64()
+	e := new(big.Int).Add(components[1], new(big.Int).SetUint64((1<<(E-1))-1+M)).Uint64()
+	m := new(big.Int).Set(components[2])
 	is_abnormal := components[3].Uint64() == 1
 
+	v := new(big.Int).Lsh(new(big.Int).SetUint64(s), uint(M+E))
 	if e <= M {
-		if is_abnormal || (e == 0) != (m == 0) {
+		if is_abnormal || (e == 0) != (m.Sign() == 0) {
 			panic("")
 		}
-		delta := M + 1 - e
-		if (m>>delta)<<delta != m {
+		delta := uint(M + 1 - e)
+		if new(big.Int).Lsh(new(big.Int).Rsh(m, delta), delta).Cmp(m) != 0 {
 			panic("")
 		}
-		return (s << (M + E)) + (m >> delta)
+		return v.Add(v, new(big.Int).Rsh(m, delta))
 	} else {
 		e = e - M
 		if (e == (1<<E)-1) != is_abnormal {
 			panic("")
 		}
-		if is_abnormal && m == 0 {
-			m = 1
+		if is_abnormal && m.Sign() == 0 {
+			m.SetUint64(1)
 		} else {
-			m = m - (1 << M)
+			m.SetBit(m, int(M), 0)
+		}
+		return v.Add(v.Add(v, new(big.Int).Lsh(new(big.Int).SetUint64(e), uint(M))), m)
+	}
+}
+
+// Compute `floor(cbrt(x))` for a non-negative `x` by Newton's method, which decreases monotonically to the
+// result when starting from an upper bound.
+func CbrtFloor(x *big.Int) *big.Int {
+	if x.Sign() == 0 {
+		return new(big.Int)
+	}
+	y := new(big.Int).Lsh(big.NewInt(1), uint((x.BitLen()+2)/3))
+	for {
+		// `z = (2y + x / y^2) / 3`
+		z := new(big.Int).Quo(x, new(big.Int).Mul(y, y))
+		z.Add(z, new(big.Int).Lsh(y, 1))
+		z.Quo(z, big.NewInt(3))
+		if z.Cmp(y) >= 0 {
+			return y
+		}
+		y = z
+	}
+}
+
+// Round `v` to the nearest number (ties to even) in the IEEE-754 format with an `E`-bit exponent and
+// an `M`-bit mantissa, and return the encoded value.
+// The result is exact if `v` is representable in the format, e.g., when widening a float64 to binary128.
+func BitsOf(v *big.Float, E, M uint64) *big.Int {
+	bits := new(big.Int)
+	if v.Signbit() {
+		bits.SetBit(bits, int(E+M), 1)
+	}
+	exponent_mask := new(big.Int).Lsh(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(E)), big.NewInt(1)), uint(M))
+	if v.IsInf() {
+		return bits.Or(bits, exponent_mask)
+	}
+	if v.Sign() == 0 {
+		return bits
+	}
+
+	bias := int64(1)<<(E-1) - 1
+	emin := 1 - bias
+	a := new(big.Float).Abs(v)
+	// `a` is in the range `[2^e, 2^(e + 1))`, and hence has `e - emin + M + 1` significant bits in the
+	// format, which is less than `M + 1` if the result is subnormal.
+	e := int64(a.MantExp(nil)) - 1
+	prec := e - emin + int64(M) + 1
+	if prec > int64(M)+1 {
+		prec = int64(M) + 1
+	}
+	if prec <= 0 {
+		// `a` is less than half of the smallest subnormal number and is rounded to zero, unless it lies
+		// exactly between zero and the smallest subnormal number, in which case it is rounded to the even
+		// one, i.e., zero.
+		if prec == 0 && a.Cmp(new(big.Float).SetMantExp(big.NewFloat(1), int(emin-int64(M)-1))) > 0 {
+			return bits.SetBit(bits, 0, 1)
 		}
-		return (s << (M + E)) + (e << M) + m
+		return bits
+	}
+	a = new(big.Float).SetPrec(uint(prec)).SetMode(big.ToNearestEven).Set(a)
+
+	// Rounding may carry into the next binade.
+	e = int64(a.MantExp(nil)) - 1
+	if e > bias {
+		return bits.Or(bits, exponent_mask)
+	}
+	if e < emin {
+		// Subnormal numbers have a biased exponent of 0.
+		m, _ := new(big.Float).SetMantExp(a, int(int64(M)-emin)).Int(nil)
+		return bits.Or(bits, m)
+	}
+	m, _ := new(big.Float).SetMantExp(a, int(int64(M)-e)).Int(nil)
+	m.SetBit(m, int(M), 0)
+	bits.Or(bits, new(big.Int).Lsh(big.NewInt(e+bias), uint(M)))
+	return bits.Or(bits, m)
+}
+
+// Same as `BitsOf`, but for a float64 value, where NaN is encoded as the default quiet NaN.
+func F64ToBits(v float64, E, M uint64) *big.Int {
+	if math.IsNaN(v) {
+		bits := new(big.Int).Lsh(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(E+1)), big.NewInt(1)), uint(M-1))
+		return bits
 	}
+	return BitsOf(big.NewFloat(v), E, M)
 }
 
 func F32ToComponents(v float32) []*big.Int {
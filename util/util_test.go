@@ -2,6 +2,9 @@ package util
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -87,3 +90,36 @@ func TestLatLngToIJ(t *testing.T) {
 	// Print the I and J coordinates
 	fmt.Printf("I coordinate: %d, J coordinate: %d\n", i, j)
 }
+
+// TestBitsOf checks the rounding of `BitsOf` against Go's float64 to float32 conversion, which covers
+// overflow, subnormal results and ties.
+func TestBitsOf(t *testing.T) {
+	rng := rand.New(rand.NewSource(0))
+	for i := 0; i < 100000; i++ {
+		// Random bit patterns are mostly far outside float32's range, so we also scale them into it.
+		v := math.Float64frombits(rng.Uint64())
+		if math.IsNaN(v) {
+			continue
+		}
+		for _, w := range []float64{v, math.Ldexp(math.Abs(math.Mod(v, 1)), rng.Intn(300)-160)} {
+			require.Equal(t, uint64(math.Float32bits(float32(w))), F64ToBits(w, 8, 23).Uint64(), "v = %v", w)
+			require.Equal(t, math.Float64bits(w), F64ToBits(w, 11, 52).Uint64(), "v = %v", w)
+		}
+	}
+}
+
+// TestComponentsOfBig checks that encoding binary128 values as components and back is lossless.
+func TestComponentsOfBig(t *testing.T) {
+	for _, s := range []string{
+		"00000000000000000000000000000000", // +0
+		"80000000000000000000000000000001", // The smallest negative subnormal number
+		"00008000000000000000000000000000", // A subnormal number
+		"3FFF0000000000000000000000000000", // 1
+		"C000921FB54442D18469898CC51701B8", // -pi
+		"7FFEFFFFFFFFFFFFFFFFFFFFFFFFFFFF", // The largest finite number
+		"FFFF0000000000000000000000000000", // -inf
+	} {
+		v, _ := new(big.Int).SetString(s, 16)
+		require.Equal(t, 0, v.Cmp(ValueOfBig(ComponentsOfBig(v, 15, 112), 15, 112)), "v = %s", s)
+	}
+}